@@ -0,0 +1,65 @@
+// kubectl-akka is a kubectl plugin for inspecting and operating the Akka clusters of
+// AkkaCluster resources. Installed on the PATH, it runs as kubectl akka.
+//
+// Membership is read from the status the operator reports on the AkkaCluster. Operations
+// and shard queries go to the Akka Management endpoint of a running member, through the pod
+// proxy of the API server, so no port-forward or in-cluster access is needed.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+)
+
+const usage = `Usage: kubectl akka [flags] COMMAND AKKACLUSTER [ARGS]
+
+Commands:
+  members AKKACLUSTER            list members, from the AkkaCluster status
+  leader AKKACLUSTER             show the leader, from the AkkaCluster status
+  unreachable AKKACLUSTER        list unreachable members, from the AkkaCluster status
+  leave AKKACLUSTER MEMBER       ask a member to leave the cluster
+  down AKKACLUSTER MEMBER        mark a member as down
+  shards AKKACLUSTER TYPE        list the shards of an entity type, per member
+
+MEMBER is a node address, pod name or pod IP.
+
+Flags:
+`
+
+func main() {
+	flags := pflag.NewFlagSet("kubectl-akka", pflag.ContinueOnError)
+	kubeconfig := flags.String("kubeconfig", "", "path to the kubeconfig file")
+	kubecontext := flags.String("context", "", "the kubeconfig context to use")
+	namespace := flags.StringP("namespace", "n", "", "the namespace of the AkkaCluster")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == pflag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	args := flags.Args()
+	if len(args) < 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	p, err := newPlugin(*kubeconfig, *kubecontext, *namespace, os.Stdout)
+	if err == nil {
+		err = p.run(args[0], args[1], args[2:])
+	}
+	if err == errUsage {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/lightbend/akka-cluster-operator/pkg/apis"
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
+)
+
+// errUsage means the command or its arguments were not understood.
+var errUsage = errors.New("usage")
+
+// managementClient reads and writes Akka Management endpoints.
+type managementClient interface {
+	management.Reader
+	management.Writer
+}
+
+// plugin runs commands against the AkkaClusters of one namespace.
+type plugin struct {
+	client     client.Client
+	management managementClient
+	apiServer  string
+	namespace  string
+	out        io.Writer
+}
+
+// newPlugin connects to the API server of a kubeconfig context. An empty namespace means
+// the namespace of the context.
+func newPlugin(kubeconfig, kubecontext, namespace string, out io.Writer) (*plugin, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules,
+		&clientcmd.ConfigOverrides{CurrentContext: kubecontext})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	if namespace == "" {
+		if namespace, _, err = clientConfig.Namespace(); err != nil {
+			return nil, err
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := apis.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+	// proxied requests carry the credentials of the kubeconfig
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	return &plugin{
+		client:     c,
+		management: management.NewHTTPClient(transport),
+		apiServer:  config.Host,
+		namespace:  namespace,
+		out:        out,
+	}, nil
+}
+
+// run runs command against the named AkkaCluster.
+func (p *plugin) run(command, name string, args []string) error {
+	wantArgs := map[string]int{"members": 0, "leader": 0, "unreachable": 0, "leave": 1, "down": 1, "shards": 1}
+	if n, ok := wantArgs[command]; !ok || len(args) != n {
+		return errUsage
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	if err := p.client.Get(context.TODO(), types.NamespacedName{Namespace: p.namespace, Name: name}, akkaCluster); err != nil {
+		return err
+	}
+	switch command {
+	case "members":
+		return p.members(akkaCluster)
+	case "leader":
+		return p.leader(akkaCluster)
+	case "unreachable":
+		return p.unreachable(akkaCluster)
+	case "leave":
+		return p.operate(akkaCluster, args[0], management.Leave)
+	case "down":
+		return p.operate(akkaCluster, args[0], management.Down)
+	default:
+		return p.shards(akkaCluster, args[0])
+	}
+}
+
+// clusterStatus is the Akka cluster status reported on the AkkaCluster.
+func clusterStatus(akkaCluster *appv1alpha1.AkkaCluster) (*appv1alpha1.AkkaClusterManagementStatus, error) {
+	if akkaCluster.Status == nil || akkaCluster.Status.Cluster.Leader == "" && len(akkaCluster.Status.Cluster.Members) == 0 {
+		return nil, fmt.Errorf("AkkaCluster %s has no cluster status yet", akkaCluster.Name)
+	}
+	return &akkaCluster.Status.Cluster, nil
+}
+
+func (p *plugin) members(akkaCluster *appv1alpha1.AkkaCluster) error {
+	status, err := clusterStatus(akkaCluster)
+	if err != nil {
+		return err
+	}
+	unreachable := make(map[string]bool)
+	for _, member := range status.Unreachable {
+		unreachable[member.Node] = true
+	}
+	w := tabwriter.NewWriter(p.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tSTATUS\tROLES\tNOTES")
+	for _, member := range status.Members {
+		notes := []string{}
+		if member.Node == status.Leader {
+			notes = append(notes, "leader")
+		}
+		if member.Node == status.Oldest {
+			notes = append(notes, "oldest")
+		}
+		if unreachable[member.Node] {
+			notes = append(notes, "unreachable")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", member.Node, member.Status, orNone(member.Roles), orNone(notes))
+	}
+	return w.Flush()
+}
+
+func (p *plugin) leader(akkaCluster *appv1alpha1.AkkaCluster) error {
+	status, err := clusterStatus(akkaCluster)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(p.out, status.Leader)
+	return err
+}
+
+func (p *plugin) unreachable(akkaCluster *appv1alpha1.AkkaCluster) error {
+	status, err := clusterStatus(akkaCluster)
+	if err != nil {
+		return err
+	}
+	if len(status.Unreachable) == 0 {
+		_, err := fmt.Fprintln(p.out, "No unreachable members.")
+		return err
+	}
+	w := tabwriter.NewWriter(p.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tOBSERVED BY")
+	for _, member := range status.Unreachable {
+		fmt.Fprintf(w, "%s\t%s\n", member.Node, orNone(member.ObservedBy))
+	}
+	return w.Flush()
+}
+
+// operate asks a running member to apply operation, like Leave or Down, to member.
+func (p *plugin) operate(akkaCluster *appv1alpha1.AkkaCluster, member, operation string) error {
+	status, err := clusterStatus(akkaCluster)
+	if err != nil {
+		return err
+	}
+	pods, err := p.runningPods(akkaCluster)
+	if err != nil {
+		return err
+	}
+	node, err := resolveMember(status, pods, member)
+	if err != nil {
+		return err
+	}
+	// prefer the leader, which is where the operator reads status as well
+	via := pods[0]
+	for _, pod := range pods {
+		if pod.Status.PodIP == management.NodeHost(status.Leader) {
+			via = pod
+		}
+	}
+	body, err := management.OperateMember(p.management, p.endpoint(via), node, operation)
+	if err != nil {
+		return err
+	}
+	response := struct {
+		Message string `json:"message"`
+	}{}
+	if json.Unmarshal(body, &response) != nil || response.Message == "" {
+		response.Message = fmt.Sprintf("%s requested for %s", operation, node)
+	}
+	_, err = fmt.Fprintln(p.out, response.Message)
+	return err
+}
+
+// shards lists the shards of entityType on every running member. Each member only knows
+// the shards of its own region.
+func (p *plugin) shards(akkaCluster *appv1alpha1.AkkaCluster, entityType string) error {
+	pods, err := p.runningPods(akkaCluster)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(p.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "POD\tSHARD\tENTITIES")
+	failed := []string{}
+	for _, pod := range pods {
+		body, err := p.management.ReadURL(management.ShardsURL(p.endpoint(pod), entityType))
+		details := management.ShardDetails{}
+		if err == nil {
+			err = json.Unmarshal(body, &details)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", pod.Name, err))
+			continue
+		}
+		sort.Slice(details.Regions, func(i, j int) bool { return details.Regions[i].ShardID < details.Regions[j].ShardID })
+		for _, shard := range details.Regions {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", pod.Name, shard.ShardID, shard.NumEntities)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not read shards from %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// runningPods lists the running pods of the AkkaCluster, by its selector.
+func (p *plugin) runningPods(akkaCluster *appv1alpha1.AkkaCluster) ([]corev1.Pod, error) {
+	selector, err := p.podSelector(akkaCluster)
+	if err != nil {
+		return nil, err
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.PodList{}
+	if err := p.client.List(context.TODO(), list, client.InNamespace(akkaCluster.Namespace),
+		client.MatchingLabelsSelector{Selector: labelSelector}); err != nil {
+		return nil, err
+	}
+	pods := []corev1.Pod{}
+	for i := range list.Items {
+		if management.IsRunning(&list.Items[i]) {
+			pods = append(pods, list.Items[i])
+		}
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("AkkaCluster %s has no running pods", akkaCluster.Name)
+	}
+	return pods, nil
+}
+
+// podSelector is the selector of the AkkaCluster or, where the operator defaulted it, of the
+// Deployment the AkkaCluster controls, since the default depends on the operator's
+// configuration and on when the Deployment was created.
+func (p *plugin) podSelector(akkaCluster *appv1alpha1.AkkaCluster) (*metav1.LabelSelector, error) {
+	if akkaCluster.Spec.Selector != nil {
+		return akkaCluster.Spec.Selector, nil
+	}
+	deployments := &appsv1.DeploymentList{}
+	if err := p.client.List(context.TODO(), deployments, client.InNamespace(akkaCluster.Namespace)); err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if metav1.IsControlledBy(deployment, akkaCluster) && deployment.Spec.Selector != nil {
+			return deployment.Spec.Selector, nil
+		}
+	}
+	return nil, fmt.Errorf("AkkaCluster %s has no selector and no Deployment yet", akkaCluster.Name)
+}
+
+// endpoint is the management endpoint of pod through the API server proxy. The plugin
+// doesn't read the operator configuration, so the port is found by the default name.
+func (p *plugin) endpoint(pod corev1.Pod) string {
+	return management.ProxyEndpoint(p.apiServer, pod.Namespace, pod.Name, management.Ports{}.Of(&pod))
+}
+
+// resolveMember finds the node address of a member given as a node address, pod name or
+// pod IP.
+func resolveMember(status *appv1alpha1.AkkaClusterManagementStatus, pods []corev1.Pod, member string) (string, error) {
+	host := member
+	for _, pod := range pods {
+		if pod.Name == member {
+			host = pod.Status.PodIP
+		}
+	}
+	for _, m := range status.Members {
+		if m.Node == member || management.NodeHost(m.Node) == host {
+			return m.Node, nil
+		}
+	}
+	return "", fmt.Errorf("no member %s in cluster status", member)
+}
+
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "<none>"
+	}
+	return strings.Join(values, ",")
+}
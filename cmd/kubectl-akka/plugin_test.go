@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// testManagement is a managementClient mock that records requests.
+type testManagement struct {
+	puts  []string
+	reads []string
+}
+
+func (m *testManagement) ReadURL(link string) ([]byte, error) {
+	m.reads = append(m.reads, link)
+	return []byte(`{"regions":[{"shardId":"2","numEntities":3},{"shardId":"1","numEntities":5}]}`), nil
+}
+
+func (m *testManagement) PutForm(link string, form url.Values) ([]byte, error) {
+	m.puts = append(m.puts, link+" "+form.Encode())
+	return []byte(`{"message":"Leaving akka://demo@10.0.0.2:25520"}`), nil
+}
+
+func testPlugin() (*plugin, *testManagement, *bytes.Buffer) {
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = "demo"
+	akkaCluster.Namespace = "default"
+	akkaCluster.UID = "demo-uid"
+	akkaCluster.Status = &appv1alpha1.AkkaClusterStatus{
+		Cluster: appv1alpha1.AkkaClusterManagementStatus{
+			Members: []appv1alpha1.AkkaClusterMemberStatus{
+				{Node: "akka://demo@10.0.0.1:25520", Status: "Up", Roles: []string{"dc-default"}},
+				{Node: "akka://demo@10.0.0.2:25520", Status: "Up", Roles: []string{"dc-default"}},
+			},
+			Unreachable: []appv1alpha1.AkkaClusterUnreachableMemberStatus{
+				{Node: "akka://demo@10.0.0.2:25520", ObservedBy: []string{"akka://demo@10.0.0.1:25520"}},
+			},
+			Leader: "akka://demo@10.0.0.1:25520",
+			Oldest: "akka://demo@10.0.0.1:25520",
+		},
+	}
+	// the operator defaulted the selector, with a key of its configuration
+	selector := map[string]string{"app.kubernetes.io/name": "demo"}
+	deployment := &appsv1.Deployment{}
+	deployment.Name = "demo"
+	deployment.Namespace = "default"
+	deployment.OwnerReferences = []metav1.OwnerReference{
+		*metav1.NewControllerRef(akkaCluster, appv1alpha1.SchemeGroupVersion.WithKind("AkkaCluster")),
+	}
+	deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+	objs := []runtime.Object{akkaCluster, deployment}
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		pod := &corev1.Pod{}
+		pod.Name = []string{"demo-a", "demo-b"}[i]
+		pod.Namespace = "default"
+		pod.Labels = selector
+		pod.Status.PodIP = ip
+		pod.Status.Phase = corev1.PodRunning
+		objs = append(objs, pod)
+	}
+	s := scheme.Scheme
+	s.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	m := &testManagement{}
+	out := &bytes.Buffer{}
+	return &plugin{
+		client:     fake.NewFakeClientWithScheme(s, objs...),
+		management: m,
+		apiServer:  "https://api.example.com:6443",
+		namespace:  "default",
+		out:        out,
+	}, m, out
+}
+
+func TestMembers(t *testing.T) {
+	p, _, out := testPlugin()
+	if err := p.run("members", "demo", nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[1], "leader,oldest") || !strings.HasSuffix(lines[2], "unreachable") {
+		t.Errorf("unexpected members table:\n%s", out)
+	}
+	if err := p.run("members", "demo", []string{"extra"}); err != errUsage {
+		t.Errorf("expected usage error, got %v", err)
+	}
+}
+
+func TestLeaveThroughProxy(t *testing.T) {
+	p, m, out := testPlugin()
+	if err := p.run("leave", "demo", []string{"demo-b"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "https://api.example.com:6443/api/v1/namespaces/default/pods/demo-a:8558/proxy/cluster/members/" +
+		"akka:%2F%2Fdemo@10.0.0.2:25520 operation=Leave"
+	if len(m.puts) != 1 || m.puts[0] != expected {
+		t.Errorf("expected leave through leader proxy\n%s\nbut got %v", expected, m.puts)
+	}
+	if !strings.Contains(out.String(), "Leaving") {
+		t.Errorf("expected management message, got %q", out)
+	}
+	if err := p.run("down", "demo", []string{"10.0.0.9"}); err == nil {
+		t.Errorf("expected unknown member to fail")
+	}
+}
+
+func TestShards(t *testing.T) {
+	p, m, out := testPlugin()
+	if err := p.run("shards", "demo", []string{"Cart"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.reads) != 2 || !strings.HasSuffix(m.reads[0], "/proxy/cluster/shards/Cart") {
+		t.Errorf("expected shards read from every pod, got %v", m.reads)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || !strings.Contains(lines[1], " 1 ") {
+		t.Errorf("unexpected shards table:\n%s", out)
+	}
+}
+
+func TestPodSelector(t *testing.T) {
+	p, _, _ := testPlugin()
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = "demo"
+	akkaCluster.Namespace = "default"
+	akkaCluster.UID = "demo-uid"
+	selector, err := p.podSelector(akkaCluster)
+	if err != nil || selector.MatchLabels["app.kubernetes.io/name"] != "demo" {
+		t.Errorf("expected the selector of the Deployment, got %v, %v", selector, err)
+	}
+
+	// a selector of its own wins
+	akkaCluster.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "demo"}}
+	if selector, err := p.podSelector(akkaCluster); err != nil || selector.MatchLabels["tier"] != "demo" {
+		t.Errorf("expected the selector of the AkkaCluster, got %v, %v", selector, err)
+	}
+
+	// a Deployment of another owner doesn't count
+	akkaCluster.Spec.Selector = nil
+	akkaCluster.UID = "other-uid"
+	if _, err := p.podSelector(akkaCluster); err == nil {
+		t.Errorf("expected an AkkaCluster without selector or Deployment to fail")
+	}
+}
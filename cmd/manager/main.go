@@ -8,18 +8,24 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"k8s.io/client-go/rest"
 
 	"github.com/lightbend/akka-cluster-operator/pkg/apis"
+	operatorconfig "github.com/lightbend/akka-cluster-operator/pkg/config"
 	"github.com/lightbend/akka-cluster-operator/pkg/controller"
+	"github.com/lightbend/akka-cluster-operator/pkg/controller/akkacluster"
+	"github.com/lightbend/akka-cluster-operator/pkg/election"
+	"github.com/lightbend/akka-cluster-operator/pkg/namespaces"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
-	"github.com/operator-framework/operator-sdk/pkg/leader"
 	"github.com/operator-framework/operator-sdk/pkg/log/zap"
 	"github.com/operator-framework/operator-sdk/pkg/metrics"
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
@@ -32,10 +38,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 )
 
-// Change below variables to serve metrics on different host or port.
+// Metrics and health probes are served on the host and ports of the operator configuration.
 var (
-	metricsHost       = "0.0.0.0"
-	metricsPort int32 = 8383
+	metricsHost     string
+	metricsPort     int32
+	healthProbePort int32
 )
 var log = logf.Log.WithName("cmd")
 
@@ -54,6 +61,45 @@ func main() {
 	// controller-runtime)
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 
+	var configFile string
+	pflag.StringVar(&configFile, "config", "",
+		"path of an OperatorConfig file setting the defaults of this installation, or empty for the built-in defaults")
+	var namespaceSelector string
+	pflag.StringVar(&namespaceSelector, "namespace-selector", "",
+		"label selector of the namespaces to watch, like akka-cluster-operator=enabled, in place of WATCH_NAMESPACE")
+	var readAPIAddress string
+	pflag.StringVar(&readAPIAddress, "read-api-address", "",
+		"address to serve the read-only AkkaCluster API on, like :8080, or empty for none")
+	var clusterRBAC bool
+	pflag.BoolVar(&clusterRBAC, "cluster-rbac", false,
+		"generate the ClusterRoles and ClusterRoleBindings of spec.rbac.clusterRules, which needs deploy/cluster_role.yaml")
+
+	// status polling flags take the place of statusPolling in the configuration file
+	var statusPolling akkacluster.StatusPolling
+	defaultPolling := akkacluster.DefaultOptions().StatusPolling
+	pflag.DurationVar(&statusPolling.QuiesceWindow, "status-quiesce-window",
+		defaultPolling.QuiesceWindow, "default quiet time before polling cluster status, and first backoff step")
+	pflag.DurationVar(&statusPolling.MaxBackoff, "status-max-backoff",
+		defaultPolling.MaxBackoff, "default longest backoff between status polls")
+	pflag.DurationVar(&statusPolling.Timeout, "status-timeout",
+		defaultPolling.Timeout, "default timeout of each request to a management endpoint")
+	pflag.DurationVar(&statusPolling.SteadyInterval, "status-steady-interval",
+		defaultPolling.SteadyInterval, "default interval to keep polling status past the longest backoff, or 0 to stop")
+
+	leaderElect := true
+	leaderElection := election.DefaultOptions
+	pflag.BoolVar(&leaderElect, "leader-elect", leaderElect,
+		"elect a leader with a Lease, so standby replicas can take over, when running in a cluster")
+	pflag.StringVar(&leaderElection.Namespace, "leader-election-namespace", "",
+		"namespace of the leader election Lease, by default the operator's own")
+	pflag.StringVar(&leaderElection.ID, "leader-election-id", leaderElection.ID, "name of the leader election Lease")
+	pflag.DurationVar(&leaderElection.LeaseDuration, "leader-election-lease-duration", leaderElection.LeaseDuration,
+		"how long standby replicas wait after the last renewal of the Lease before taking over")
+	pflag.DurationVar(&leaderElection.RenewDeadline, "leader-election-renew-deadline", leaderElection.RenewDeadline,
+		"how long the leader retries renewing the Lease before it gives up leading")
+	pflag.DurationVar(&leaderElection.RetryPeriod, "leader-election-retry-period", leaderElection.RetryPeriod,
+		"how often replicas try to acquire or renew the Lease")
+
 	pflag.Parse()
 
 	// Use a zap logr.Logger implementation. If none of the zap
@@ -68,8 +114,19 @@ func main() {
 
 	printVersion()
 
-	namespace, err := k8sutil.GetWatchNamespace()
+	controllerOptions, err := configure(configFile)
 	if err != nil {
+		log.Error(err, "Failed to load operator configuration")
+		os.Exit(1)
+	}
+	controllerOptions.ReadAPIAddress, controllerOptions.ClusterRBAC = readAPIAddress, clusterRBAC
+	overrideDuration(&controllerOptions.StatusPolling.QuiesceWindow, "status-quiesce-window", statusPolling.QuiesceWindow)
+	overrideDuration(&controllerOptions.StatusPolling.MaxBackoff, "status-max-backoff", statusPolling.MaxBackoff)
+	overrideDuration(&controllerOptions.StatusPolling.Timeout, "status-timeout", statusPolling.Timeout)
+	overrideDuration(&controllerOptions.StatusPolling.SteadyInterval, "status-steady-interval", statusPolling.SteadyInterval)
+
+	namespace, err := k8sutil.GetWatchNamespace()
+	if err != nil && namespaceSelector == "" {
 		log.Error(err, "Failed to get watch namespace")
 		os.Exit(1)
 	}
@@ -83,17 +140,12 @@ func main() {
 
 	ctx := context.TODO()
 
-	// Become the leader before proceeding
-	err = leader.Become(ctx, "akka-cluster-operator-lock")
-	if err != nil {
-		log.Error(err, "")
-		os.Exit(1)
-	}
-
 	// Set default manager options
 	options := manager.Options{
 		Namespace:          namespace,
 		MetricsBindAddress: fmt.Sprintf("%s:%d", metricsHost, metricsPort),
+		// serves /healthz, which fails if the StatusActor is wedged
+		HealthProbeBindAddress: fmt.Sprintf("%s:%d", metricsHost, healthProbePort),
 	}
 
 	// Add support for MultiNamespace set in WATCH_NAMESPACE (e.g ns1,ns2)
@@ -101,10 +153,27 @@ func main() {
 	// Also note that you may face performance issues when using this with a high number of namespaces.
 	// More Info: https://pkg.go.dev/sigs.k8s.io/controller-runtime/pkg/cache#MultiNamespacedCacheBuilder
 	if strings.Contains(namespace, ",") {
+		if clusterRBAC {
+			log.Error(fmt.Errorf("WATCH_NAMESPACE %q", namespace),
+				"--cluster-rbac needs a single watch namespace or --namespace-selector")
+			os.Exit(1)
+		}
 		options.Namespace = ""
 		options.NewCache = cache.MultiNamespacedCacheBuilder(strings.Split(namespace, ","))
 	}
 
+	// Or watch the namespaces with matching labels, following them as they are labelled
+	if namespaceSelector != "" {
+		selector, err := labels.Parse(namespaceSelector)
+		if err != nil {
+			log.Error(err, "Failed to parse namespace selector")
+			os.Exit(1)
+		}
+		log.Info("Watching namespaces by label", "selector", selector.String())
+		options.Namespace = ""
+		options.NewCache = namespaces.LabelSelectedCacheBuilder(selector)
+	}
+
 	// Create a new manager to provide shared dependencies and start components
 	mgr, err := manager.New(cfg, options)
 	if err != nil {
@@ -112,6 +181,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Run controllers on the leader only. Standby replicas keep their caches warm.
+	if leaderElect {
+		mgr, err = electLeader(cfg, mgr, leaderElection)
+		if err != nil {
+			log.Error(err, "Failed to set up leader election")
+			os.Exit(1)
+		}
+	}
+
 	log.Info("Registering Components.")
 
 	// Setup Scheme for all resources
@@ -121,7 +199,7 @@ func main() {
 	}
 
 	// Setup all Controllers
-	if err := controller.AddToManager(mgr); err != nil {
+	if err := controller.AddToManager(mgr, controllerOptions); err != nil {
 		log.Error(err, "")
 		os.Exit(1)
 	}
@@ -137,6 +215,32 @@ func main() {
 	}
 }
 
+// electLeader wraps mgr to run its controllers only while holding the leader election
+// Lease, in the operator namespace unless options name another. Running locally, there is
+// no election and mgr is returned as is.
+func electLeader(cfg *rest.Config, mgr manager.Manager, options election.Options) (manager.Manager, error) {
+	if options.Namespace == "" {
+		namespace, err := k8sutil.GetOperatorNamespace()
+		if errors.Is(err, k8sutil.ErrRunLocal) {
+			log.Info("Skipping leader election; not running in a cluster.")
+			return mgr, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		options.Namespace = namespace
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	elected, err := election.New(mgr, client, options)
+	if err != nil {
+		return nil, err
+	}
+	return elected, nil
+}
+
 func addMetrics(ctx context.Context, cfg *rest.Config) {
 
 	// Get the namespace the operator is currently deployed in.
@@ -174,3 +278,25 @@ func addMetrics(ctx context.Context, cfg *rest.Config) {
 		}
 	}
 }
+
+// configure applies the operator configuration file at path, or the defaults if path is
+// empty, returning the controller options it sets.
+func configure(path string) (akkacluster.Options, error) {
+	c := operatorconfig.Default()
+	if path != "" {
+		var err error
+		if c, err = operatorconfig.Load(path); err != nil {
+			return akkacluster.Options{}, err
+		}
+		log.Info("Loaded operator configuration", "path", path)
+	}
+	metricsHost, metricsPort, healthProbePort = c.MetricsHost, c.MetricsPort, c.HealthProbePort
+	return akkacluster.OptionsFor(c), nil
+}
+
+// overrideDuration sets d to the value of the named flag, if it was given.
+func overrideDuration(d *time.Duration, flag string, value time.Duration) {
+	if pflag.CommandLine.Changed(flag) {
+		*d = value
+	}
+}
@@ -2,6 +2,9 @@ package v1alpha1
 
 import (
 	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -32,10 +35,266 @@ type AkkaClusterManagementStatus struct {
 	OldestPerRole map[string]string                    `json:"oldestPerRole"`
 }
 
+// AkkaClusterSplitBrainSpec configures detection of a split brain, where the members of one
+// AkkaCluster have formed more than one Akka cluster.
+type AkkaClusterSplitBrainSpec struct {
+	// Probes is the number of running members asked in parallel for their view of the
+	// cluster. Views are compared when two or more members are probed.
+	Probes int32 `json:"probes,omitempty"`
+	// Remediate deletes the pods of the minority island once a split brain has lasted
+	// longer than GracePeriodSeconds, so that they restart and join the main cluster.
+	Remediate bool `json:"remediate,omitempty"`
+	// GracePeriodSeconds is how long a split brain may last before it is remediated.
+	// Defaults to 60 seconds.
+	GracePeriodSeconds *int32 `json:"gracePeriodSeconds,omitempty"`
+	// Resolver configures the Akka split brain resolver of the pods, which decides which
+	// side of a partition survives.
+	Resolver *AkkaClusterSplitBrainResolverSpec `json:"resolver,omitempty"`
+}
+
+// AkkaClusterSplitBrainResolverStrategy is an active strategy of the Akka split brain
+// resolver.
+type AkkaClusterSplitBrainResolverStrategy string
+
+const (
+	// LeaseMajority keeps the side of a partition that acquires a Kubernetes Lease, using
+	// the leases.akka.io CRD of Akka Coordination.
+	LeaseMajority AkkaClusterSplitBrainResolverStrategy = "LeaseMajority"
+)
+
+// AkkaClusterSplitBrainResolverSpec configures the Akka split brain resolver. The operator
+// grants the generated Role what the strategy needs, and sets the pods up to use it.
+type AkkaClusterSplitBrainResolverSpec struct {
+	// Strategy is the active strategy. Only LeaseMajority is supported.
+	Strategy AkkaClusterSplitBrainResolverStrategy `json:"strategy"`
+	// LeaseName is the Lease the pods are told to use. Defaults to the AkkaCluster name
+	// followed by -akka-sbr.
+	LeaseName string `json:"leaseName,omitempty"`
+}
+
+// AkkaClusterIsland is one of several Akka clusters formed by members of an AkkaCluster,
+// as seen by the members that were probed.
+type AkkaClusterIsland struct {
+	Leader  string   `json:"leader"`
+	Members []string `json:"members"`
+}
+
+// AkkaClusterAdoptionPolicy says what to do when a generated resource would take the name
+// of an existing resource that the AkkaCluster doesn't control.
+type AkkaClusterAdoptionPolicy string
+
+const (
+	// AdoptNever leaves the existing resource alone and reports a NameCollision condition.
+	AdoptNever AkkaClusterAdoptionPolicy = "Never"
+	// AdoptIfUnowned takes over existing resources that no other controller owns.
+	AdoptIfUnowned AkkaClusterAdoptionPolicy = "IfUnowned"
+)
+
+// AkkaClusterGeneratedResourcesSpec configures the resources generated for an AkkaCluster.
+type AkkaClusterGeneratedResourcesSpec struct {
+	// NamePrefix and NameSuffix are added to the AkkaCluster name to name the generated
+	// Deployment, ServiceAccount, Role and RoleBinding. Once the Deployment exists, they
+	// keep its name.
+	NamePrefix string `json:"namePrefix,omitempty"`
+	NameSuffix string `json:"nameSuffix,omitempty"`
+	// AdoptionPolicy is either Never or IfUnowned. Defaults to Never.
+	AdoptionPolicy AkkaClusterAdoptionPolicy `json:"adoptionPolicy,omitempty"`
+	// BootstrapEnv adds AKKA_CLUSTER_BOOTSTRAP_SERVICE_NAME to each container that doesn't
+	// set it. Defaults to true.
+	BootstrapEnv *bool `json:"bootstrapEnv,omitempty"`
+}
+
+// AkkaClusterRBACSpec configures what the generated ServiceAccount of an AkkaCluster may
+// do, beyond reading pods.
+type AkkaClusterRBACSpec struct {
+	// ExtraRules are added to the generated Role. Like ClusterRules, they are only granted
+	// if the grantable rules of the operator configuration cover them.
+	ExtraRules []rbac.PolicyRule `json:"extraRules,omitempty"`
+	// ClusterRules are granted in every namespace, by a ClusterRole and ClusterRoleBinding
+	// named after the namespace and the AkkaCluster. They are only generated by an operator
+	// running with --cluster-rbac.
+	ClusterRules []rbac.PolicyRule `json:"clusterRules,omitempty"`
+}
+
+// AkkaClusterStatusPollingSpec tunes how the operator polls Akka Management for the status
+// of an AkkaCluster. Unset fields take the operator defaults.
+type AkkaClusterStatusPollingSpec struct {
+	// QuiesceWindow is how long changes must settle before status is polled, and the first
+	// step of the backoff between polls. Defaults to 1s.
+	QuiesceWindow *metav1.Duration `json:"quiesceWindow,omitempty"`
+	// MaxBackoff is the longest wait between polls while status doesn't change. Polling
+	// stops once the backoff passes it, until the next change. Defaults to 60s.
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+	// Timeout bounds each request to a management endpoint. Defaults to 3s.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// SteadyInterval, if set, keeps polling at this interval once the backoff passes
+	// MaxBackoff, rather than stopping.
+	SteadyInterval *metav1.Duration `json:"steadyInterval,omitempty"`
+}
+
+// AkkaClusterTeardownSpec has the members of an AkkaCluster leave the Akka cluster one at
+// a time when the AkkaCluster is deleted, before its pods are removed.
+type AkkaClusterTeardownSpec struct {
+	// Timeout bounds how long deletion waits for members to leave. Defaults to 2m.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// AkkaClusterAutoscalingSpec has the operator set spec.replicas from the load on the
+// members of an AkkaCluster. Each load source recommends enough members to keep the load
+// per member at its target, and the largest recommendation wins.
+type AkkaClusterAutoscalingSpec struct {
+	MinReplicas int32 `json:"minReplicas"`
+	MaxReplicas int32 `json:"maxReplicas"`
+	// Shards scales on the shards hosted by the members, as Akka Management reports them.
+	Shards *AkkaClusterShardsLoadSpec `json:"shards,omitempty"`
+	// Metric scales on a metric the application exposes.
+	Metric *AkkaClusterMetricLoadSpec `json:"metric,omitempty"`
+	// ScaleUpStabilizationSeconds is how long recommendations must stay above the
+	// replicas before scaling up to the lowest of them. Defaults to 0.
+	ScaleUpStabilizationSeconds *int32 `json:"scaleUpStabilizationSeconds,omitempty"`
+	// ScaleDownStabilizationSeconds is how long recommendations must stay below the
+	// replicas before scaling down to the highest of them. Defaults to 300.
+	ScaleDownStabilizationSeconds *int32 `json:"scaleDownStabilizationSeconds,omitempty"`
+	// MaxStep is the most members added or removed by one decision. Defaults to 1.
+	MaxStep *int32 `json:"maxStep,omitempty"`
+}
+
+// AkkaClusterShardsLoadSpec counts the shards of sharded entity types as load.
+type AkkaClusterShardsLoadSpec struct {
+	// EntityTypes are the names of the sharded entity types to count shards of.
+	EntityTypes []string `json:"entityTypes"`
+	// TargetPerMember is the number of shards each member should host.
+	TargetPerMember int32 `json:"targetPerMember"`
+}
+
+// AkkaClusterMetricLoadSpec reads a metric in the Prometheus text format from each member
+// as load.
+type AkkaClusterMetricLoadSpec struct {
+	// Name of the metric, summed over its series and the members.
+	Name string `json:"name"`
+	// Port serves the metrics of a member.
+	Port int32 `json:"port"`
+	// Path of the metrics on Port. Defaults to /metrics.
+	Path string `json:"path,omitempty"`
+	// TargetPerMember is the value of the metric each member should have.
+	TargetPerMember resource.Quantity `json:"targetPerMember"`
+}
+
 // AkkaClusterSpec defines the desired state of AkkaCluster
 // +k8s:openapi-gen=true
 type AkkaClusterSpec struct {
 	apps.DeploymentSpec `json:",inline"`
+	SplitBrain          *AkkaClusterSplitBrainSpec `json:"splitBrain,omitempty"`
+	// IgnoredFields are paths in the generated Deployment, like spec.replicas or
+	// spec.template.spec.containers[*].resources, that other controllers own. They are set
+	// when the Deployment is created, and then left out of drift correction.
+	IgnoredFields      []string                           `json:"ignoredFields,omitempty"`
+	GeneratedResources *AkkaClusterGeneratedResourcesSpec `json:"generatedResources,omitempty"`
+	StatusPolling      *AkkaClusterStatusPollingSpec      `json:"statusPolling,omitempty"`
+	RBAC               *AkkaClusterRBACSpec               `json:"rbac,omitempty"`
+	Autoscaling        *AkkaClusterAutoscalingSpec        `json:"autoscaling,omitempty"`
+	// Teardown, if set, holds deletion until the members have left the Akka cluster.
+	Teardown *AkkaClusterTeardownSpec `json:"teardown,omitempty"`
+	// ClassName names an AkkaClusterClass in the same namespace that this AkkaCluster
+	// inherits its pod template and Akka settings from.
+	ClassName string `json:"className,omitempty"`
+}
+
+// AkkaClusterConditionType is a valid value for AkkaClusterCondition.Type
+type AkkaClusterConditionType string
+
+const (
+	// AkkaClusterSplitBrain means probed members disagree on leader or membership.
+	AkkaClusterSplitBrain AkkaClusterConditionType = "SplitBrain"
+	// AkkaClusterPaused means generated resources are left as they are, not reconciled.
+	AkkaClusterPaused AkkaClusterConditionType = "Paused"
+	// AkkaClusterNameCollision means a generated resource would take the name of an
+	// existing resource that the AkkaCluster doesn't control, so it was left alone.
+	AkkaClusterNameCollision AkkaClusterConditionType = "NameCollision"
+	// AkkaClusterClassNotFound means spec.className names an AkkaClusterClass that doesn't
+	// exist, so generated resources are left as they are until it does.
+	AkkaClusterClassNotFound AkkaClusterConditionType = "ClassNotFound"
+	// AkkaClusterLeaseUnavailable means the split brain resolver needs a Lease, but the
+	// leases.akka.io CRD is not installed, or containers take JAVA_TOOL_OPTIONS from
+	// elsewhere so the resolver options can't be added.
+	AkkaClusterLeaseUnavailable AkkaClusterConditionType = "LeaseUnavailable"
+	// AkkaClusterRulesRefused means spec.rbac asks for rules the operator configuration
+	// doesn't allow AkkaClusters to grant, so they were left out.
+	AkkaClusterRulesRefused AkkaClusterConditionType = "RulesRefused"
+	// AkkaClusterAutoscalingUnsupported means spec.autoscaling is left alone, as the
+	// Kubernetes version can't be relied on to scale down the members that left.
+	AkkaClusterAutoscalingUnsupported AkkaClusterConditionType = "AutoscalingUnsupported"
+	// AkkaClusterNameChangeRefused means a changed name prefix or suffix was not applied,
+	// as the Deployment of the AkkaCluster already exists under the old name.
+	AkkaClusterNameChangeRefused AkkaClusterConditionType = "NameChangeRefused"
+)
+
+// PausedAnnotation set to "true" on an AkkaCluster stops the operator from creating or
+// patching generated resources, so they can be edited by hand. Status is still reported.
+// This is an annotation since spec.paused already pauses rollout of the Deployment.
+const PausedAnnotation = "app.lightbend.com/paused"
+
+// AdoptDeploymentAnnotation names an existing Deployment for a new AkkaCluster to take over,
+// along with its RBAC. The AkkaCluster spec is derived from the Deployment so that the pod
+// template, and with it the running pods, stay as they are. The annotation is removed once
+// the spec is derived.
+const AdoptDeploymentAnnotation = "app.lightbend.com/adopt-deployment"
+
+// AkkaClusterCondition describes the state of an AkkaCluster at a certain point.
+type AkkaClusterCondition struct {
+	Type               AkkaClusterConditionType `json:"type"`
+	Status             corev1.ConditionStatus   `json:"status"`
+	LastTransitionTime metav1.Time              `json:"lastTransitionTime,omitempty"`
+	Reason             string                   `json:"reason,omitempty"`
+	Message            string                   `json:"message,omitempty"`
+}
+
+// AkkaClusterLoadStatus is the load on the Up members of an AkkaCluster, for autoscaling.
+type AkkaClusterLoadStatus struct {
+	// Members is the number of members the load was read from.
+	Members int32 `json:"members"`
+	// Shards is the number of shards hosted, if spec.autoscaling.shards is set.
+	Shards *int32 `json:"shards,omitempty"`
+	// Metric is the sum of the metric, if spec.autoscaling.metric is set.
+	Metric *resource.Quantity `json:"metric,omitempty"`
+}
+
+// AkkaClusterScaleRecommendation is the number of replicas the load called for, from Time
+// until the next recommendation.
+type AkkaClusterScaleRecommendation struct {
+	Time     metav1.Time `json:"time"`
+	Replicas int32       `json:"replicas"`
+}
+
+// AkkaClusterScaleDecision is a change of spec.replicas made by the autoscaler.
+type AkkaClusterScaleDecision struct {
+	Time   metav1.Time `json:"time"`
+	From   int32       `json:"from"`
+	To     int32       `json:"to"`
+	Reason string      `json:"reason"`
+}
+
+// AkkaClusterScaleDownStatus is a scale down waiting for members to leave the Akka cluster
+// before spec.replicas is lowered.
+type AkkaClusterScaleDownStatus struct {
+	Replicas int32       `json:"replicas"`
+	Members  []string    `json:"members"`
+	Started  metav1.Time `json:"started"`
+	Reason   string      `json:"reason"`
+}
+
+// AkkaClusterAutoscalingStatus records the recommendations and decisions of the
+// autoscaler.
+type AkkaClusterAutoscalingStatus struct {
+	// DesiredReplicas is the last recommendation, before stabilization and MaxStep.
+	DesiredReplicas int32        `json:"desiredReplicas"`
+	LastScaleTime   *metav1.Time `json:"lastScaleTime,omitempty"`
+	// Recommendations are those still within a stabilization window, oldest first.
+	Recommendations []AkkaClusterScaleRecommendation `json:"recommendations,omitempty"`
+	// ScaleDown is set while members leave.
+	ScaleDown *AkkaClusterScaleDownStatus `json:"scaleDown,omitempty"`
+	// Decisions are the most recent changes of spec.replicas, oldest first.
+	Decisions []AkkaClusterScaleDecision `json:"decisions,omitempty"`
 }
 
 // AkkaClusterStatus defines the observed state of AkkaCluster
@@ -45,6 +304,14 @@ type AkkaClusterStatus struct {
 	ManagementPort int32                       `json:"managementPort"`
 	LastUpdate     metav1.Time                 `json:"lastUpdate"`
 	Cluster        AkkaClusterManagementStatus `json:"cluster"`
+	Conditions     []AkkaClusterCondition      `json:"conditions,omitempty"`
+	Islands        []AkkaClusterIsland         `json:"islands,omitempty"`
+	// LeaseHolder is the member holding the Lease of the split brain resolver, if any.
+	LeaseHolder string `json:"leaseHolder,omitempty"`
+	// Load is read by the operator for spec.autoscaling.
+	Load *AkkaClusterLoadStatus `json:"load,omitempty"`
+	// Autoscaling is set by the operator for spec.autoscaling.
+	Autoscaling *AkkaClusterAutoscalingStatus `json:"autoscaling,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -55,8 +322,8 @@ type AkkaCluster struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec   apps.DeploymentSpec `json:"spec,omitempty"`
-	Status *AkkaClusterStatus  `json:"status,omitempty"`
+	Spec   AkkaClusterSpec    `json:"spec,omitempty"`
+	Status *AkkaClusterStatus `json:"status,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,44 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AkkaClusterClassSpec holds the settings shared by AkkaClusters of a class. Fields set on
+// an AkkaCluster take precedence, and the pod template is merged like a strategic merge
+// patch, so containers, env vars, ports and volumes are merged by name.
+// +k8s:openapi-gen=true
+type AkkaClusterClassSpec struct {
+	// Template is the pod template fragment, like JVM options in env, resources, probes
+	// and tolerations, that AkkaClusters of this class start from.
+	Template           corev1.PodTemplateSpec             `json:"template,omitempty"`
+	SplitBrain         *AkkaClusterSplitBrainSpec         `json:"splitBrain,omitempty"`
+	GeneratedResources *AkkaClusterGeneratedResourcesSpec `json:"generatedResources,omitempty"`
+	StatusPolling      *AkkaClusterStatusPollingSpec      `json:"statusPolling,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AkkaClusterClass is a reusable template for AkkaClusters in its namespace, which
+// reference it by name in spec.className.
+// +k8s:openapi-gen=true
+type AkkaClusterClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AkkaClusterClassSpec `json:"spec,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AkkaClusterClassList contains a list of AkkaClusterClass
+type AkkaClusterClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AkkaClusterClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AkkaClusterClass{}, &AkkaClusterClassList{})
+}
@@ -5,6 +5,8 @@
 package v1alpha1
 
 import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -40,6 +42,238 @@ func (in *AkkaCluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterAutoscalingSpec) DeepCopyInto(out *AkkaClusterAutoscalingSpec) {
+	*out = *in
+	if in.Shards != nil {
+		in, out := &in.Shards, &out.Shards
+		*out = new(AkkaClusterShardsLoadSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Metric != nil {
+		in, out := &in.Metric, &out.Metric
+		*out = new(AkkaClusterMetricLoadSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScaleUpStabilizationSeconds != nil {
+		in, out := &in.ScaleUpStabilizationSeconds, &out.ScaleUpStabilizationSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ScaleDownStabilizationSeconds != nil {
+		in, out := &in.ScaleDownStabilizationSeconds, &out.ScaleDownStabilizationSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxStep != nil {
+		in, out := &in.MaxStep, &out.MaxStep
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterAutoscalingSpec.
+func (in *AkkaClusterAutoscalingSpec) DeepCopy() *AkkaClusterAutoscalingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterAutoscalingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterAutoscalingStatus) DeepCopyInto(out *AkkaClusterAutoscalingStatus) {
+	*out = *in
+	if in.LastScaleTime != nil {
+		in, out := &in.LastScaleTime, &out.LastScaleTime
+		*out = new(v1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Recommendations != nil {
+		in, out := &in.Recommendations, &out.Recommendations
+		*out = make([]AkkaClusterScaleRecommendation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ScaleDown != nil {
+		in, out := &in.ScaleDown, &out.ScaleDown
+		*out = new(AkkaClusterScaleDownStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Decisions != nil {
+		in, out := &in.Decisions, &out.Decisions
+		*out = make([]AkkaClusterScaleDecision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterAutoscalingStatus.
+func (in *AkkaClusterAutoscalingStatus) DeepCopy() *AkkaClusterAutoscalingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterAutoscalingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterClass) DeepCopyInto(out *AkkaClusterClass) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterClass.
+func (in *AkkaClusterClass) DeepCopy() *AkkaClusterClass {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterClass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkkaClusterClass) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterClassList) DeepCopyInto(out *AkkaClusterClassList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AkkaClusterClass, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterClassList.
+func (in *AkkaClusterClassList) DeepCopy() *AkkaClusterClassList {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterClassList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AkkaClusterClassList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterClassSpec) DeepCopyInto(out *AkkaClusterClassSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+	if in.SplitBrain != nil {
+		in, out := &in.SplitBrain, &out.SplitBrain
+		*out = new(AkkaClusterSplitBrainSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GeneratedResources != nil {
+		in, out := &in.GeneratedResources, &out.GeneratedResources
+		*out = new(AkkaClusterGeneratedResourcesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StatusPolling != nil {
+		in, out := &in.StatusPolling, &out.StatusPolling
+		*out = new(AkkaClusterStatusPollingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterClassSpec.
+func (in *AkkaClusterClassSpec) DeepCopy() *AkkaClusterClassSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterClassSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterCondition) DeepCopyInto(out *AkkaClusterCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterCondition.
+func (in *AkkaClusterCondition) DeepCopy() *AkkaClusterCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterGeneratedResourcesSpec) DeepCopyInto(out *AkkaClusterGeneratedResourcesSpec) {
+	*out = *in
+	if in.BootstrapEnv != nil {
+		in, out := &in.BootstrapEnv, &out.BootstrapEnv
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterGeneratedResourcesSpec.
+func (in *AkkaClusterGeneratedResourcesSpec) DeepCopy() *AkkaClusterGeneratedResourcesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterGeneratedResourcesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterIsland) DeepCopyInto(out *AkkaClusterIsland) {
+	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterIsland.
+func (in *AkkaClusterIsland) DeepCopy() *AkkaClusterIsland {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterIsland)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AkkaClusterList) DeepCopyInto(out *AkkaClusterList) {
 	*out = *in
@@ -73,6 +307,32 @@ func (in *AkkaClusterList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterLoadStatus) DeepCopyInto(out *AkkaClusterLoadStatus) {
+	*out = *in
+	if in.Shards != nil {
+		in, out := &in.Shards, &out.Shards
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Metric != nil {
+		in, out := &in.Metric, &out.Metric
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterLoadStatus.
+func (in *AkkaClusterLoadStatus) DeepCopy() *AkkaClusterLoadStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterLoadStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AkkaClusterManagementStatus) DeepCopyInto(out *AkkaClusterManagementStatus) {
 	*out = *in
@@ -131,10 +391,169 @@ func (in *AkkaClusterMemberStatus) DeepCopy() *AkkaClusterMemberStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterMetricLoadSpec) DeepCopyInto(out *AkkaClusterMetricLoadSpec) {
+	*out = *in
+	out.TargetPerMember = in.TargetPerMember.DeepCopy()
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterMetricLoadSpec.
+func (in *AkkaClusterMetricLoadSpec) DeepCopy() *AkkaClusterMetricLoadSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterMetricLoadSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterRBACSpec) DeepCopyInto(out *AkkaClusterRBACSpec) {
+	*out = *in
+	if in.ExtraRules != nil {
+		in, out := &in.ExtraRules, &out.ExtraRules
+		*out = make([]rbacv1.PolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ClusterRules != nil {
+		in, out := &in.ClusterRules, &out.ClusterRules
+		*out = make([]rbacv1.PolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterRBACSpec.
+func (in *AkkaClusterRBACSpec) DeepCopy() *AkkaClusterRBACSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterRBACSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterScaleDecision) DeepCopyInto(out *AkkaClusterScaleDecision) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterScaleDecision.
+func (in *AkkaClusterScaleDecision) DeepCopy() *AkkaClusterScaleDecision {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterScaleDecision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterScaleDownStatus) DeepCopyInto(out *AkkaClusterScaleDownStatus) {
+	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Started.DeepCopyInto(&out.Started)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterScaleDownStatus.
+func (in *AkkaClusterScaleDownStatus) DeepCopy() *AkkaClusterScaleDownStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterScaleDownStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterScaleRecommendation) DeepCopyInto(out *AkkaClusterScaleRecommendation) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterScaleRecommendation.
+func (in *AkkaClusterScaleRecommendation) DeepCopy() *AkkaClusterScaleRecommendation {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterScaleRecommendation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterShardsLoadSpec) DeepCopyInto(out *AkkaClusterShardsLoadSpec) {
+	*out = *in
+	if in.EntityTypes != nil {
+		in, out := &in.EntityTypes, &out.EntityTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterShardsLoadSpec.
+func (in *AkkaClusterShardsLoadSpec) DeepCopy() *AkkaClusterShardsLoadSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterShardsLoadSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AkkaClusterSpec) DeepCopyInto(out *AkkaClusterSpec) {
 	*out = *in
 	in.DeploymentSpec.DeepCopyInto(&out.DeploymentSpec)
+	if in.SplitBrain != nil {
+		in, out := &in.SplitBrain, &out.SplitBrain
+		*out = new(AkkaClusterSplitBrainSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IgnoredFields != nil {
+		in, out := &in.IgnoredFields, &out.IgnoredFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GeneratedResources != nil {
+		in, out := &in.GeneratedResources, &out.GeneratedResources
+		*out = new(AkkaClusterGeneratedResourcesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StatusPolling != nil {
+		in, out := &in.StatusPolling, &out.StatusPolling
+		*out = new(AkkaClusterStatusPollingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RBAC != nil {
+		in, out := &in.RBAC, &out.RBAC
+		*out = new(AkkaClusterRBACSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(AkkaClusterAutoscalingSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Teardown != nil {
+		in, out := &in.Teardown, &out.Teardown
+		*out = new(AkkaClusterTeardownSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -148,11 +567,77 @@ func (in *AkkaClusterSpec) DeepCopy() *AkkaClusterSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterSplitBrainResolverSpec) DeepCopyInto(out *AkkaClusterSplitBrainResolverSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterSplitBrainResolverSpec.
+func (in *AkkaClusterSplitBrainResolverSpec) DeepCopy() *AkkaClusterSplitBrainResolverSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterSplitBrainResolverSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterSplitBrainSpec) DeepCopyInto(out *AkkaClusterSplitBrainSpec) {
+	*out = *in
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Resolver != nil {
+		in, out := &in.Resolver, &out.Resolver
+		*out = new(AkkaClusterSplitBrainResolverSpec)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterSplitBrainSpec.
+func (in *AkkaClusterSplitBrainSpec) DeepCopy() *AkkaClusterSplitBrainSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterSplitBrainSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AkkaClusterStatus) DeepCopyInto(out *AkkaClusterStatus) {
 	*out = *in
 	in.LastUpdate.DeepCopyInto(&out.LastUpdate)
 	in.Cluster.DeepCopyInto(&out.Cluster)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]AkkaClusterCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Islands != nil {
+		in, out := &in.Islands, &out.Islands
+		*out = make([]AkkaClusterIsland, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Load != nil {
+		in, out := &in.Load, &out.Load
+		*out = new(AkkaClusterLoadStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(AkkaClusterAutoscalingStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -166,6 +651,63 @@ func (in *AkkaClusterStatus) DeepCopy() *AkkaClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterStatusPollingSpec) DeepCopyInto(out *AkkaClusterStatusPollingSpec) {
+	*out = *in
+	if in.QuiesceWindow != nil {
+		in, out := &in.QuiesceWindow, &out.QuiesceWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxBackoff != nil {
+		in, out := &in.MaxBackoff, &out.MaxBackoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.SteadyInterval != nil {
+		in, out := &in.SteadyInterval, &out.SteadyInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterStatusPollingSpec.
+func (in *AkkaClusterStatusPollingSpec) DeepCopy() *AkkaClusterStatusPollingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterStatusPollingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AkkaClusterTeardownSpec) DeepCopyInto(out *AkkaClusterTeardownSpec) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AkkaClusterTeardownSpec.
+func (in *AkkaClusterTeardownSpec) DeepCopy() *AkkaClusterTeardownSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AkkaClusterTeardownSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AkkaClusterUnreachableMemberStatus) DeepCopyInto(out *AkkaClusterUnreachableMemberStatus) {
 	*out = *in
@@ -11,9 +11,11 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
-		"./pkg/apis/app/v1alpha1.AkkaCluster":       schema_pkg_apis_app_v1alpha1_AkkaCluster(ref),
-		"./pkg/apis/app/v1alpha1.AkkaClusterSpec":   schema_pkg_apis_app_v1alpha1_AkkaClusterSpec(ref),
-		"./pkg/apis/app/v1alpha1.AkkaClusterStatus": schema_pkg_apis_app_v1alpha1_AkkaClusterStatus(ref),
+		"./pkg/apis/app/v1alpha1.AkkaCluster":          schema_pkg_apis_app_v1alpha1_AkkaCluster(ref),
+		"./pkg/apis/app/v1alpha1.AkkaClusterClass":     schema_pkg_apis_app_v1alpha1_AkkaClusterClass(ref),
+		"./pkg/apis/app/v1alpha1.AkkaClusterClassSpec": schema_pkg_apis_app_v1alpha1_AkkaClusterClassSpec(ref),
+		"./pkg/apis/app/v1alpha1.AkkaClusterSpec":      schema_pkg_apis_app_v1alpha1_AkkaClusterSpec(ref),
+		"./pkg/apis/app/v1alpha1.AkkaClusterStatus":    schema_pkg_apis_app_v1alpha1_AkkaClusterStatus(ref),
 	}
 }
 
@@ -45,7 +47,7 @@ func schema_pkg_apis_app_v1alpha1_AkkaCluster(ref common.ReferenceCallback) comm
 					},
 					"spec": {
 						SchemaProps: spec.SchemaProps{
-							Ref: ref("k8s.io/api/apps/v1.DeploymentSpec"),
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterSpec"),
 						},
 					},
 					"status": {
@@ -57,7 +59,82 @@ func schema_pkg_apis_app_v1alpha1_AkkaCluster(ref common.ReferenceCallback) comm
 			},
 		},
 		Dependencies: []string{
-			"./pkg/apis/app/v1alpha1.AkkaClusterStatus", "k8s.io/api/apps/v1.DeploymentSpec", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+			"./pkg/apis/app/v1alpha1.AkkaClusterSpec", "./pkg/apis/app/v1alpha1.AkkaClusterStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_app_v1alpha1_AkkaClusterClass(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "AkkaClusterClass is a reusable template for AkkaClusters in its namespace, which reference it by name in spec.className.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterClassSpec"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"./pkg/apis/app/v1alpha1.AkkaClusterClassSpec", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_app_v1alpha1_AkkaClusterClassSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "AkkaClusterClassSpec holds the settings shared by AkkaClusters of a class. Fields set on an AkkaCluster take precedence, and the pod template is merged like a strategic merge patch, so containers, env vars, ports and volumes are merged by name.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"template": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Template is the pod template fragment, like JVM options in env, resources, probes and tolerations, that AkkaClusters of this class start from.",
+							Ref:         ref("k8s.io/api/core/v1.PodTemplateSpec"),
+						},
+					},
+					"splitBrain": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterSplitBrainSpec"),
+						},
+					},
+					"generatedResources": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterGeneratedResourcesSpec"),
+						},
+					},
+					"statusPolling": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterStatusPollingSpec"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"./pkg/apis/app/v1alpha1.AkkaClusterGeneratedResourcesSpec", "./pkg/apis/app/v1alpha1.AkkaClusterSplitBrainSpec", "./pkg/apis/app/v1alpha1.AkkaClusterStatusPollingSpec", "k8s.io/api/core/v1.PodTemplateSpec"},
 	}
 }
 
@@ -126,12 +203,64 @@ func schema_pkg_apis_app_v1alpha1_AkkaClusterSpec(ref common.ReferenceCallback)
 							Format:      "int32",
 						},
 					},
+					"splitBrain": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterSplitBrainSpec"),
+						},
+					},
+					"generatedResources": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterGeneratedResourcesSpec"),
+						},
+					},
+					"statusPolling": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterStatusPollingSpec"),
+						},
+					},
+					"rbac": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterRBACSpec"),
+						},
+					},
+					"autoscaling": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterAutoscalingSpec"),
+						},
+					},
+					"teardown": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Teardown, if set, holds deletion until the members have left the Akka cluster.",
+							Ref:         ref("./pkg/apis/app/v1alpha1.AkkaClusterTeardownSpec"),
+						},
+					},
+					"ignoredFields": {
+						SchemaProps: spec.SchemaProps{
+							Description: "IgnoredFields are paths in the generated Deployment, like spec.replicas or spec.template.spec.containers[*].resources, that other controllers own. They are set when the Deployment is created, and then left out of drift correction.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Type:   []string{"string"},
+										Format: "",
+									},
+								},
+							},
+						},
+					},
+					"className": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ClassName names an AkkaClusterClass in the same namespace that this AkkaCluster inherits its pod template and Akka settings from.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
 				},
 				Required: []string{"selector", "template"},
 			},
 		},
 		Dependencies: []string{
-			"k8s.io/api/apps/v1.DeploymentStrategy", "k8s.io/api/core/v1.PodTemplateSpec", "k8s.io/apimachinery/pkg/apis/meta/v1.LabelSelector"},
+			"./pkg/apis/app/v1alpha1.AkkaClusterAutoscalingSpec", "./pkg/apis/app/v1alpha1.AkkaClusterGeneratedResourcesSpec", "./pkg/apis/app/v1alpha1.AkkaClusterRBACSpec", "./pkg/apis/app/v1alpha1.AkkaClusterSplitBrainSpec", "./pkg/apis/app/v1alpha1.AkkaClusterStatusPollingSpec", "./pkg/apis/app/v1alpha1.AkkaClusterTeardownSpec", "k8s.io/api/apps/v1.DeploymentStrategy", "k8s.io/api/core/v1.PodTemplateSpec", "k8s.io/apimachinery/pkg/apis/meta/v1.LabelSelector"},
 	}
 }
 
@@ -164,11 +293,54 @@ func schema_pkg_apis_app_v1alpha1_AkkaClusterStatus(ref common.ReferenceCallback
 							Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterManagementStatus"),
 						},
 					},
+					"conditions": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterCondition"),
+									},
+								},
+							},
+						},
+					},
+					"islands": {
+						SchemaProps: spec.SchemaProps{
+							Type: []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("./pkg/apis/app/v1alpha1.AkkaClusterIsland"),
+									},
+								},
+							},
+						},
+					},
+					"leaseHolder": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LeaseHolder is the member holding the Lease of the split brain resolver, if any.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"load": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Load is read by the operator for spec.autoscaling.",
+							Ref:         ref("./pkg/apis/app/v1alpha1.AkkaClusterLoadStatus"),
+						},
+					},
+					"autoscaling": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Autoscaling is set by the operator for spec.autoscaling.",
+							Ref:         ref("./pkg/apis/app/v1alpha1.AkkaClusterAutoscalingStatus"),
+						},
+					},
 				},
 				Required: []string{"managementHost", "managementPort", "lastUpdate", "cluster"},
 			},
 		},
 		Dependencies: []string{
-			"./pkg/apis/app/v1alpha1.AkkaClusterManagementStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+			"./pkg/apis/app/v1alpha1.AkkaClusterAutoscalingStatus", "./pkg/apis/app/v1alpha1.AkkaClusterCondition", "./pkg/apis/app/v1alpha1.AkkaClusterIsland", "./pkg/apis/app/v1alpha1.AkkaClusterLoadStatus", "./pkg/apis/app/v1alpha1.AkkaClusterManagementStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
 	}
 }
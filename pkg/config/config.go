@@ -0,0 +1,253 @@
+// Package config loads the operator configuration file, which sets the defaults of an
+// installation: where the operator serves metrics and health probes, what it fills in on
+// the Deployments it generates, how it finds the Akka Management endpoint of a pod, and
+// how often it polls status.
+//
+// The file is versioned like a Kubernetes object, and unknown fields are errors, so a typo
+// fails at startup rather than going unnoticed:
+//
+//	apiVersion: app.lightbend.com/v1alpha1
+//	kind: OperatorConfig
+//	metricsPort: 8383
+//	deployment:
+//	  selectorKey: app.kubernetes.io/name
+//	management:
+//	  portName: akka-mgmt
+//	statusPolling:
+//	  maxBackoff: 2m
+//	rbac:
+//	  grantableRules:
+//	    - apiGroups: [""]
+//	      resources: ["configmaps"]
+//	      verbs: ["get", "list", "watch"]
+//
+// Fields left out keep their defaults.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
+)
+
+const (
+	// APIVersion and Kind identify an operator configuration file.
+	APIVersion = "app.lightbend.com/v1alpha1"
+	Kind       = "OperatorConfig"
+)
+
+// OperatorConfig is the operator configuration file.
+type OperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+	// MetricsHost is the host metrics and health probes are served on.
+	MetricsHost string `json:"metricsHost,omitempty"`
+	// MetricsPort serves metrics.
+	MetricsPort int32 `json:"metricsPort,omitempty"`
+	// HealthProbePort serves /healthz.
+	HealthProbePort int32              `json:"healthProbePort,omitempty"`
+	Deployment      DeploymentDefaults `json:"deployment,omitempty"`
+	Management      ManagementDefaults `json:"management,omitempty"`
+	RBAC            RBACLimits         `json:"rbac,omitempty"`
+	// StatusPolling is the polling policy of AkkaClusters that leave spec.statusPolling, or
+	// some of it, out.
+	StatusPolling appv1alpha1.AkkaClusterStatusPollingSpec `json:"statusPolling,omitempty"`
+}
+
+// DeploymentDefaults are filled in on generated Deployments when an AkkaCluster leaves them
+// out.
+type DeploymentDefaults struct {
+	// SelectorKey is the pod label that selects the pods of an AkkaCluster by its name.
+	SelectorKey string `json:"selectorKey,omitempty"`
+	// Strategy replaces pods of a cluster.
+	Strategy *appsv1.DeploymentStrategy `json:"strategy,omitempty"`
+	// BootstrapServiceNameEnv is the env var that tells Akka Management Cluster Bootstrap
+	// the service name of the cluster.
+	BootstrapServiceNameEnv string `json:"bootstrapServiceNameEnv,omitempty"`
+}
+
+// ManagementDefaults find the Akka Management endpoint of a pod.
+type ManagementDefaults struct {
+	// PortName is the name of the container port of the endpoint.
+	PortName string `json:"portName,omitempty"`
+	// Port is used when no container port has PortName.
+	Port int32 `json:"port,omitempty"`
+}
+
+// RBACLimits bound what AkkaClusters may grant the ServiceAccounts generated for them.
+type RBACLimits struct {
+	// GrantableRules cover every rule that spec.rbac.extraRules and spec.rbac.clusterRules
+	// may ask for. Rules they don't cover are refused. Empty refuses all of them.
+	GrantableRules []rbac.PolicyRule `json:"grantableRules,omitempty"`
+}
+
+// Default returns the configuration of an operator without a configuration file.
+func Default() *OperatorConfig {
+	config := &OperatorConfig{}
+	config.setDefaults()
+	return config
+}
+
+// setDefaults fills in fields left out.
+func (c *OperatorConfig) setDefaults() {
+	if c.APIVersion == "" && c.Kind == "" {
+		c.APIVersion, c.Kind = APIVersion, Kind
+	}
+	if c.MetricsHost == "" {
+		c.MetricsHost = "0.0.0.0"
+	}
+	if c.MetricsPort == 0 {
+		c.MetricsPort = 8383
+	}
+	if c.HealthProbePort == 0 {
+		c.HealthProbePort = 8081
+	}
+	if c.Deployment.SelectorKey == "" {
+		c.Deployment.SelectorKey = "app"
+	}
+	if c.Deployment.Strategy == nil {
+		maxSurge, maxUnavailable := intstr.FromInt(1), intstr.FromInt(0)
+		c.Deployment.Strategy = &appsv1.DeploymentStrategy{
+			Type: appsv1.RollingUpdateDeploymentStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateDeployment{
+				MaxSurge:       &maxSurge,
+				MaxUnavailable: &maxUnavailable,
+			},
+		}
+	}
+	if c.Deployment.BootstrapServiceNameEnv == "" {
+		c.Deployment.BootstrapServiceNameEnv = "AKKA_CLUSTER_BOOTSTRAP_SERVICE_NAME"
+	}
+	if c.Management.PortName == "" {
+		c.Management.PortName = management.DefaultPortName
+	}
+	if c.Management.Port == 0 {
+		c.Management.Port = management.DefaultPort
+	}
+	defaultDuration(&c.StatusPolling.QuiesceWindow, time.Second)
+	defaultDuration(&c.StatusPolling.MaxBackoff, 60*time.Second)
+	defaultDuration(&c.StatusPolling.Timeout, management.DefaultTimeout)
+	defaultDuration(&c.StatusPolling.SteadyInterval, 0)
+}
+
+// defaultDuration sets a duration left out.
+func defaultDuration(d **metav1.Duration, value time.Duration) {
+	if *d == nil {
+		*d = &metav1.Duration{Duration: value}
+	}
+}
+
+// Load reads, defaults and validates the configuration file at path, in YAML or JSON.
+func Load(path string) (*OperatorConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Parse reads, defaults and validates a configuration in YAML or JSON.
+func Parse(data []byte) (*OperatorConfig, error) {
+	data, err := yaml.ToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	config := &OperatorConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, err
+	}
+	if config.APIVersion != APIVersion || config.Kind != Kind {
+		return nil, fmt.Errorf("expected apiVersion %s and kind %s, but got %q and %q",
+			APIVersion, Kind, config.APIVersion, config.Kind)
+	}
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks a defaulted configuration, returning all problems found.
+func (c *OperatorConfig) Validate() error {
+	problems := []string{}
+	ports := []struct {
+		field string
+		port  int32
+	}{
+		{"metricsPort", c.MetricsPort},
+		{"healthProbePort", c.HealthProbePort},
+		{"management.port", c.Management.Port},
+	}
+	for _, port := range ports {
+		for _, msg := range validation.IsValidPortNum(int(port.port)) {
+			problems = append(problems, port.field+": "+msg)
+		}
+	}
+	if c.MetricsPort == c.HealthProbePort {
+		problems = append(problems, "metricsPort and healthProbePort must differ")
+	}
+	for _, msg := range validation.IsQualifiedName(c.Deployment.SelectorKey) {
+		problems = append(problems, "deployment.selectorKey: "+msg)
+	}
+	switch strategy := c.Deployment.Strategy; strategy.Type {
+	case appsv1.RollingUpdateDeploymentStrategyType:
+	case appsv1.RecreateDeploymentStrategyType:
+		if strategy.RollingUpdate != nil {
+			problems = append(problems, "deployment.strategy: rollingUpdate may not be set with type Recreate")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("deployment.strategy: unknown type %q", strategy.Type))
+	}
+	for _, msg := range validation.IsEnvVarName(c.Deployment.BootstrapServiceNameEnv) {
+		problems = append(problems, "deployment.bootstrapServiceNameEnv: "+msg)
+	}
+	for _, msg := range validation.IsValidPortName(c.Management.PortName) {
+		problems = append(problems, "management.portName: "+msg)
+	}
+	durations := []struct {
+		field    string
+		duration *metav1.Duration
+	}{
+		{"statusPolling.quiesceWindow", c.StatusPolling.QuiesceWindow},
+		{"statusPolling.maxBackoff", c.StatusPolling.MaxBackoff},
+		{"statusPolling.timeout", c.StatusPolling.Timeout},
+	}
+	for _, d := range durations {
+		if d.duration.Duration <= 0 {
+			problems = append(problems, d.field+": must be positive")
+		}
+	}
+	if c.StatusPolling.SteadyInterval.Duration < 0 {
+		problems = append(problems, "statusPolling.steadyInterval: must not be negative")
+	}
+	for i, rule := range c.RBAC.GrantableRules {
+		if len(rule.Verbs) == 0 {
+			problems = append(problems, fmt.Sprintf("rbac.grantableRules[%d]: verbs must be set", i))
+		}
+		if len(rule.Resources) == 0 && len(rule.NonResourceURLs) == 0 {
+			problems = append(problems, fmt.Sprintf("rbac.grantableRules[%d]: resources or nonResourceURLs must be set", i))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid operator configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`
+apiVersion: app.lightbend.com/v1alpha1
+kind: OperatorConfig
+metricsPort: 9090
+deployment:
+  selectorKey: app.kubernetes.io/name
+  strategy:
+    type: Recreate
+management:
+  portName: akka-mgmt
+statusPolling:
+  maxBackoff: 2m
+rbac:
+  grantableRules:
+    - apiGroups: [""]
+      resources: ["configmaps"]
+      verbs: ["get"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MetricsPort != 9090 || config.Deployment.SelectorKey != "app.kubernetes.io/name" ||
+		config.Deployment.Strategy.Type != appsv1.RecreateDeploymentStrategyType || config.Management.PortName != "akka-mgmt" ||
+		len(config.RBAC.GrantableRules) != 1 || config.StatusPolling.MaxBackoff.Duration != 2*time.Minute {
+		t.Errorf("expected fields set in the file, but got %+v", config)
+	}
+	defaults := Default()
+	if config.HealthProbePort != defaults.HealthProbePort || config.Management.Port != defaults.Management.Port ||
+		config.Deployment.BootstrapServiceNameEnv != defaults.Deployment.BootstrapServiceNameEnv ||
+		*config.StatusPolling.QuiesceWindow != *defaults.StatusPolling.QuiesceWindow {
+		t.Errorf("expected defaults for fields left out, but got %+v", config)
+	}
+	if err := defaults.Validate(); err != nil {
+		t.Errorf("expected valid defaults, but got %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	header := "apiVersion: app.lightbend.com/v1alpha1\nkind: OperatorConfig\n"
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"wrong kind", "apiVersion: app.lightbend.com/v1alpha1\nkind: AkkaCluster\n", "expected apiVersion"},
+		{"no kind", "metricsPort: 9090\n", "expected apiVersion"},
+		{"unknown field", header + "metricPort: 9090\n", "unknown field"},
+		{"port out of range", header + "management:\n  port: 70000\n", "management.port"},
+		{"same ports", header + "metricsPort: 8081\n", "must differ"},
+		{"bad selector key", header + "deployment:\n  selectorKey: not a key\n", "deployment.selectorKey"},
+		{"unknown strategy", header + "deployment:\n  strategy:\n    type: BlueGreen\n", "unknown type"},
+		{"recreate with rolling update", header +
+			"deployment:\n  strategy:\n    type: Recreate\n    rollingUpdate:\n      maxSurge: 1\n", "may not be set"},
+		{"bad env var", header + "deployment:\n  bootstrapServiceNameEnv: 1BAD\n", "deployment.bootstrapServiceNameEnv"},
+		{"rule without verbs", header + "rbac:\n  grantableRules:\n    - resources: [pods]\n", "verbs must be set"},
+		{"rule without resources", header + "rbac:\n  grantableRules:\n    - verbs: [get]\n", "resources or nonResourceURLs"},
+		{"bad port name", header + "management:\n  portName: management-endpoint\n", "management.portName"},
+		{"zero timeout", header + "statusPolling:\n  timeout: 0s\n", "statusPolling.timeout"},
+		{"negative steady interval", header + "statusPolling:\n  steadyInterval: -1s\n", "statusPolling.steadyInterval"},
+	}
+	for _, test := range tests {
+		_, err := Parse([]byte(test.config))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: expected error containing %q, but got %v", test.name, test.want, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package akkacluster
+
+import (
+	"context"
+	"encoding/json"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// On adopting an existing Deployment:
+//
+// Applications deployed as plain Deployments can move under an AkkaCluster without a
+// restart. A new AkkaCluster, named so that its generated names match the Deployment, is
+// annotated with the AdoptDeploymentAnnotation. adoptDeployment then copies the Deployment
+// spec into the AkkaCluster, and turns off any default that would change the pod template:
+// the bootstrap env is left as the Deployment has it, and a ServiceAccount of the generated
+// name is regenerated, along with its Role and RoleBinding, under that very name. Rules of
+// the existing Role beyond the generated ones are copied into spec.rbac.extraRules, and
+// recorded in the adoptedRulesAnnotation, so the application keeps its permissions even
+// where the grantable rules don't cover them (see grantedRules). Pods using a
+// ServiceAccount of another name keep it and its RBAC, outside the AkkaCluster. With the
+// adoption policy set to IfUnowned, the next reconcile takes over the Deployment and RBAC
+// by adding owner references, while the pod template hash stays the same. Once it has,
+// the policy is set back, so nothing else is adopted by accident later.
+
+// adoptionPendingAnnotation marks an AkkaCluster whose adoption policy was set to
+// IfUnowned only to adopt a Deployment.
+const adoptionPendingAnnotation = "app.lightbend.com/adoption-pending"
+
+// adoptedRulesAnnotation lists, as JSON, the rules copied from an adopted Role into
+// spec.rbac.extraRules.
+const adoptedRulesAnnotation = "app.lightbend.com/adopted-rules"
+
+// adoptDeployment derives the spec of the AkkaCluster from the Deployment named by its
+// AdoptDeploymentAnnotation, and removes the annotation. Problems are reported as Events
+// and leave the annotation in place. Returns true if the AkkaCluster was updated.
+func (r *ReconcileAkkaCluster) adoptDeployment(akkaCluster *appv1alpha1.AkkaCluster) (bool, error) {
+	name, ok := akkaCluster.Annotations[appv1alpha1.AdoptDeploymentAnnotation]
+	if !ok {
+		return false, nil
+	}
+	deployment := &appsv1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: akkaCluster.Namespace, Name: name}, deployment)
+	if errors.IsNotFound(err) {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "AdoptionFailed", "Deployment %s not found", name)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if generatedName(akkaCluster) != name {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "AdoptionFailed",
+			"Deployment %s doesn't have the generated name %s, set a name prefix or suffix to match", name, generatedName(akkaCluster))
+		return false, nil
+	}
+	if owner := metav1.GetControllerOf(deployment); owner != nil {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "AdoptionFailed",
+			"Deployment %s is controlled by %s %s", name, owner.Kind, owner.Name)
+		return false, nil
+	}
+
+	spec := deployment.Spec.DeepCopy()
+	generated := akkaCluster.Spec.GeneratedResources.DeepCopy()
+	if generated == nil {
+		generated = &appv1alpha1.AkkaClusterGeneratedResourcesSpec{}
+	}
+	if generated.AdoptionPolicy != appv1alpha1.AdoptIfUnowned {
+		generated.AdoptionPolicy = appv1alpha1.AdoptIfUnowned
+		akkaCluster.Annotations[adoptionPendingAnnotation] = "true"
+	}
+	bootstrapEnv := false
+	generated.BootstrapEnv = &bootstrapEnv
+	switch serviceAccount := spec.Template.Spec.ServiceAccountName; serviceAccount {
+	case name:
+		// generated again, under the same name, keeping the rules of the existing Role
+		spec.Template.Spec.ServiceAccountName = ""
+		if err := r.adoptRoleRules(akkaCluster); err != nil {
+			return false, err
+		}
+	case "", "default":
+	default:
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "AdoptingDeployment",
+			"pods keep ServiceAccount %s, which is left with its RBAC as it is, outside the AkkaCluster", serviceAccount)
+	}
+	akkaCluster.Spec.DeploymentSpec = *spec
+	akkaCluster.Spec.GeneratedResources = generated
+	delete(akkaCluster.Annotations, appv1alpha1.AdoptDeploymentAnnotation)
+	if err := r.client.Update(context.TODO(), akkaCluster); err != nil {
+		return false, err
+	}
+	r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "AdoptingDeployment", "derived spec from Deployment %s", name)
+	return true, nil
+}
+
+// adoptRoleRules copies the rules of the Role of the generated name, if there is one, into
+// spec.rbac.extraRules, leaving out those the generated Role has anyway, and records them
+// in the adoptedRulesAnnotation.
+func (r *ReconcileAkkaCluster) adoptRoleRules(akkaCluster *appv1alpha1.AkkaCluster) error {
+	role := &rbac.Role{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: akkaCluster.Namespace, Name: generatedName(akkaCluster)}, role)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if akkaCluster.Spec.RBAC == nil {
+		akkaCluster.Spec.RBAC = &appv1alpha1.AkkaClusterRBACSpec{}
+	}
+	generated := []rbac.PolicyRule{podReaderRule(), leaseRule()}
+	adopted := []rbac.PolicyRule{}
+	for _, rule := range role.Rules {
+		if hasRule(generated, rule) || hasRule(adopted, rule) {
+			continue
+		}
+		adopted = append(adopted, rule)
+		if !hasRule(akkaCluster.Spec.RBAC.ExtraRules, rule) {
+			akkaCluster.Spec.RBAC.ExtraRules = append(akkaCluster.Spec.RBAC.ExtraRules, rule)
+		}
+	}
+	if len(adopted) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(adopted)
+	if err != nil {
+		return err
+	}
+	akkaCluster.Annotations[adoptedRulesAnnotation] = string(data)
+	return nil
+}
+
+// hasRule is true if rules has rule.
+func hasRule(rules []rbac.PolicyRule, rule rbac.PolicyRule) bool {
+	for _, r := range rules {
+		if apiequality.Semantic.DeepEqual(r, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// finishAdoption sets the adoption policy back once the adopted resources are controlled
+// by the AkkaCluster, as stored.
+func (r *ReconcileAkkaCluster) finishAdoption(akkaCluster *appv1alpha1.AkkaCluster) error {
+	if _, ok := akkaCluster.Annotations[adoptionPendingAnnotation]; !ok {
+		return nil
+	}
+	delete(akkaCluster.Annotations, adoptionPendingAnnotation)
+	if spec := akkaCluster.Spec.GeneratedResources; spec != nil {
+		spec.AdoptionPolicy = ""
+	}
+	if err := r.client.Update(context.TODO(), akkaCluster); err != nil {
+		return err
+	}
+	r.recorder.Event(akkaCluster, corev1.EventTypeNormal, "AdoptedDeployment", "adopted generated resources, adoption policy cleared")
+	return nil
+}
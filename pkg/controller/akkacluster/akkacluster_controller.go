@@ -4,10 +4,19 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -19,21 +28,42 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
 )
 
 var log = logf.Log.WithName("controller_akkacluster")
 
-// Add creates a new AkkaCluster Controller and adds it to the Manager. The Manager will
-// set fields on the Controller and Start it when the Manager is Started.
-func Add(mgr manager.Manager) error {
+// Add creates a new AkkaCluster Controller with the given options and adds it to the
+// Manager. The Manager will set fields on the Controller and Start it when the Manager is
+// Started.
+func Add(mgr manager.Manager, options Options) error {
 	statusEvents := make(chan event.GenericEvent, 1024)
 	apiClient := mgr.GetClient()
+	recorder := mgr.GetEventRecorderFor("akkacluster-controller")
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
 
+	statusActor := NewStatusActor(apiClient, statusEvents, recorder, options)
 	r := &ReconcileAkkaCluster{
+		options:     options,
 		client:      apiClient,
 		scheme:      mgr.GetScheme(),
+		mapper:      mgr.GetRESTMapper(),
+		discovery:   discoveryClient,
+		recorder:    recorder,
+		writer:      management.NewHTTPClient(nil),
 		events:      statusEvents,
-		statusActor: NewStatusActor(apiClient, statusEvents),
+		statusActor: statusActor,
+	}
+
+	// The manager runs the StatusActor alongside the controller, on the leader only.
+	if err := mgr.Add(statusActor); err != nil {
+		return err
+	}
+	if err := mgr.AddHealthzCheck("status-actor", statusActor.Healthz); err != nil {
+		return err
 	}
 
 	// Create a new controller
@@ -59,6 +89,26 @@ func Add(mgr manager.Manager) error {
 		}
 	}
 
+	// Watch for changes to cluster-scoped secondary resources, which are owned by labels
+	if options.ClusterRBAC {
+		for _, obj := range clusterScopedGeneratedResourceTypes() {
+			err = c.Watch(&source.Kind{Type: obj}, &handler.EnqueueRequestsFromMapFunc{
+				ToRequests: handler.ToRequestsFunc(ownerOfClusterScoped),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Watch for changes to classes, to roll the AkkaClusters of a class
+	err = c.Watch(&source.Kind{Type: &appv1alpha1.AkkaClusterClass{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.clustersOfClass),
+	})
+	if err != nil {
+		return err
+	}
+
 	// watch for Akka Cluster status updates
 	err = c.Watch(&source.Channel{Source: r.events}, &handler.EnqueueRequestForObject{})
 	if err != nil {
@@ -76,6 +126,31 @@ func Add(mgr manager.Manager) error {
 		return err
 	}
 
+	// Controllers only start their watches once elected, so get the informers now. They
+	// start with the cache on every replica, and a standby takes over with a warm cache.
+	informers := []runtime.Object{&appv1alpha1.AkkaCluster{}, &appv1alpha1.AkkaClusterClass{}, &corev1.Pod{}}
+	for _, obj := range allPossibleGeneratedResourceTypes() {
+		informers = append(informers, obj)
+	}
+	if options.ClusterRBAC {
+		for _, obj := range clusterScopedGeneratedResourceTypes() {
+			informers = append(informers, obj)
+		}
+	}
+	for _, obj := range informers {
+		if _, err := mgr.GetCache().GetInformer(context.TODO(), obj); err != nil {
+			return err
+		}
+	}
+
+	// serve the read API, if asked to
+	if options.ReadAPIAddress != "" {
+		err = mgr.Add(newReadAPI(options.ReadAPIAddress, mgr.GetCache(), r.statusActor, options.Deployment.SelectorKey))
+		if err != nil {
+			return err
+		}
+	}
+
 	// debug watchers (this could be redone as debug predicates)
 	// c.Watch(&source.Kind{Type: &appsv1.Deployment{}}, &enqueueDebugger{})
 	// c.Watch(&source.Kind{Type: &corev1.Pod{}}, &enqueueDebugger{})
@@ -88,10 +163,15 @@ var _ reconcile.Reconciler = &ReconcileAkkaCluster{}
 
 // ReconcileAkkaCluster reconciles a AkkaCluster object
 type ReconcileAkkaCluster struct {
+	options Options
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client      client.Client
 	scheme      *runtime.Scheme
+	mapper      meta.RESTMapper
+	discovery   discovery.ServerVersionInterface
+	recorder    record.EventRecorder
+	writer      management.Writer
 	events      chan event.GenericEvent
 	statusActor *StatusActor
 }
@@ -114,57 +194,167 @@ func (r *ReconcileAkkaCluster) Reconcile(request reconcile.Request) (reconcile.R
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
 			r.statusActor.StopPolling(request)
+			r.statusActor.NotifySubscribers(request)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	// generateResources populates akkaCluster with defaults and returns list of resources to check.
-	for _, wantedResource := range generateResources(akkaCluster) {
-		if err := controllerutil.SetControllerReference(akkaCluster, wantedResource, r.scheme); err != nil {
-			return reconcile.Result{}, err
+	// On deletion, walk members out of the Akka cluster before releasing owned resources,
+	// and then delete cluster-scoped resources, which garbage collection won't.
+	if akkaCluster.DeletionTimestamp != nil {
+		if hasFinalizer(akkaCluster, teardownFinalizer) {
+			return r.teardown(request, akkaCluster)
 		}
-		kind := reflect.ValueOf(wantedResource).Elem().Type().String()
-		// Fetch this resource from cluster, if any.
-		clusterResource := wantedResource.DeepCopyObject()
-		err = r.client.Get(context.TODO(), request.NamespacedName, clusterResource)
-		if err != nil && errors.IsNotFound(err) {
-			// Create wanted resource. Next client.Get will at least fetch wantedResource and will eventually
-			// reflect the object as it is in the cluster.
-			if err := r.client.Create(context.TODO(), wantedResource); err != nil {
-				reqLogger.Info("Tried to create a new resource", "kind", kind, "error", err)
-				return reconcile.Result{}, err
-			}
-			reqLogger.Info("Creating resource", "kind", kind)
-			return reconcile.Result{Requeue: true}, nil
+		return reconcile.Result{}, r.releaseClusterRBAC(akkaCluster)
+	}
+	finalizers := []string{}
+	if wantsTeardown(akkaCluster) {
+		finalizers = append(finalizers, teardownFinalizer)
+	}
+	if r.options.ClusterRBAC && wantsClusterRBAC(akkaCluster) {
+		finalizers = append(finalizers, clusterRBACFinalizer)
+	}
+	updated := false
+	for _, finalizer := range finalizers {
+		if !hasFinalizer(akkaCluster, finalizer) {
+			akkaCluster.Finalizers = append(akkaCluster.Finalizers, finalizer)
+			updated = true
 		}
-		// Patch cluster resource to wanted resource, if needed.
-		if !SubsetEqual(wantedResource, clusterResource) {
-			reqLogger.Info("applying update", "kind", kind, "match")
+	}
+	if !wantsTeardown(akkaCluster) && hasFinalizer(akkaCluster, teardownFinalizer) {
+		removeFinalizer(akkaCluster, teardownFinalizer)
+		updated = true
+	}
+	if updated {
+		if err := r.client.Update(context.TODO(), akkaCluster); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
 
-			// patch.Merge uses the raw object as a merge patch, without modifications.
-			if err := r.client.Patch(context.TODO(), wantedResource, client.Merge); err != nil {
-				reqLogger.Info("Tried to patch resource", "kind", kind, "error", err)
-				return reconcile.Result{}, err
-			}
-			return reconcile.Result{Requeue: true}, nil
+	// Take over an existing Deployment, if asked to, before generating anything.
+	if adopted, err := r.adoptDeployment(akkaCluster); err != nil || adopted {
+		return reconcile.Result{Requeue: adopted}, err
+	}
+
+	// Keep the AkkaCluster as stored, so status updates don't write back generated defaults.
+	persisted := akkaCluster.DeepCopy()
+
+	// Reconcile the effective spec, with the class of the AkkaCluster merged in.
+	classFound, err := r.applyClass(akkaCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// Existing Deployments keep their name, as a renamed one would start another Akka cluster.
+	refusedName, err := r.keepLiveName(akkaCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// Leave out rules of spec.rbac that the operator may not grant, unless adopted.
+	granted, err := r.grantedRules(akkaCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	refused := r.refuseRules(akkaCluster, granted)
+
+	// Existing Deployments keep their selector, which can't change.
+	if err := r.keepLiveSelector(akkaCluster); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// generateResources populates akkaCluster with defaults and returns list of resources to check.
+	wantedResources := generateResources(akkaCluster, r.options.Deployment)
+	if isPaused(akkaCluster) {
+		// leave generated resources as they are, which may be hand edited
+		reqLogger.Info("Reconciliation paused, skipping generated resources")
+		wantedResources = nil
+	}
+	if !classFound {
+		// the class watch brings the AkkaCluster back once the class exists
+		reqLogger.Info("AkkaClusterClass not found, skipping generated resources", "class", akkaCluster.Spec.ClassName)
+		wantedResources = nil
+	}
+	// fields owned by other controllers, left out of drift correction
+	ignored := r.ignoredFields(akkaCluster)
+	// Reconcile every resource in this pass, so a new AkkaCluster comes up in one round trip.
+	changed := false
+	errs := []error{}
+	collisions := []*nameCollision{}
+	for _, wantedResource := range wantedResources {
+		resourceChanged, err := r.reconcileResource(request, akkaCluster, wantedResource, ignored)
+		if collision, ok := err.(*nameCollision); ok {
+			reqLogger.Info("Leaving colliding resource alone", "collision", collision.Error())
+			collisions = append(collisions, collision)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		changed = changed || resourceChanged
+	}
+	if !isPaused(akkaCluster) && classFound {
+		if err := r.deleteUnwanted(akkaCluster, wantedResources); err != nil {
+			errs = append(errs, err)
 		}
 	}
+	// With every generated resource controlled, an adoption is done.
+	if !isPaused(akkaCluster) && classFound && len(errs) == 0 && len(collisions) == 0 {
+		if err := r.finishAdoption(persisted); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// A lease-majority resolver needs the Lease CRD, which the operator doesn't install.
+	leaseAvailable, err := r.leaseAvailable(akkaCluster)
+	if err != nil {
+		errs = append(errs, err)
+	}
 
+	// Status comes from the StatusActor, except for conditions owned by Reconcile.
+	status := akkaCluster.Status.DeepCopy()
 	if r.statusActor != nil {
-		currentStatus := r.statusActor.GetStatus(request)
-		if nil != currentStatus {
-			if !reflect.DeepEqual(akkaCluster.Status, currentStatus) {
-				akkaCluster.Status = currentStatus
-				err := r.client.Status().Update(context.TODO(), akkaCluster)
-				if err != nil {
-					reqLogger.Info("update error", "err", err)
-					return reconcile.Result{}, err
-				}
-				reqLogger.Info("updated cluster status")
+		status = r.statusActor.statusOf(request, akkaCluster.Status)
+	}
+	status = r.reportPaused(akkaCluster, status)
+	status = r.reportClass(akkaCluster, status, classFound)
+	status = r.reportLease(akkaCluster, status, leaseAvailable, optionsFromElsewhere(akkaCluster))
+	status = r.reportRulesRefused(akkaCluster, status, refused)
+	status = r.reportNameKept(akkaCluster, status, refusedName)
+	if !isPaused(akkaCluster) {
+		status = r.reportCollisions(akkaCluster, status, collisions)
+	}
+	// With the load from the StatusActor, the autoscaler may change spec.replicas.
+	var scaleTo *int32
+	var scaleWait time.Duration
+	if !isPaused(akkaCluster) && classFound {
+		status, scaleTo, scaleWait = r.autoscale(akkaCluster, status)
+	}
+	if status != nil && !reflect.DeepEqual(akkaCluster.Status, status) {
+		akkaCluster.Status = status
+		persisted.Status = status
+		err := r.client.Status().Update(context.TODO(), persisted)
+		if err != nil {
+			reqLogger.Info("update error", "err", err)
+			errs = append(errs, err)
+		} else {
+			reqLogger.Info("updated cluster status")
+			if r.statusActor != nil {
+				r.statusActor.NotifySubscribers(request)
 			}
 		}
+	}
+
+	// Scale once the decision is recorded in status, so it isn't made twice.
+	if scaleTo != nil && len(errs) == 0 {
+		if err := r.scale(persisted, *scaleTo); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if r.statusActor != nil {
 		// StartPolling means: notify me if status for this cluster changes from what I've
 		// got so far. This could happen on the first reconcile, meaning status is unknown
 		// or nil, and we want to be notified when it becomes available. This could also
@@ -173,5 +363,182 @@ func (r *ReconcileAkkaCluster) Reconcile(request reconcile.Request) (reconcile.R
 		r.statusActor.StartPolling(akkaCluster)
 	}
 
-	return reconcile.Result{}, nil
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		return reconcile.Result{}, err
+	}
+	if changed {
+		// requeue once to check the applied resources
+		return reconcile.Result{Requeue: true}, nil
+	}
+	if len(collisions) > 0 {
+		return reconcile.Result{RequeueAfter: collisionRetryInterval}, nil
+	}
+	if isPaused(akkaCluster) || !classFound {
+		return reconcile.Result{}, nil
+	}
+
+	// With status up to date, see if a lasting split brain needs remediation.
+	wait, err := r.remediateSplitBrain(akkaCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !leaseAvailable && (wait == 0 || wait > leaseRetryInterval) {
+		wait = leaseRetryInterval
+	}
+	if scaleWait > 0 && (wait == 0 || wait > scaleWait) {
+		wait = scaleWait
+	}
+
+	return reconcile.Result{RequeueAfter: wait}, nil
+}
+
+// reconcileResource creates or updates one generated resource, returning true if it did.
+func (r *ReconcileAkkaCluster) reconcileResource(request reconcile.Request, akkaCluster *appv1alpha1.AkkaCluster,
+	wantedResource GenericResource, ignored []FieldPath) (bool, error) {
+
+	reqLogger := log.WithValues("name", request.String())
+	// ignored fields are paths in the Deployment, so other kinds stay fully enforced
+	if _, ok := wantedResource.(*appsv1.Deployment); !ok {
+		ignored = nil
+	}
+	// cluster-scoped resources can't be owned by a namespaced AkkaCluster, and carry owner labels instead
+	if !isClusterScoped(wantedResource) {
+		if err := controllerutil.SetControllerReference(akkaCluster, wantedResource, r.scheme); err != nil {
+			return false, err
+		}
+	}
+	if err := stampAppliedHash(wantedResource, ignored); err != nil {
+		return false, err
+	}
+	kind := reflect.ValueOf(wantedResource).Elem().Type().String()
+	// Fetch this resource from cluster, if any, into an empty object so nothing of the wanted one shows through.
+	clusterResource := reflect.New(reflect.TypeOf(wantedResource).Elem()).Interface().(runtime.Object)
+	key := types.NamespacedName{Namespace: wantedResource.GetNamespace(), Name: wantedResource.GetName()}
+	err := r.client.Get(context.TODO(), key, clusterResource)
+	if err != nil && errors.IsNotFound(err) {
+		// Apply creates the wanted resource. Next client.Get will at least fetch wantedResource and will
+		// eventually reflect the object as it is in the cluster. Ignored fields are set too, to seed them.
+		if err := r.apply(wantedResource, nil, nil); err != nil {
+			reqLogger.Info("Tried to create a new resource", "kind", kind, "error", err)
+			return false, err
+		}
+		reqLogger.Info("Creating resource", "kind", kind)
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := r.checkOwnership(akkaCluster, reflect.TypeOf(wantedResource).Elem().Name(), clusterResource); err != nil {
+		return false, err
+	}
+	// Apply wanted resource to cluster resource, if needed. The applied hash annotation makes
+	// this catch fields that are no longer wanted too.
+	if !SubsetEqual(wantedResource, clusterResource, ignored...) {
+		diffs := SubsetDiff(wantedResource, clusterResource, ignored...)
+		reqLogger.Info("applying update", "kind", kind, "drift", describeDrift(diffs, len(diffs)))
+		r.reportDrift(akkaCluster, kind, diffs)
+
+		if err := r.apply(wantedResource, clusterResource, ignored); err != nil {
+			reqLogger.Info("Tried to apply resource", "kind", kind, "error", err)
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// keepLiveName names the resources of an AkkaCluster after its existing Deployment, when a
+// changed name prefix or suffix asks for another name. A Deployment of the new name would
+// form a second Akka cluster next to the first until the old one was deleted. Returns the
+// name asked for if it was refused, or else an empty string.
+func (r *ReconcileAkkaCluster) keepLiveName(akkaCluster *appv1alpha1.AkkaCluster) (string, error) {
+	name := generatedName(akkaCluster)
+	deployment := &appsv1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: akkaCluster.Namespace, Name: name}, deployment)
+	if err == nil && metav1.IsControlledBy(deployment, akkaCluster) {
+		return "", nil
+	} else if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	deployments := &appsv1.DeploymentList{}
+	if err := r.client.List(context.TODO(), deployments, client.InNamespace(akkaCluster.Namespace)); err != nil {
+		return "", err
+	}
+	var live *appsv1.Deployment
+	for i := range deployments.Items {
+		candidate := &deployments.Items[i]
+		if !metav1.IsControlledBy(candidate, akkaCluster) || candidate.DeletionTimestamp != nil ||
+			!strings.Contains(candidate.Name, akkaCluster.Name) {
+			continue
+		}
+		if live == nil || candidate.CreationTimestamp.Before(&live.CreationTimestamp) {
+			live = candidate
+		}
+	}
+	if live == nil {
+		return "", nil
+	}
+	spec := akkaCluster.Spec.GeneratedResources.DeepCopy()
+	if spec == nil {
+		spec = &appv1alpha1.AkkaClusterGeneratedResourcesSpec{}
+	}
+	at := strings.Index(live.Name, akkaCluster.Name)
+	spec.NamePrefix, spec.NameSuffix = live.Name[:at], live.Name[at+len(akkaCluster.Name):]
+	akkaCluster.Spec.GeneratedResources = spec
+	return name, nil
+}
+
+// reportNameKept sets the NameChangeRefused condition on status, naming the generated name
+// asked for if it was refused.
+func (r *ReconcileAkkaCluster) reportNameKept(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus,
+	refused string) *appv1alpha1.AkkaClusterStatus {
+
+	if refused == "" {
+		return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterNameChangeRefused, true, "NameUnchanged",
+			"generated resources have the name asked for")
+	}
+	return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterNameChangeRefused, false, "DeploymentExists",
+		fmt.Sprintf("generated resources keep the name %s, as a Deployment named %s would form a second Akka cluster; "+
+			"recreate the AkkaCluster to rename them", generatedName(akkaCluster), refused))
+}
+
+// keepLiveSelector selects the pods of an AkkaCluster that has no selector as its existing
+// Deployment does, as spec.selector of a Deployment can't change. Otherwise a new default
+// selector key would break every apply of existing clusters relying on the default.
+func (r *ReconcileAkkaCluster) keepLiveSelector(akkaCluster *appv1alpha1.AkkaCluster) error {
+	if akkaCluster.Spec.Selector != nil {
+		return nil
+	}
+	deployment := &appsv1.Deployment{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: akkaCluster.Namespace, Name: generatedName(akkaCluster)}, deployment)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if owner := metav1.GetControllerOf(deployment); deployment.Spec.Selector == nil || (owner != nil && owner.UID != akkaCluster.UID) {
+		return nil
+	}
+	akkaCluster.Spec.Selector = deployment.Spec.Selector.DeepCopy()
+	if akkaCluster.Spec.Template.Labels == nil {
+		akkaCluster.Spec.Template.Labels = make(map[string]string)
+	}
+	for key, value := range akkaCluster.Spec.Selector.MatchLabels {
+		akkaCluster.Spec.Template.Labels[key] = value
+	}
+	return nil
+}
+
+// defaulted returns a copy of akkaCluster with defaults filled in, as reconciled, leaving
+// the original untouched.
+func (r *ReconcileAkkaCluster) defaulted(akkaCluster *appv1alpha1.AkkaCluster) (*appv1alpha1.AkkaCluster, error) {
+	defaulted := akkaCluster.DeepCopy()
+	if _, err := r.keepLiveName(defaulted); err != nil {
+		return nil, err
+	}
+	if err := r.keepLiveSelector(defaulted); err != nil {
+		return nil, err
+	}
+	generateResources(defaulted, r.options.Deployment)
+	return defaulted, nil
 }
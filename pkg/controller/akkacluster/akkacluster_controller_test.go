@@ -3,20 +3,148 @@ package akkacluster
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 
 	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
 )
 
+// applyClient records the patches sent through it. Server-side apply, which the fake client
+// doesn't support, is stood in for by a strategic merge of the applied configuration, so
+// generated resources come into being. Ownership, conflicts and the removal of fields no
+// longer applied are the API server's, so tests of those assert on the recorded patches.
+type applyClient struct {
+	client.Client
+	scheme  *runtime.Scheme
+	patches []sentPatch
+}
+
+// sentPatch is a patch as sent: its type, field manager, whether it forced ownership, and
+// its body.
+type sentPatch struct {
+	patchType types.PatchType
+	manager   string
+	force     bool
+	body      map[string]interface{}
+}
+
+func newApplyClient(scheme *runtime.Scheme, objs ...runtime.Object) *applyClient {
+	return recordPatches(scheme, fake.NewFakeClientWithScheme(scheme, objs...))
+}
+
+func recordPatches(scheme *runtime.Scheme, c client.Client) *applyClient {
+	return &applyClient{Client: c, scheme: scheme}
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	options := &client.PatchOptions{}
+	options.ApplyOptions(opts)
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+	c.patches = append(c.patches, sentPatch{
+		patchType: patch.Type(),
+		manager:   options.FieldManager,
+		force:     options.Force != nil && *options.Force,
+		body:      body,
+	})
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	// the tracker needs typed objects to find list merge keys for strategic merge
+	typed, err := c.scheme.New(obj.GetObjectKind().GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, typed); err != nil {
+		return err
+	}
+	key, err := client.ObjectKeyFromObject(obj)
+	if err != nil {
+		return err
+	}
+	if err := c.Get(ctx, key, typed.DeepCopyObject()); errors.IsNotFound(err) {
+		return c.Create(ctx, typed)
+	} else if err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, typed, client.RawPatch(types.StrategicMergePatchType, data))
+}
+
+// applies returns the recorded applies of the given kind and name.
+func (c *applyClient) applies(kind, name string) []sentPatch {
+	applies := []sentPatch{}
+	for _, patch := range c.patches {
+		if patch.patchType != types.ApplyPatchType || patch.body["kind"] != kind {
+			continue
+		}
+		if metadata, _ := patch.body["metadata"].(map[string]interface{}); metadata["name"] == name {
+			applies = append(applies, patch)
+		}
+	}
+	return applies
+}
+
+// field returns the value at path in the body of a patch, as a string, and whether it is
+// there. Path segments are field names, or name=value to pick a list element by name.
+func (p sentPatch) field(path ...string) (string, bool) {
+	var value interface{} = p.body
+	for _, segment := range path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[segment]
+			if !ok {
+				return "", false
+			}
+			value = child
+		case []interface{}:
+			found := false
+			for _, element := range v {
+				if e, ok := element.(map[string]interface{}); ok && "name="+fmt.Sprint(e["name"]) == segment {
+					value, found = e, true
+				}
+			}
+			if !found {
+				return "", false
+			}
+		default:
+			return "", false
+		}
+	}
+	return fmt.Sprint(value), true
+}
+
 func TestAkkaController(t *testing.T) {
 	name := types.NamespacedName{
 		Name:      "akka-cluster-test",
@@ -49,7 +177,7 @@ func TestAkkaController(t *testing.T) {
 	scheme := scheme.Scheme
 	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
 	client := fake.NewFakeClientWithScheme(scheme, akkaCluster)
-	r := &ReconcileAkkaCluster{client: client, scheme: scheme}
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: recordPatches(scheme, client), scheme: scheme}
 
 	// mock event loop
 	req := reconcile.Request{NamespacedName: name}
@@ -98,6 +226,12 @@ func TestAkkaController(t *testing.T) {
 		t.Errorf("expected three replicas but got %d", deployment.Spec.Replicas)
 	}
 
+	// graceful teardown isn't asked for, so deletion isn't held up
+	client.Get(context.TODO(), req.NamespacedName, akkaCluster)
+	if hasFinalizer(akkaCluster, teardownFinalizer) {
+		t.Errorf("expected no teardown finalizer, but got %v", akkaCluster.Finalizers)
+	}
+
 	// grow the cluster
 	*akkaCluster.Spec.Replicas = 4
 	client.Update(context.TODO(), akkaCluster)
@@ -112,3 +246,2025 @@ func TestAkkaController(t *testing.T) {
 	}
 
 }
+
+func TestSplitBrainRemediation(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	grace := int32(30)
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	akkaCluster.Spec.SplitBrain = &appv1alpha1.AkkaClusterSplitBrainSpec{
+		Probes:             4,
+		Remediate:          true,
+		GracePeriodSeconds: &grace,
+	}
+	akkaCluster.Status = &appv1alpha1.AkkaClusterStatus{
+		Conditions: []appv1alpha1.AkkaClusterCondition{{
+			Type:               appv1alpha1.AkkaClusterSplitBrain,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+		}},
+		Islands: []appv1alpha1.AkkaClusterIsland{
+			{
+				Leader:  generateNodeAddress("10.0.0.1"),
+				Members: []string{generateNodeAddress("10.0.0.1"), generateNodeAddress("10.0.0.2")},
+			},
+			{
+				Leader:  generateNodeAddress("10.0.0.3"),
+				Members: []string{generateNodeAddress("10.0.0.3")},
+			},
+		},
+	}
+
+	objs := []runtime.Object{akkaCluster}
+	for n, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		pod := generatePod(ip)
+		pod.Name = fmt.Sprintf("pod-%d", n+1)
+		pod.Namespace = name.Namespace
+		pod.Labels = map[string]string{"app": name.Name}
+		objs = append(objs, pod)
+	}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, objs...)
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: recorder}
+
+	req := reconcile.Request{NamespacedName: name}
+	res := reconcile.Result{Requeue: true}
+	for limit := 10; res.Requeue && limit > 0; limit-- {
+		var err error
+		res, err = r.Reconcile(req)
+		if err != nil {
+			t.Fatalf("reconcile error: %v", err)
+		}
+	}
+	if res.RequeueAfter != 30*time.Second {
+		t.Errorf("expected to check again after grace period, but got %v", res.RequeueAfter)
+	}
+
+	pods := &corev1.PodList{}
+	client.List(context.TODO(), pods)
+	if len(pods.Items) != 2 {
+		t.Fatalf("expected minority pod to be deleted, but have %d pods", len(pods.Items))
+	}
+	for _, pod := range pods.Items {
+		if pod.Name == "pod-3" {
+			t.Errorf("expected pod-3 of minority island to be deleted")
+		}
+	}
+	if len(recorder.Events) != 2 {
+		t.Errorf("expected decision and deletion events, but got %d", len(recorder.Events))
+	}
+}
+
+func TestMinorityIsland(t *testing.T) {
+	pods := []corev1.Pod{*generatePod("10.0.0.1"), *generatePod("10.0.0.2")}
+	pods[0].CreationTimestamp = metav1.NewTime(time.Unix(1000, 0))
+	pods[1].CreationTimestamp = metav1.NewTime(time.Unix(2000, 0))
+	older := appv1alpha1.AkkaClusterIsland{Leader: "a", Members: []string{generateNodeAddress("10.0.0.1")}}
+	younger := appv1alpha1.AkkaClusterIsland{Leader: "b", Members: []string{generateNodeAddress("10.0.0.2")}}
+	bigger := appv1alpha1.AkkaClusterIsland{Leader: "c", Members: []string{"x", "y"}}
+
+	if island := minorityIsland([]appv1alpha1.AkkaClusterIsland{bigger, older}, pods); island.Leader != "a" {
+		t.Errorf("expected smaller island to be minority, but got %s", island.Leader)
+	}
+	if island := minorityIsland([]appv1alpha1.AkkaClusterIsland{older, younger}, pods); island.Leader != "b" {
+		t.Errorf("expected younger island to be minority on a tie, but got %s", island.Leader)
+	}
+}
+
+func TestFindIslandsDuringPartition(t *testing.T) {
+	a, b, c := generateNodeAddress("10.0.0.1"), generateNodeAddress("10.0.0.2"), generateNodeAddress("10.0.0.3")
+	all := []appv1alpha1.AkkaClusterMemberStatus{{Node: a, Status: "Up"}, {Node: b, Status: "Up"}, {Node: c, Status: "Up"}}
+	// both sides still list every member, marking the other side unreachable
+	views := map[string]*appv1alpha1.AkkaClusterManagementStatus{
+		"10.0.0.1": {
+			Leader:      a,
+			Members:     all,
+			Unreachable: []appv1alpha1.AkkaClusterUnreachableMemberStatus{{Node: c, ObservedBy: []string{a, b}}},
+		},
+		"10.0.0.3": {
+			Leader:      c,
+			Members:     all,
+			Unreachable: []appv1alpha1.AkkaClusterUnreachableMemberStatus{{Node: a, ObservedBy: []string{c}}, {Node: b, ObservedBy: []string{c}}},
+		},
+	}
+	islands := findIslands(views)
+	expected := []appv1alpha1.AkkaClusterIsland{
+		{Leader: a, Members: []string{a, b}},
+		{Leader: c, Members: []string{c}},
+	}
+	if !apiequality.Semantic.DeepEqual(islands, expected) {
+		t.Errorf("expected islands %v, but got %v", expected, islands)
+	}
+
+	// a node both sides claim as reachable belongs to neither
+	views["10.0.0.3"].Unreachable = []appv1alpha1.AkkaClusterUnreachableMemberStatus{{Node: a, ObservedBy: []string{c}}}
+	islands = findIslands(views)
+	expected = []appv1alpha1.AkkaClusterIsland{
+		{Leader: a, Members: []string{a}},
+		{Leader: c, Members: []string{c}},
+	}
+	if !apiequality.Semantic.DeepEqual(islands, expected) {
+		t.Errorf("expected shared node to be left out, but got %v", islands)
+	}
+}
+
+func TestOnlyHosts(t *testing.T) {
+	a, b, c := generateNodeAddress("10.0.0.1"), generateNodeAddress("10.0.0.2"), generateNodeAddress("10.0.0.3")
+	majority := appv1alpha1.AkkaClusterIsland{Leader: a, Members: []string{a, b, c}}
+	minority := appv1alpha1.AkkaClusterIsland{Leader: c, Members: []string{a, b, c}}
+	if hosts := onlyHosts(minority, []appv1alpha1.AkkaClusterIsland{majority, minority}); len(hosts) != 0 {
+		t.Errorf("expected no hosts when islands overlap completely, but got %v", hosts)
+	}
+	majority.Members = []string{a, b}
+	if hosts := onlyHosts(minority, []appv1alpha1.AkkaClusterIsland{majority, minority}); !apiequality.Semantic.DeepEqual(hosts, map[string]bool{"10.0.0.3": true}) {
+		t.Errorf("expected only 10.0.0.3, but got %v", hosts)
+	}
+}
+
+// testWriter is a management.Writer mock that records the members asked to leave.
+type testWriter struct {
+	left []string
+}
+
+func (w *testWriter) PutForm(link string, form url.Values) ([]byte, error) {
+	node, _ := url.PathUnescape(link[strings.LastIndex(link, "/")+1:])
+	w.left = append(w.left, management.NodeHost(node))
+	return nil, nil
+}
+
+func TestGracefulTeardown(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	now := metav1.Now()
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Teardown = &appv1alpha1.AkkaClusterTeardownSpec{}
+	akkaCluster.Finalizers = []string{teardownFinalizer}
+	akkaCluster.DeletionTimestamp = &now
+	akkaCluster.Status = &appv1alpha1.AkkaClusterStatus{ManagementPort: 8558}
+	akkaCluster.Status.Cluster.Oldest = generateNodeAddress("10.0.0.2")
+
+	replicas := int32(3)
+	deployment := &appsv1.Deployment{}
+	deployment.Name = name.Name
+	deployment.Namespace = name.Namespace
+	deployment.Spec.Replicas = &replicas
+
+	// pods started in order .2, .1, .3, so .3 leaves first and oldest member .2 leaves last
+	objs := []runtime.Object{akkaCluster, deployment}
+	for n, ip := range []string{"10.0.0.2", "10.0.0.1", "10.0.0.3"} {
+		pod := generatePod(ip)
+		pod.Name = fmt.Sprintf("pod-%d", n+1)
+		pod.Namespace = name.Namespace
+		pod.Labels = map[string]string{"app": name.Name}
+		pod.CreationTimestamp = metav1.NewTime(time.Unix(int64(1000*(n+1)), 0))
+		objs = append(objs, pod)
+		akkaCluster.Status.Cluster.Members = append(akkaCluster.Status.Cluster.Members,
+			appv1alpha1.AkkaClusterMemberStatus{Node: generateNodeAddress(ip), Status: "Up"})
+	}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, objs...)
+	writer := &testWriter{}
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(10), writer: writer}
+	req := reconcile.Request{NamespacedName: name}
+
+	deploymentReplicas := func() int32 {
+		deployment := &appsv1.Deployment{}
+		client.Get(context.TODO(), req.NamespacedName, deployment)
+		return *deployment.Spec.Replicas
+	}
+
+	// each step scales down to the members left, asks one member to leave, and then the
+	// member is removed from the cluster
+	for n, want := range []string{"10.0.0.3", "10.0.0.1", "10.0.0.2"} {
+		if _, err := r.Reconcile(req); err != nil {
+			t.Fatalf("reconcile error: %v", err)
+		}
+		if replicas := deploymentReplicas(); replicas != int32(3-n) {
+			t.Errorf("expected deployment scaled down to %d members, but got %d replicas", 3-n, replicas)
+		}
+		if len(writer.left) == 0 || writer.left[len(writer.left)-1] != want {
+			t.Fatalf("expected %s to be asked to leave, but got %v", want, writer.left)
+		}
+		client.Get(context.TODO(), req.NamespacedName, akkaCluster)
+		remaining := []appv1alpha1.AkkaClusterMemberStatus{}
+		for _, member := range akkaCluster.Status.Cluster.Members {
+			if management.NodeHost(member.Node) != want {
+				remaining = append(remaining, member)
+			}
+		}
+		akkaCluster.Status.Cluster.Members = remaining
+		client.Update(context.TODO(), akkaCluster)
+	}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	released := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), req.NamespacedName, released)
+	if hasFinalizer(released, teardownFinalizer) {
+		t.Errorf("expected finalizer to be released after members left")
+	}
+	if replicas := deploymentReplicas(); replicas != 0 {
+		t.Errorf("expected deployment scaled to zero once all members left, but got %d replicas", replicas)
+	}
+	pod := &corev1.Pod{}
+	client.Get(context.TODO(), types.NamespacedName{Namespace: name.Namespace, Name: "pod-3"}, pod)
+	if pod.Annotations[podDeletionCostAnnotation] == "" {
+		t.Errorf("expected pod of the first member to leave to be ranked first for deletion")
+	}
+}
+
+func TestTeardownScalesDownExitingMember(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	now := metav1.Now()
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Teardown = &appv1alpha1.AkkaClusterTeardownSpec{}
+	akkaCluster.Finalizers = []string{teardownFinalizer}
+	akkaCluster.DeletionTimestamp = &now
+	akkaCluster.Status = &appv1alpha1.AkkaClusterStatus{ManagementPort: 8558}
+	akkaCluster.Status.Cluster.Oldest = generateNodeAddress("10.0.0.1")
+	akkaCluster.Status.Cluster.Members = []appv1alpha1.AkkaClusterMemberStatus{
+		{Node: generateNodeAddress("10.0.0.1"), Status: "Up"},
+		{Node: generateNodeAddress("10.0.0.2"), Status: "Exiting"},
+	}
+	replicas := int32(2)
+	deployment := &appsv1.Deployment{}
+	deployment.Name = name.Name
+	deployment.Namespace = name.Namespace
+	deployment.Spec.Replicas = &replicas
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster, deployment)
+	writer := &testWriter{}
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(10), writer: writer}
+
+	// the exiting member's pod goes before it can restart, while teardown waits for it
+	res, err := r.Reconcile(reconcile.Request{NamespacedName: name})
+	if err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	if res.RequeueAfter != teardownPollInterval || len(writer.left) != 0 {
+		t.Errorf("expected to wait for the exiting member, but got %v and leaves %v", res, writer.left)
+	}
+	client.Get(context.TODO(), name, deployment)
+	if *deployment.Spec.Replicas != 1 {
+		t.Errorf("expected deployment scaled down past the exiting member, but got %d replicas", *deployment.Spec.Replicas)
+	}
+}
+
+func TestTeardownOptIn(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	akkaCluster.Spec.Teardown = &appv1alpha1.AkkaClusterTeardownSpec{}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(100)}
+	req := reconcile.Request{NamespacedName: name}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if !hasFinalizer(cluster, teardownFinalizer) {
+		t.Errorf("expected teardown finalizer when asked for, but got %v", cluster.Finalizers)
+	}
+
+	// turning teardown off lets go of the finalizer
+	cluster.Spec.Teardown = nil
+	client.Update(context.TODO(), cluster)
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	cluster = &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if hasFinalizer(cluster, teardownFinalizer) {
+		t.Errorf("expected teardown finalizer removed, but got %v", cluster.Finalizers)
+	}
+}
+
+func TestTeardownWaitsForStatusRead(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	now := metav1.Now()
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Teardown = &appv1alpha1.AkkaClusterTeardownSpec{}
+	akkaCluster.Finalizers = []string{teardownFinalizer}
+	akkaCluster.DeletionTimestamp = &now
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	actor := NewStatusActor(client, make(chan event.GenericEvent), record.NewFakeRecorder(100), DefaultOptions())
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(100),
+		writer: &testWriter{}, statusActor: actor}
+	req := reconcile.Request{NamespacedName: name}
+
+	// as after a restart of the operator, no members are known, as nothing was read yet
+	res, err := r.Reconcile(req)
+	if err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if res.RequeueAfter != teardownPollInterval || !hasFinalizer(cluster, teardownFinalizer) {
+		t.Errorf("expected to wait for status to be read, but got %v and finalizers %v", res, cluster.Finalizers)
+	}
+
+	// once read, no members means they all left
+	actor.recordRead(req)
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	cluster = &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if hasFinalizer(cluster, teardownFinalizer) {
+		t.Errorf("expected finalizer released once status was read, but got %v", cluster.Finalizers)
+	}
+}
+
+func TestPause(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	replicas := int32(3)
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Replicas = &replicas
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: recorder}
+	req := reconcile.Request{NamespacedName: name}
+	eventLoop := func() {
+		for limit := 10; limit > 0; limit-- {
+			res, err := r.Reconcile(req)
+			if err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+			if !res.Requeue {
+				return
+			}
+		}
+		t.Fatalf("reconcile didn't resolve within expected number of passes")
+	}
+	deploymentReplicas := func() int32 {
+		deployment := &appsv1.Deployment{}
+		client.Get(context.TODO(), req.NamespacedName, deployment)
+		return *deployment.Spec.Replicas
+	}
+	setPaused := func(paused bool) *appv1alpha1.AkkaCluster {
+		cluster := &appv1alpha1.AkkaCluster{}
+		client.Get(context.TODO(), req.NamespacedName, cluster)
+		cluster.Annotations = map[string]string{}
+		if paused {
+			cluster.Annotations[appv1alpha1.PausedAnnotation] = "true"
+		}
+		client.Update(context.TODO(), cluster)
+		eventLoop()
+		cluster = &appv1alpha1.AkkaCluster{}
+		client.Get(context.TODO(), req.NamespacedName, cluster)
+		return cluster
+	}
+	eventLoop()
+
+	// hand edit the deployment while paused
+	cluster := setPaused(true)
+	if !isConditionTrue(cluster.Status, appv1alpha1.AkkaClusterPaused) {
+		t.Errorf("expected paused condition, but got %#v", cluster.Status)
+	}
+	deployment := &appsv1.Deployment{}
+	client.Get(context.TODO(), req.NamespacedName, deployment)
+	*deployment.Spec.Replicas = 7
+	client.Update(context.TODO(), deployment)
+	eventLoop()
+	if deploymentReplicas() != 7 {
+		t.Errorf("expected hand edited replicas to be kept while paused, but got %d", deploymentReplicas())
+	}
+
+	// resume drift correction
+	cluster = setPaused(false)
+	if c := findCondition(cluster.Status, appv1alpha1.AkkaClusterPaused); c == nil || c.Status != corev1.ConditionFalse {
+		t.Errorf("expected paused condition to be false, but got %#v", cluster.Status)
+	}
+	if deploymentReplicas() != 3 {
+		t.Errorf("expected replicas to be reverted after resume, but got %d", deploymentReplicas())
+	}
+	events := []string{}
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	expected := []string{
+		"Warning Paused",
+		"Normal DriftDetected v1.Deployment differs from wanted: spec.replicas: wanted 3, actual 7",
+		"Normal Resumed",
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected paused, drift and resumed events, but got %v", events)
+	}
+	for i := range expected {
+		if !strings.HasPrefix(events[i], expected[i]) {
+			t.Errorf("expected event %q, but got %q", expected[i], events[i])
+		}
+	}
+}
+
+func TestServerSideApply(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Template.Annotations = map[string]string{"example.com/trace": "on"}
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(10)}
+	req := reconcile.Request{NamespacedName: name}
+	eventLoop := func() {
+		for limit := 10; limit > 0; limit-- {
+			res, err := r.Reconcile(req)
+			if err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+			if !res.Requeue {
+				return
+			}
+		}
+		t.Fatalf("reconcile didn't resolve within expected number of passes")
+	}
+	eventLoop()
+
+	for _, patch := range client.patches {
+		if patch.patchType != types.ApplyPatchType || patch.manager != fieldManager || !patch.force {
+			t.Errorf("expected resources applied by %s with force, but got %s by %q", fieldManager, patch.patchType, patch.manager)
+		}
+	}
+	applies := client.applies("Deployment", name.Name)
+	if len(applies) != 1 {
+		t.Fatalf("expected the deployment applied once, but got %d applies", len(applies))
+	}
+	if trace, _ := applies[0].field("spec", "template", "metadata", "annotations", "example.com/trace"); trace != "on" {
+		t.Errorf("expected pod template annotation applied, but got %v", applies[0].body)
+	}
+	hash, _ := applies[0].field("metadata", "annotations", appliedHashAnnotation)
+	if hash == "" {
+		t.Errorf("expected applied hash annotation, but got %v", applies[0].body)
+	}
+
+	// a field the operator stops setting is left out of the next apply, so the API server
+	// removes it, and the changed hash makes the apply happen
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), req.NamespacedName, cluster)
+	cluster.Spec.Template.Annotations = nil
+	client.Update(context.TODO(), cluster)
+	eventLoop()
+
+	applies = client.applies("Deployment", name.Name)
+	if len(applies) != 2 {
+		t.Fatalf("expected the deployment applied again, but got %d applies", len(applies))
+	}
+	if _, ok := applies[1].field("spec", "template", "metadata", "annotations", "example.com/trace"); ok {
+		t.Errorf("expected pod template annotation left out, but got %v", applies[1].body)
+	}
+	if changed, _ := applies[1].field("metadata", "annotations", appliedHashAnnotation); changed == hash {
+		t.Errorf("expected applied hash to change")
+	}
+}
+
+func TestOwnershipMigration(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(100)}
+	req := reconcile.Request{NamespacedName: name}
+	eventLoop := func() {
+		for limit := 10; limit > 0; limit-- {
+			res, err := r.Reconcile(req)
+			if err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+			if !res.Requeue {
+				return
+			}
+		}
+		t.Fatalf("reconcile didn't resolve within expected number of passes")
+	}
+	eventLoop()
+
+	// a Deployment as an operator without server-side apply left it: its fields owned by an
+	// update, which an apply would never remove
+	deployment := &appsv1.Deployment{}
+	client.Get(context.TODO(), req.NamespacedName, deployment)
+	delete(deployment.Annotations, appliedHashAnnotation)
+	deployment.ManagedFields = []metav1.ManagedFieldsEntry{
+		{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{}}`)}},
+		{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{}}`)}},
+	}
+	client.Update(context.TODO(), deployment)
+	read := &appsv1.Deployment{}
+	client.Get(context.TODO(), req.NamespacedName, read)
+	client.patches = nil
+	eventLoop()
+
+	// the update's fields move to the operator's apply first, guarded by the version read
+	if len(client.patches) != 2 || client.patches[0].patchType != types.MergePatchType || client.patches[1].patchType != types.ApplyPatchType {
+		t.Fatalf("expected a migration patch before the apply, but got %v", client.patches)
+	}
+	migration := client.patches[0]
+	if version, _ := migration.field("metadata", "resourceVersion"); version != read.ResourceVersion {
+		t.Errorf("expected migration guarded by resourceVersion %s, but got %s", read.ResourceVersion, version)
+	}
+	entries, _ := migration.body["metadata"].(map[string]interface{})["managedFields"].([]interface{})
+	operations := map[string]string{}
+	for _, entry := range entries {
+		e := entry.(map[string]interface{})
+		operations[fmt.Sprint(e["manager"])] = fmt.Sprint(e["operation"])
+	}
+	if operations[fieldManager] != "Apply" || operations["kubectl"] != "Update" {
+		t.Errorf("expected only the operator's update turned into its apply, but got %v", operations)
+	}
+
+	// once the operator's fields are owned by its apply, there's nothing to migrate
+	deployment = &appsv1.Deployment{}
+	client.Get(context.TODO(), req.NamespacedName, deployment)
+	delete(deployment.Annotations, appliedHashAnnotation)
+	client.Update(context.TODO(), deployment)
+	client.patches = nil
+	eventLoop()
+	if len(client.patches) != 1 || client.patches[0].patchType != types.ApplyPatchType {
+		t.Errorf("expected only an apply, but got %v", client.patches)
+	}
+}
+
+func TestIgnoredFields(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	replicas := int32(3)
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Replicas = &replicas
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	akkaCluster.Spec.IgnoredFields = []string{"spec.replicas", "spec.template.spec.containers[*].resources", "spec..oops", "rules"}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	recorder := record.NewFakeRecorder(100)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: recorder}
+	req := reconcile.Request{NamespacedName: name}
+	eventLoop := func() {
+		for limit := 10; limit > 0; limit-- {
+			res, err := r.Reconcile(req)
+			if err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+			if !res.Requeue {
+				return
+			}
+		}
+		t.Fatalf("reconcile didn't resolve within expected number of passes")
+	}
+	getDeployment := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		client.Get(context.TODO(), req.NamespacedName, deployment)
+		return deployment
+	}
+	eventLoop()
+
+	// ignored fields are seeded on create
+	applies := client.applies("Deployment", name.Name)
+	if len(applies) != 1 {
+		t.Fatalf("expected the deployment applied once, but got %d applies", len(applies))
+	}
+	if replicas, _ := applies[0].field("spec", "replicas"); replicas != "3" {
+		t.Errorf("expected three replicas on create, but got %v", applies[0].body)
+	}
+
+	// an autoscaler takes over replicas and resources, which the operator still owns from
+	// the create, as the API server would record it
+	deployment := getDeployment()
+	*deployment.Spec.Replicas = 5
+	deployment.Spec.Template.Spec.Containers[0].Resources.Limits = corev1.ResourceList{
+		corev1.ResourceCPU: resource.MustParse("2"),
+	}
+	deployment.ManagedFields = []metav1.ManagedFieldsEntry{{
+		Manager:   fieldManager,
+		Operation: metav1.ManagedFieldsOperationApply,
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{},"f:template":{"f:spec":{"f:containers":{` +
+			`"k:{\"name\":\"main\"}":{"f:image":{},"f:name":{},"f:resources":{}}}}}}}`)},
+	}}
+	client.Update(context.TODO(), deployment)
+
+	// the rest of the spec stays enforced
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), req.NamespacedName, cluster)
+	cluster.Spec.Template.Spec.Containers[0].Image = "akka-cluster:1.0.1"
+	client.Update(context.TODO(), cluster)
+	client.patches = nil
+	eventLoop()
+
+	// the values read are handed off without force, so the API server conflicts rather than
+	// write them back over a change made since, and then the operator applies without them
+	if len(client.patches) != 2 {
+		t.Fatalf("expected a hand-off and an apply, but got %v", client.patches)
+	}
+	handOff, apply := client.patches[0], client.patches[1]
+	if handOff.manager != ignoredFieldManager || handOff.force {
+		t.Errorf("expected ignored fields handed off to %s without force, but got %q force %v", ignoredFieldManager, handOff.manager, handOff.force)
+	}
+	if replicas, _ := handOff.field("spec", "replicas"); replicas != "5" {
+		t.Errorf("expected the replicas read handed off, but got %v", handOff.body)
+	}
+	if cpu, _ := handOff.field("spec", "template", "spec", "containers", "name=main", "resources", "limits", "cpu"); cpu != "2" {
+		t.Errorf("expected the cpu limit read handed off, but got %v", handOff.body)
+	}
+	if _, ok := handOff.field("spec", "template", "spec", "containers", "name=main", "image"); ok {
+		t.Errorf("expected only ignored fields handed off, but got %v", handOff.body)
+	}
+	if apply.manager != fieldManager || !apply.force {
+		t.Errorf("expected an apply by %s with force, but got %q force %v", fieldManager, apply.manager, apply.force)
+	}
+	if _, ok := apply.field("spec", "replicas"); ok {
+		t.Errorf("expected replicas left out of the apply, but got %v", apply.body)
+	}
+	if _, ok := apply.field("spec", "template", "spec", "containers", "name=main", "resources"); ok {
+		t.Errorf("expected resources left out of the apply, but got %v", apply.body)
+	}
+	if image, _ := apply.field("spec", "template", "spec", "containers", "name=main", "image"); image != "akka-cluster:1.0.1" {
+		t.Errorf("expected image to be updated, but got %v", apply.body)
+	}
+	if replicas := *getDeployment().Spec.Replicas; replicas != 5 {
+		t.Errorf("expected autoscaled replicas to be kept, but got %d", replicas)
+	}
+
+	// once the operator no longer owns them, there is nothing to hand off
+	deployment = getDeployment()
+	deployment.ManagedFields = []metav1.ManagedFieldsEntry{{
+		Manager:   fieldManager,
+		Operation: metav1.ManagedFieldsOperationApply,
+		FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{}}}`)},
+	}}
+	client.Update(context.TODO(), deployment)
+	cluster = &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), req.NamespacedName, cluster)
+	cluster.Spec.Template.Spec.Containers[0].Image = "akka-cluster:1.0.2"
+	client.Update(context.TODO(), cluster)
+	client.patches = nil
+	eventLoop()
+	if len(client.patches) != 1 || client.patches[0].manager != fieldManager {
+		t.Errorf("expected only the operator's apply, but got %v", client.patches)
+	}
+
+	invalid := 0
+	for len(recorder.Events) > 0 {
+		e := <-recorder.Events
+		if strings.Contains(e, "spec.replicas") || strings.Contains(e, "resources") {
+			t.Errorf("expected no drift reported on ignored fields, but got %s", e)
+		}
+		if strings.HasPrefix(e, "Warning InvalidIgnoredField") {
+			invalid++
+		}
+	}
+	if invalid == 0 {
+		t.Errorf("expected invalid ignored field to be reported")
+	}
+
+	// paths are in the Deployment only, so the Role stays enforced
+	role := &rbac.Role{}
+	client.Get(context.TODO(), req.NamespacedName, role)
+	role.Rules[0].Verbs = []string{"*"}
+	client.Update(context.TODO(), role)
+	eventLoop()
+	role = &rbac.Role{}
+	client.Get(context.TODO(), req.NamespacedName, role)
+	if !apiequality.Semantic.DeepEqual(role.Rules, []rbac.PolicyRule{podReaderRule()}) {
+		t.Errorf("expected the Role to be reverted despite the ignored rules path, but got %v", role.Rules)
+	}
+}
+
+// failingClient fails applies of one kind of resource.
+type failingClient struct {
+	*applyClient
+	kind string
+}
+
+func (c *failingClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() == types.ApplyPatchType && obj.GetObjectKind().GroupVersionKind().Kind == c.kind {
+		return fmt.Errorf("%s is not allowed", c.kind)
+	}
+	return c.applyClient.Patch(ctx, obj, patch, opts...)
+}
+
+func TestOnePassReconcile(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := &failingClient{applyClient: newApplyClient(scheme, akkaCluster), kind: "Role"}
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(10)}
+	req := reconcile.Request{NamespacedName: name}
+
+	// one failing resource doesn't hold up the others
+	if _, err := r.Reconcile(req); err == nil || !strings.Contains(err.Error(), "Role is not allowed") {
+		t.Errorf("expected role error, but got %v", err)
+	}
+	for _, obj := range []runtime.Object{&corev1.ServiceAccount{}, &rbac.RoleBinding{}, &appsv1.Deployment{}} {
+		if err := client.Get(context.TODO(), req.NamespacedName, obj); err != nil {
+			t.Errorf("expected %T to be created in the same pass, but got %v", obj, err)
+		}
+	}
+
+	// with everything created in one pass, a single requeue confirms it
+	client.kind = ""
+	res, err := r.Reconcile(req)
+	if err != nil || !res.Requeue {
+		t.Fatalf("expected requeue after creating role, but got %v, %v", res, err)
+	}
+	if res, err := r.Reconcile(req); err != nil || res.Requeue {
+		t.Errorf("expected reconcile to settle, but got %v, %v", res, err)
+	}
+}
+
+func TestSelectorKeyChange(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(100)}
+	req := reconcile.Request{NamespacedName: name}
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(req); err != nil {
+			t.Fatalf("reconcile: (%v)", err)
+		}
+	}
+
+	// a new default key leaves the existing Deployment on the selector it was created with
+	r.options.Deployment.SelectorKey = "app.kubernetes.io/name"
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(req); err != nil {
+			t.Fatalf("reconcile after selector key change: (%v)", err)
+		}
+	}
+	deployment := &appsv1.Deployment{}
+	if err := client.Get(context.TODO(), name, deployment); err != nil {
+		t.Fatalf("get deployment: (%v)", err)
+	}
+	want := map[string]string{"app": name.Name}
+	if !apiequality.Semantic.DeepEqual(deployment.Spec.Selector.MatchLabels, want) {
+		t.Errorf("expected selector %v to be kept, but got %v", want, deployment.Spec.Selector.MatchLabels)
+	}
+	if deployment.Spec.Template.Labels["app"] != name.Name {
+		t.Errorf("expected pods to keep label app, but got %v", deployment.Spec.Template.Labels)
+	}
+
+	// new clusters get the new key
+	fresh := &appv1alpha1.AkkaCluster{}
+	fresh.Name = "akka-cluster-fresh"
+	fresh.Namespace = name.Namespace
+	fresh.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	if err := client.Create(context.TODO(), fresh); err != nil {
+		t.Fatalf("create cluster: (%v)", err)
+	}
+	freshName := types.NamespacedName{Namespace: fresh.Namespace, Name: fresh.Name}
+	if _, err := r.Reconcile(reconcile.Request{NamespacedName: freshName}); err != nil {
+		t.Fatalf("reconcile new cluster: (%v)", err)
+	}
+	deployment = &appsv1.Deployment{}
+	if err := client.Get(context.TODO(), freshName, deployment); err != nil {
+		t.Fatalf("get new deployment: (%v)", err)
+	}
+	want = map[string]string{"app.kubernetes.io/name": fresh.Name}
+	if !apiequality.Semantic.DeepEqual(deployment.Spec.Selector.MatchLabels, want) {
+		t.Errorf("expected new cluster selector %v, but got %v", want, deployment.Spec.Selector.MatchLabels)
+	}
+}
+
+func TestDeleteUnwanted(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.UID = "akka-cluster-uid"
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+
+	// a role in the same namespace that the AkkaCluster does not control
+	unowned := &rbac.Role{}
+	unowned.Name = "unowned"
+	unowned.Namespace = name.Namespace
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster, unowned)
+	recorder := record.NewFakeRecorder(100)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: recorder}
+	req := reconcile.Request{NamespacedName: name}
+	eventLoop := func() {
+		for limit := 10; limit > 0; limit-- {
+			res, err := r.Reconcile(req)
+			if err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+			if !res.Requeue {
+				return
+			}
+		}
+		t.Fatalf("reconcile didn't resolve within expected number of passes")
+	}
+	eventLoop()
+	if err := client.Get(context.TODO(), req.NamespacedName, &rbac.Role{}); err != nil {
+		t.Fatalf("expected default role, but got %v", err)
+	}
+
+	// switching to a custom ServiceAccount drops the default RBAC
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), req.NamespacedName, cluster)
+	cluster.Spec.Template.Spec.ServiceAccountName = "custom"
+	client.Update(context.TODO(), cluster)
+	eventLoop()
+
+	for _, obj := range []runtime.Object{&corev1.ServiceAccount{}, &rbac.Role{}, &rbac.RoleBinding{}} {
+		if err := client.Get(context.TODO(), req.NamespacedName, obj); !errors.IsNotFound(err) {
+			t.Errorf("expected %T to be deleted, but got %v", obj, err)
+		}
+	}
+	deployment := &appsv1.Deployment{}
+	if err := client.Get(context.TODO(), req.NamespacedName, deployment); err != nil {
+		t.Errorf("expected deployment to be kept, but got %v", err)
+	}
+	if deployment.Spec.Template.Spec.ServiceAccountName != "custom" {
+		t.Errorf("expected custom service account, but got %s", deployment.Spec.Template.Spec.ServiceAccountName)
+	}
+	if err := client.Get(context.TODO(), types.NamespacedName{Namespace: name.Namespace, Name: "unowned"}, &rbac.Role{}); err != nil {
+		t.Errorf("expected unowned role to be kept, but got %v", err)
+	}
+	deleted := 0
+	for len(recorder.Events) > 0 {
+		if strings.Contains(<-recorder.Events, "UnwantedResourceDeleted") {
+			deleted++
+		}
+	}
+	if deleted != 3 {
+		t.Errorf("expected three deletion events, but got %d", deleted)
+	}
+}
+
+func TestNameCollision(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.UID = "akka-cluster-uid"
+	akkaCluster.Spec.Template.Spec.ServiceAccountName = "custom"
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+
+	// someone else's deployment, already using the name
+	existing := &appsv1.Deployment{}
+	existing.Name = name.Name
+	existing.Namespace = name.Namespace
+	existing.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "someone-else:1.0.0"}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster, existing)
+	recorder := record.NewFakeRecorder(100)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: recorder}
+	req := reconcile.Request{NamespacedName: name}
+	eventLoop := func() reconcile.Result {
+		for limit := 10; limit > 0; limit-- {
+			res, err := r.Reconcile(req)
+			if err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+			if !res.Requeue {
+				return res
+			}
+		}
+		t.Fatalf("reconcile didn't resolve within expected number of passes")
+		return reconcile.Result{}
+	}
+	getCluster := func() *appv1alpha1.AkkaCluster {
+		cluster := &appv1alpha1.AkkaCluster{}
+		client.Get(context.TODO(), req.NamespacedName, cluster)
+		return cluster
+	}
+	getDeployment := func(name string) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		client.Get(context.TODO(), types.NamespacedName{Namespace: req.Namespace, Name: name}, deployment)
+		return deployment
+	}
+
+	// by default the existing deployment is left alone
+	if res := eventLoop(); res.RequeueAfter != collisionRetryInterval {
+		t.Errorf("expected collision to be retried, but got %v", res)
+	}
+	if image := getDeployment(name.Name).Spec.Template.Spec.Containers[0].Image; image != "someone-else:1.0.0" {
+		t.Errorf("expected existing deployment untouched, but got image %s", image)
+	}
+	if c := findCondition(getCluster().Status, appv1alpha1.AkkaClusterNameCollision); c == nil || c.Status != corev1.ConditionTrue {
+		t.Errorf("expected name collision condition, but got %#v", getCluster().Status)
+	}
+
+	// resources controlled by something else are never adopted
+	controller := true
+	other := &appsv1.Deployment{}
+	other.Name = "app-" + name.Name
+	other.Namespace = name.Namespace
+	other.OwnerReferences = []metav1.OwnerReference{{Kind: "Other", Name: "other", UID: "other-uid", Controller: &controller}}
+	client.Create(context.TODO(), other)
+	cluster := getCluster()
+	cluster.Spec.GeneratedResources = &appv1alpha1.AkkaClusterGeneratedResourcesSpec{
+		NamePrefix:     "app-",
+		AdoptionPolicy: appv1alpha1.AdoptIfUnowned,
+	}
+	client.Update(context.TODO(), cluster)
+	eventLoop()
+	if c := findCondition(getCluster().Status, appv1alpha1.AkkaClusterNameCollision); c == nil || c.Status != corev1.ConditionTrue ||
+		!strings.Contains(c.Message, "controlled by Other other") {
+		t.Errorf("expected collision with controlled deployment, but got %#v", getCluster().Status)
+	}
+	if getDeployment(other.Name).Spec.Template.Spec.Containers != nil {
+		t.Errorf("expected controlled deployment to be untouched")
+	}
+
+	// adopting takes over unowned resources
+	cluster = getCluster()
+	cluster.Spec.GeneratedResources.NamePrefix = ""
+	client.Update(context.TODO(), cluster)
+	eventLoop()
+	adopted := getDeployment(name.Name)
+	if !metav1.IsControlledBy(adopted, cluster) || adopted.Spec.Template.Spec.Containers[0].Image != "akka-cluster:1.0.0" {
+		t.Errorf("expected deployment to be adopted, but got %#v", adopted.ObjectMeta)
+	}
+	if c := findCondition(getCluster().Status, appv1alpha1.AkkaClusterNameCollision); c == nil || c.Status != corev1.ConditionFalse {
+		t.Errorf("expected resolved name collision condition, but got %#v", getCluster().Status)
+	}
+}
+
+func TestNameChangeRefused(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.UID = "akka-cluster-uid"
+	akkaCluster.Spec.GeneratedResources = &appv1alpha1.AkkaClusterGeneratedResourcesSpec{NamePrefix: "team-a-"}
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(100)}
+	req := reconcile.Request{NamespacedName: name}
+	reconcileWith := func(prefix, suffix string) *appv1alpha1.AkkaCluster {
+		cluster := &appv1alpha1.AkkaCluster{}
+		client.Get(context.TODO(), name, cluster)
+		cluster.Spec.GeneratedResources.NamePrefix = prefix
+		cluster.Spec.GeneratedResources.NameSuffix = suffix
+		client.Update(context.TODO(), cluster)
+		for i := 0; i < 3; i++ {
+			if _, err := r.Reconcile(req); err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+		}
+		cluster = &appv1alpha1.AkkaCluster{}
+		client.Get(context.TODO(), name, cluster)
+		return cluster
+	}
+	names := func(list runtime.Object) []string {
+		client.List(context.TODO(), list)
+		items, _ := meta.ExtractList(list)
+		names := []string{}
+		for _, item := range items {
+			object, _ := meta.Accessor(item)
+			names = append(names, object.GetName())
+		}
+		return names
+	}
+
+	cluster := reconcileWith("team-a-", "")
+	if deployments := names(&appsv1.DeploymentList{}); len(deployments) != 1 || deployments[0] != "team-a-"+name.Name {
+		t.Fatalf("expected deployment with prefix, but got %v", deployments)
+	}
+
+	// renaming would form a second Akka cluster, so every resource keeps its name
+	cluster = reconcileWith("", "-app")
+	if deployments := names(&appsv1.DeploymentList{}); len(deployments) != 1 || deployments[0] != "team-a-"+name.Name {
+		t.Errorf("expected deployment to keep its name, but got %v", deployments)
+	}
+	if roles := names(&rbac.RoleList{}); len(roles) != 1 || roles[0] != "team-a-"+name.Name {
+		t.Errorf("expected role to keep its name, but got %v", roles)
+	}
+	condition := findCondition(cluster.Status, appv1alpha1.AkkaClusterNameChangeRefused)
+	if condition == nil || condition.Status != corev1.ConditionTrue || !strings.Contains(condition.Message, name.Name+"-app") {
+		t.Errorf("expected NameChangeRefused condition naming the refused name, but got %+v", condition)
+	}
+	if cluster.Spec.GeneratedResources.NameSuffix != "-app" {
+		t.Errorf("expected the stored spec to be left as written, but got %#v", cluster.Spec.GeneratedResources)
+	}
+
+	// changing it back clears the condition
+	cluster = reconcileWith("team-a-", "")
+	if isConditionTrue(cluster.Status, appv1alpha1.AkkaClusterNameChangeRefused) {
+		t.Errorf("expected NameChangeRefused condition to be false, but got %+v", cluster.Status.Conditions)
+	}
+}
+
+func TestAdoptDeployment(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.UID = "akka-cluster-uid"
+	akkaCluster.Annotations = map[string]string{appv1alpha1.AdoptDeploymentAnnotation: name.Name}
+
+	// a plain deployment with its own RBAC, as deployed before the operator
+	replicas := int32(5)
+	existing := &appsv1.Deployment{}
+	existing.Name = name.Name
+	existing.Namespace = name.Namespace
+	existing.Spec.Replicas = &replicas
+	existing.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "legacy"}}
+	existing.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+	existing.Spec.Template.Labels = map[string]string{"app": "legacy"}
+	existing.Spec.Template.Spec.ServiceAccountName = name.Name
+	existing.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "legacy:1.0.0"}}
+	serviceAccount := &corev1.ServiceAccount{}
+	serviceAccount.Name = name.Name
+	serviceAccount.Namespace = name.Namespace
+	configMapRule := rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}
+	role := &rbac.Role{}
+	role.Name = name.Name
+	role.Namespace = name.Namespace
+	role.Rules = []rbac.PolicyRule{podReaderRule(), configMapRule}
+	roleBinding := &rbac.RoleBinding{}
+	roleBinding.Name = name.Name
+	roleBinding.Namespace = name.Namespace
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster, existing, serviceAccount, role, roleBinding)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(100)}
+	req := reconcile.Request{NamespacedName: name}
+	for limit := 10; limit > 0; limit-- {
+		res, err := r.Reconcile(req)
+		if err != nil {
+			t.Fatalf("reconcile error: %v", err)
+		}
+		if !res.Requeue {
+			break
+		}
+	}
+
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), req.NamespacedName, cluster)
+	if _, ok := cluster.Annotations[appv1alpha1.AdoptDeploymentAnnotation]; ok {
+		t.Errorf("expected adopt annotation to be removed")
+	}
+	if *cluster.Spec.Replicas != 5 || cluster.Spec.Template.Spec.Containers[0].Image != "legacy:1.0.0" {
+		t.Errorf("expected spec derived from deployment, but got %#v", cluster.Spec)
+	}
+	if cluster.Spec.RBAC == nil || len(cluster.Spec.RBAC.ExtraRules) != 1 ||
+		!apiequality.Semantic.DeepEqual(cluster.Spec.RBAC.ExtraRules[0], configMapRule) {
+		t.Errorf("expected existing role rules kept as extra rules, but got %#v", cluster.Spec.RBAC)
+	}
+	if _, ok := cluster.Annotations[adoptionPendingAnnotation]; ok || cluster.Spec.GeneratedResources.AdoptionPolicy != "" {
+		t.Errorf("expected adoption policy cleared once adopted, but got %q", cluster.Spec.GeneratedResources.AdoptionPolicy)
+	}
+
+	deployment := &appsv1.Deployment{}
+	client.Get(context.TODO(), req.NamespacedName, deployment)
+	if !apiequality.Semantic.DeepEqual(deployment.Spec.Template, existing.Spec.Template) {
+		t.Errorf("expected pod template to be unchanged, but got %#v", deployment.Spec.Template)
+	}
+	for _, obj := range []runtime.Object{deployment, &corev1.ServiceAccount{}, &rbac.Role{}, &rbac.RoleBinding{}} {
+		client.Get(context.TODO(), req.NamespacedName, obj)
+		objMeta, _ := meta.Accessor(obj)
+		if !metav1.IsControlledBy(objMeta, cluster) {
+			t.Errorf("expected %T to be adopted", obj)
+		}
+	}
+	// with no grantable rules configured, the adopted rules are kept all the same
+	adopted := &rbac.Role{}
+	client.Get(context.TODO(), req.NamespacedName, adopted)
+	if !hasRule(adopted.Rules, configMapRule) {
+		t.Errorf("expected adopted role to keep its rules, but got %v", adopted.Rules)
+	}
+	if condition := findCondition(cluster.Status, appv1alpha1.AkkaClusterRulesRefused); condition != nil {
+		t.Errorf("expected adopted rules not to be refused, but got %v", condition)
+	}
+
+	// listing a rule the adopted Role never had as adopted doesn't grant it
+	secretRule := rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}
+	cluster.Spec.RBAC.ExtraRules = append(cluster.Spec.RBAC.ExtraRules, secretRule)
+	cluster.Annotations[adoptedRulesAnnotation] = `[{"apiGroups":[""],"resources":["configmaps"],"verbs":["get"]},` +
+		`{"apiGroups":[""],"resources":["secrets"],"verbs":["get"]}]`
+	client.Update(context.TODO(), cluster)
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	adopted = &rbac.Role{}
+	client.Get(context.TODO(), req.NamespacedName, adopted)
+	if hasRule(adopted.Rules, secretRule) || !hasRule(adopted.Rules, configMapRule) {
+		t.Errorf("expected only the rules of the adopted role kept, but got %v", adopted.Rules)
+	}
+}
+
+func TestAkkaClusterClass(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.ClassName = "jvm"
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name:  "main",
+		Image: "akka-cluster:1.0.0",
+		Env:   []corev1.EnvVar{{Name: "JAVA_OPTS", Value: "-Xmx1g"}},
+	}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster, &appv1alpha1.AkkaClusterList{}, &appv1alpha1.AkkaClusterClass{})
+	client := newApplyClient(scheme, akkaCluster)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, recorder: record.NewFakeRecorder(10)}
+	req := reconcile.Request{NamespacedName: name}
+	eventLoop := func() {
+		for limit := 10; limit > 0; limit-- {
+			res, err := r.Reconcile(req)
+			if err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+			if !res.Requeue {
+				return
+			}
+		}
+		t.Fatalf("reconcile didn't resolve within expected number of passes")
+	}
+	mainContainer := func() (*corev1.Container, error) {
+		deployment := &appsv1.Deployment{}
+		if err := client.Get(context.TODO(), name, deployment); err != nil {
+			return nil, err
+		}
+		return &deployment.Spec.Template.Spec.Containers[0], nil
+	}
+
+	// nothing is generated until the class exists
+	eventLoop()
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if !isConditionTrue(cluster.Status, appv1alpha1.AkkaClusterClassNotFound) {
+		t.Errorf("expected ClassNotFound condition, but got %#v", cluster.Status)
+	}
+	if _, err := mainContainer(); !errors.IsNotFound(err) {
+		t.Errorf("expected no Deployment without the class, but got %v", err)
+	}
+
+	class := &appv1alpha1.AkkaClusterClass{}
+	class.Name = "jvm"
+	class.Namespace = name.Namespace
+	class.Spec.Template.Spec.Tolerations = []corev1.Toleration{{Key: "akka", Operator: corev1.TolerationOpExists}}
+	class.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name: "main",
+		Env: []corev1.EnvVar{
+			{Name: "JAVA_OPTS", Value: "-Xmx512m"},
+			{Name: "AKKA_LOGLEVEL", Value: "INFO"},
+		},
+		Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")}},
+	}}
+	if requests := r.clustersOfClass(handler.MapObject{Meta: class, Object: class}); len(requests) != 1 || requests[0] != req {
+		t.Errorf("expected class to map to its AkkaCluster, but got %v", requests)
+	}
+	client.Create(context.TODO(), class)
+	eventLoop()
+	container, err := mainContainer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{}
+	for _, e := range container.Env {
+		env[e.Name] = e.Value
+	}
+	if env["JAVA_OPTS"] != "-Xmx1g" || env["AKKA_LOGLEVEL"] != "INFO" || container.Image != "akka-cluster:1.0.0" {
+		t.Errorf("expected class env merged under the AkkaCluster's own, but got %v", container)
+	}
+	if !container.Resources.Limits.Memory().Equal(resource.MustParse("2Gi")) {
+		t.Errorf("expected resources from the class, but got %v", container.Resources)
+	}
+	cluster = &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if c := findCondition(cluster.Status, appv1alpha1.AkkaClusterClassNotFound); c == nil || c.Status != corev1.ConditionFalse {
+		t.Errorf("expected ClassNotFound condition to be false, but got %#v", cluster.Status)
+	}
+	if len(cluster.Spec.Template.Spec.Tolerations) != 0 || len(cluster.Spec.Template.Spec.Containers[0].Env) != 1 {
+		t.Errorf("expected the effective spec not to be written back, but got %#v", cluster.Spec)
+	}
+
+	// a change to the class rolls the Deployment
+	client.Get(context.TODO(), types.NamespacedName{Namespace: name.Namespace, Name: "jvm"}, class)
+	class.Spec.Template.Spec.Containers[0].Resources.Limits[corev1.ResourceMemory] = resource.MustParse("4Gi")
+	client.Update(context.TODO(), class)
+	eventLoop()
+	if container, _ := mainContainer(); !container.Resources.Limits.Memory().Equal(resource.MustParse("4Gi")) {
+		t.Errorf("expected changed class to be applied, but got %v", container.Resources)
+	}
+}
+
+func TestLeaseMajority(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.SplitBrain = &appv1alpha1.AkkaClusterSplitBrainSpec{
+		Resolver: &appv1alpha1.AkkaClusterSplitBrainResolverSpec{Strategy: appv1alpha1.LeaseMajority},
+	}
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	mapper := meta.NewDefaultRESTMapper(nil)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, mapper: mapper, recorder: record.NewFakeRecorder(10)}
+	req := reconcile.Request{NamespacedName: name}
+	reconcileCluster := func() (reconcile.Result, *appv1alpha1.AkkaCluster) {
+		var res reconcile.Result
+		for limit := 10; limit > 0; limit-- {
+			var err error
+			if res, err = r.Reconcile(req); err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+			if !res.Requeue {
+				break
+			}
+		}
+		cluster := &appv1alpha1.AkkaCluster{}
+		client.Get(context.TODO(), name, cluster)
+		return res, cluster
+	}
+
+	// without the Lease CRD, resources are generated, but the cluster is flagged
+	res, cluster := reconcileCluster()
+	if !isConditionTrue(cluster.Status, appv1alpha1.AkkaClusterLeaseUnavailable) {
+		t.Errorf("expected LeaseUnavailable condition, but got %#v", cluster.Status)
+	}
+	if res.RequeueAfter != leaseRetryInterval {
+		t.Errorf("expected the CRD to be checked again, but got %v", res)
+	}
+	role := &rbac.Role{}
+	if err := client.Get(context.TODO(), name, role); err != nil {
+		t.Fatal(err)
+	}
+	if len(role.Rules) != 2 || role.Rules[1].APIGroups[0] != "akka.io" || role.Rules[1].Resources[0] != "leases" {
+		t.Errorf("expected Role to grant leases, but got %v", role.Rules)
+	}
+	deployment := &appsv1.Deployment{}
+	client.Get(context.TODO(), name, deployment)
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	if len(env) != 2 || env[1].Name != javaToolOptionsEnv || !strings.Contains(env[1].Value, "active-strategy=lease-majority") {
+		t.Errorf("expected resolver options in JAVA_TOOL_OPTIONS, but got %v", env)
+	}
+
+	// once installed, the condition clears
+	mapper.Add(leaseGroupVersionKind, meta.RESTScopeNamespace)
+	res, cluster = reconcileCluster()
+	if c := findCondition(cluster.Status, appv1alpha1.AkkaClusterLeaseUnavailable); c == nil || c.Status != corev1.ConditionFalse {
+		t.Errorf("expected LeaseUnavailable condition to be false, but got %#v", cluster.Status)
+	}
+	if res.RequeueAfter != 0 {
+		t.Errorf("expected no recheck once the CRD is installed, but got %v", res)
+	}
+
+	// options taken from a ConfigMap can't be added to, which is reported
+	recorder := record.NewFakeRecorder(10)
+	r.recorder = recorder
+	cluster.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{
+		Name: javaToolOptionsEnv,
+		ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "jvm"}, Key: "options",
+		}},
+	}}
+	client.Update(context.TODO(), cluster)
+	_, cluster = reconcileCluster()
+	c := findCondition(cluster.Status, appv1alpha1.AkkaClusterLeaseUnavailable)
+	if c == nil || c.Status != corev1.ConditionTrue || c.Reason != "OptionsNotSet" || !strings.Contains(c.Message, "main") {
+		t.Errorf("expected LeaseUnavailable condition naming the container, but got %#v", c)
+	}
+	found := false
+	for len(recorder.Events) > 0 {
+		if strings.HasPrefix(<-recorder.Events, "Warning OptionsNotSet") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected an OptionsNotSet Warning")
+	}
+}
+
+func TestAddResolverOptions(t *testing.T) {
+	container := &corev1.Container{Env: []corev1.EnvVar{{Name: javaToolOptionsEnv, Value: "-Xmx1g"}}}
+	addResolverOptions(container, "demo-akka-sbr")
+	if value := container.Env[0].Value; !strings.HasPrefix(value, "-Xmx1g -Dakka.cluster.downing-provider-class") {
+		t.Errorf("expected options appended to existing ones, but got %q", value)
+	}
+	if value := container.Env[0].Value; !strings.HasSuffix(value, " "+leaseNameOption+"demo-akka-sbr") {
+		t.Errorf("expected the lease name passed on, but got %q", value)
+	}
+	own := "-Dakka.cluster.split-brain-resolver.active-strategy=keep-majority"
+	container = &corev1.Container{Env: []corev1.EnvVar{{Name: javaToolOptionsEnv, Value: own}}}
+	addResolverOptions(container, "demo-akka-sbr")
+	if container.Env[0].Value != own {
+		t.Errorf("expected a container picking its own strategy to be left alone, but got %q", container.Env[0].Value)
+	}
+}
+
+func TestClusterRBAC(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	configMapRule := rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}
+	podRule := rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}}
+	akkaCluster.Spec.RBAC = &appv1alpha1.AkkaClusterRBACSpec{
+		ExtraRules:   []rbac.PolicyRule{configMapRule},
+		ClusterRules: []rbac.PolicyRule{podRule},
+	}
+	options := DefaultOptions()
+	options.ClusterRBAC = true
+	options.GrantableRules = []rbac.PolicyRule{configMapRule, podRule}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	r := &ReconcileAkkaCluster{options: options, client: client, scheme: scheme, recorder: record.NewFakeRecorder(10)}
+	req := reconcile.Request{NamespacedName: name}
+	eventLoop := func() {
+		for limit := 10; limit > 0; limit-- {
+			res, err := r.Reconcile(req)
+			if err != nil {
+				t.Fatalf("reconcile error: %v", err)
+			}
+			if !res.Requeue {
+				return
+			}
+		}
+		t.Fatalf("reconcile didn't resolve within expected number of passes")
+	}
+	clusterName := types.NamespacedName{Name: name.Namespace + "-" + name.Name}
+
+	eventLoop()
+	role := &rbac.Role{}
+	if err := client.Get(context.TODO(), name, role); err != nil {
+		t.Fatal(err)
+	}
+	if len(role.Rules) != 2 || !apiequality.Semantic.DeepEqual(role.Rules[1], configMapRule) {
+		t.Errorf("expected extra rules added to the Role, but got %v", role.Rules)
+	}
+	clusterRole := &rbac.ClusterRole{}
+	if err := client.Get(context.TODO(), clusterName, clusterRole); err != nil {
+		t.Fatal(err)
+	}
+	if len(clusterRole.Rules) != 1 || len(clusterRole.OwnerReferences) != 0 ||
+		clusterRole.Labels[ownerNamespaceLabel] != name.Namespace || clusterRole.Labels[ownerNameLabel] != name.Name ||
+		clusterRole.Annotations[ownerNameAnnotation] != name.Name {
+		t.Errorf("expected ClusterRole with cluster rules and owner labels, but got %#v", clusterRole)
+	}
+	binding := &rbac.ClusterRoleBinding{}
+	if err := client.Get(context.TODO(), clusterName, binding); err != nil {
+		t.Fatal(err)
+	}
+	if binding.RoleRef.Name != clusterName.Name || len(binding.Subjects) != 1 || binding.Subjects[0].Namespace != name.Namespace {
+		t.Errorf("expected ClusterRoleBinding to the namespaced ServiceAccount, but got %#v", binding)
+	}
+	requests := ownerOfClusterScoped(handler.MapObject{Meta: clusterRole, Object: clusterRole})
+	if len(requests) != 1 || requests[0] != req {
+		t.Errorf("expected ClusterRole to map to its AkkaCluster, but got %v", requests)
+	}
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if !hasFinalizer(cluster, clusterRBACFinalizer) {
+		t.Errorf("expected cluster RBAC finalizer, but got %v", cluster.Finalizers)
+	}
+
+	// drift is put back like on namespaced resources
+	clusterRole.Rules[0].Resources = []string{"secrets"}
+	client.Update(context.TODO(), clusterRole)
+	eventLoop()
+	clusterRole = &rbac.ClusterRole{}
+	client.Get(context.TODO(), clusterName, clusterRole)
+	if len(clusterRole.Rules) != 1 || !apiequality.Semantic.DeepEqual(clusterRole.Rules[0], podRule) {
+		t.Errorf("expected drifted ClusterRole to be reconciled, but got %v", clusterRole.Rules)
+	}
+
+	// a ClusterRole of the same name for another AkkaCluster is left alone
+	other := &appv1alpha1.AkkaCluster{}
+	other.Name = "other"
+	other.Namespace = name.Namespace
+	other.Spec.GeneratedResources = &appv1alpha1.AkkaClusterGeneratedResourcesSpec{AdoptionPolicy: appv1alpha1.AdoptIfUnowned}
+	if err := r.checkClusterScopedOwnership(other, "ClusterRole", clusterRole); err == nil {
+		t.Errorf("expected collision with a ClusterRole of another AkkaCluster")
+	}
+
+	// deletion removes the cluster-scoped resources, then the finalizer
+	client.Get(context.TODO(), name, cluster)
+	now := metav1.Now()
+	cluster.DeletionTimestamp = &now
+	removeFinalizer(cluster, teardownFinalizer)
+	client.Update(context.TODO(), cluster)
+	eventLoop()
+	if err := client.Get(context.TODO(), clusterName, &rbac.ClusterRole{}); !errors.IsNotFound(err) {
+		t.Errorf("expected ClusterRole deleted with its AkkaCluster, but got %v", err)
+	}
+	if err := client.Get(context.TODO(), clusterName, &rbac.ClusterRoleBinding{}); !errors.IsNotFound(err) {
+		t.Errorf("expected ClusterRoleBinding deleted with its AkkaCluster, but got %v", err)
+	}
+	cluster = &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if hasFinalizer(cluster, clusterRBACFinalizer) {
+		t.Errorf("expected cluster RBAC finalizer to be released, but got %v", cluster.Finalizers)
+	}
+}
+
+func TestClusterRBACRemoved(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	akkaCluster.Spec.RBAC = &appv1alpha1.AkkaClusterRBACSpec{
+		ClusterRules: []rbac.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}}},
+	}
+	options := DefaultOptions()
+	options.ClusterRBAC = true
+	options.GrantableRules = akkaCluster.Spec.RBAC.ClusterRules
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileAkkaCluster{options: options, client: client, scheme: scheme, recorder: recorder}
+	req := reconcile.Request{NamespacedName: name}
+	clusterName := types.NamespacedName{Name: name.Namespace + "-" + name.Name}
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(req); err != nil {
+			t.Fatalf("reconcile error: %v", err)
+		}
+	}
+	if err := client.Get(context.TODO(), clusterName, &rbac.ClusterRole{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// dropping the cluster rules deletes what they generated
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	cluster.Spec.RBAC = nil
+	client.Update(context.TODO(), cluster)
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(req); err != nil {
+			t.Fatalf("reconcile error: %v", err)
+		}
+	}
+	if err := client.Get(context.TODO(), clusterName, &rbac.ClusterRole{}); !errors.IsNotFound(err) {
+		t.Errorf("expected unwanted ClusterRole deleted, but got %v", err)
+	}
+	if err := client.Get(context.TODO(), clusterName, &rbac.ClusterRoleBinding{}); !errors.IsNotFound(err) {
+		t.Errorf("expected unwanted ClusterRoleBinding deleted, but got %v", err)
+	}
+
+	// without --cluster-rbac, cluster rules are refused, with a Warning only once
+	r.options.ClusterRBAC = false
+	client.Get(context.TODO(), name, cluster)
+	cluster.Spec.RBAC = akkaCluster.Spec.RBAC
+	client.Update(context.TODO(), cluster)
+	for len(recorder.Events) > 0 {
+		<-recorder.Events
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(req); err != nil {
+			t.Fatalf("reconcile error: %v", err)
+		}
+	}
+	if err := client.Get(context.TODO(), clusterName, &rbac.ClusterRole{}); !errors.IsNotFound(err) {
+		t.Errorf("expected no ClusterRole without --cluster-rbac, but got %v", err)
+	}
+	client.Get(context.TODO(), name, cluster)
+	condition := findCondition(cluster.Status, appv1alpha1.AkkaClusterRulesRefused)
+	if condition == nil || condition.Status != corev1.ConditionTrue || !strings.Contains(condition.Message, "--cluster-rbac") {
+		t.Errorf("expected RulesRefused condition naming --cluster-rbac, but got %+v", condition)
+	}
+	warnings := 0
+	for len(recorder.Events) > 0 {
+		if strings.Contains(<-recorder.Events, "NotGrantable") {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("expected one NotGrantable Event, but got %d", warnings)
+	}
+}
+
+func TestOwnerLabelValue(t *testing.T) {
+	if value := ownerLabelValue("akka-cluster-test"); value != "akka-cluster-test" {
+		t.Errorf("expected short name kept, but got %q", value)
+	}
+	long := strings.Repeat("a", 100)
+	value := ownerLabelValue(long)
+	if len(value) != validation.LabelValueMaxLength || len(validation.IsValidLabelValue(value)) > 0 {
+		t.Errorf("expected a valid label value of %d characters, but got %q", validation.LabelValueMaxLength, value)
+	}
+	if value == ownerLabelValue(long+"b") {
+		t.Errorf("expected long names with a common prefix to get distinct label values")
+	}
+
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = long
+	akkaCluster.Namespace = "akka-cluster-namespace"
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	akkaCluster.Spec.RBAC = &appv1alpha1.AkkaClusterRBACSpec{
+		ClusterRules: []rbac.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"list"}}},
+	}
+	for _, resource := range generateResources(akkaCluster, DefaultOptions().Deployment) {
+		if !isClusterScoped(resource) {
+			continue
+		}
+		requests := ownerOfClusterScoped(handler.MapObject{Meta: resource, Object: resource})
+		if len(requests) != 1 || requests[0].Name != long {
+			t.Errorf("expected %T to map to the full AkkaCluster name, but got %v", resource, requests)
+		}
+		r := &ReconcileAkkaCluster{}
+		if err := r.checkClusterScopedOwnership(akkaCluster, "ClusterRole", resource); err != nil {
+			t.Errorf("expected %T owned by its AkkaCluster, but got %v", resource, err)
+		}
+	}
+}
+
+func TestRulesRefused(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	grantable := rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}
+	wildcard := rbac.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	akkaCluster.Spec.RBAC = &appv1alpha1.AkkaClusterRBACSpec{ExtraRules: []rbac.PolicyRule{grantable, wildcard}}
+	options := DefaultOptions()
+	options.GrantableRules = []rbac.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps", "services"}, Verbs: []string{"get", "list", "watch"}},
+	}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	recorder := record.NewFakeRecorder(10)
+	r := &ReconcileAkkaCluster{options: options, client: client, scheme: scheme, recorder: recorder}
+	req := reconcile.Request{NamespacedName: name}
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(req); err != nil {
+			t.Fatalf("reconcile error: %v", err)
+		}
+	}
+
+	role := &rbac.Role{}
+	if err := client.Get(context.TODO(), name, role); err != nil {
+		t.Fatal(err)
+	}
+	if len(role.Rules) != 2 || !apiequality.Semantic.DeepEqual(role.Rules[1], grantable) {
+		t.Errorf("expected only the grantable extra rule in the Role, but got %v", role.Rules)
+	}
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	condition := findCondition(cluster.Status, appv1alpha1.AkkaClusterRulesRefused)
+	if condition == nil || condition.Status != corev1.ConditionTrue || !strings.Contains(condition.Message, "spec.rbac.extraRules[1]") {
+		t.Errorf("expected RulesRefused condition naming the wildcard rule, but got %+v", condition)
+	}
+	if len(cluster.Spec.RBAC.ExtraRules) != 2 {
+		t.Errorf("expected refused rules to stay in the stored spec, but got %v", cluster.Spec.RBAC.ExtraRules)
+	}
+	found := false
+	for len(recorder.Events) > 0 {
+		if strings.Contains(<-recorder.Events, "NotGrantable") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a RulesRefused Event")
+	}
+}
+
+func TestCoversRule(t *testing.T) {
+	allowed := []rbac.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps", "services"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"app-secret"}, Verbs: []string{"get"}},
+		{APIGroups: []string{"apps"}, Resources: []string{"*"}, Verbs: []string{"get"}},
+		{NonResourceURLs: []string{"/metrics*"}, Verbs: []string{"get"}},
+	}
+	tests := []struct {
+		name    string
+		rule    rbac.PolicyRule
+		covered bool
+	}{
+		{"subset", rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}, true},
+		{"spread over rules", rbac.PolicyRule{APIGroups: []string{"", "apps"}, Resources: []string{"services"}, Verbs: []string{"get"}}, true},
+		{"extra verb", rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "delete"}}, false},
+		{"wildcard verb", rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"*"}}, false},
+		{"wildcard resource", rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"*"}, Verbs: []string{"get"}}, false},
+		{"allowed wildcard", rbac.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"*"}, Verbs: []string{"get"}}, true},
+		{"named resource", rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"app-secret"}, Verbs: []string{"get"}}, true},
+		{"all of named resource", rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}, false},
+		{"url prefix", rbac.PolicyRule{NonResourceURLs: []string{"/metrics/jvm"}, Verbs: []string{"get"}}, true},
+		{"other url", rbac.PolicyRule{NonResourceURLs: []string{"/healthz"}, Verbs: []string{"get"}}, false},
+	}
+	for _, test := range tests {
+		if covered := coversRule(allowed, test.rule); covered != test.covered {
+			t.Errorf("%s: expected covered %v, but got %v", test.name, test.covered, covered)
+		}
+	}
+}
+
+func TestAutoscaler(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	replicas, noWindow := int32(3), int32(0)
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Replicas = &replicas
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	akkaCluster.Spec.Autoscaling = &appv1alpha1.AkkaClusterAutoscalingSpec{
+		MinReplicas:                   1,
+		MaxReplicas:                   5,
+		Shards:                        &appv1alpha1.AkkaClusterShardsLoadSpec{EntityTypes: []string{"cart"}, TargetPerMember: 10},
+		ScaleDownStabilizationSeconds: &noWindow,
+	}
+	akkaCluster.Status = &appv1alpha1.AkkaClusterStatus{ManagementPort: 8558}
+	akkaCluster.Status.Cluster.Oldest = generateNodeAddress("10.0.0.1")
+
+	// pods started in order .1, .2, .3, so .3 is the youngest
+	objs := []runtime.Object{akkaCluster}
+	for n, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		pod := generatePod(ip)
+		pod.Name = fmt.Sprintf("pod-%d", n+1)
+		pod.Namespace = name.Namespace
+		pod.Labels = map[string]string{"app": name.Name}
+		pod.CreationTimestamp = metav1.NewTime(time.Unix(int64(1000*(n+1)), 0))
+		objs = append(objs, pod)
+		akkaCluster.Status.Cluster.Members = append(akkaCluster.Status.Cluster.Members,
+			appv1alpha1.AkkaClusterMemberStatus{Node: generateNodeAddress(ip), Status: "Up"})
+	}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, objs...)
+	writer := &testWriter{}
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, discovery: testVersion("v1.22.3-gke.1500"),
+		recorder: record.NewFakeRecorder(100), writer: writer}
+	req := reconcile.Request{NamespacedName: name}
+	// setLoad stands in for the StatusActor, and reconciles once with the load.
+	setLoad := func(shards int32, change func(*appv1alpha1.AkkaClusterStatus)) *appv1alpha1.AkkaCluster {
+		cluster := &appv1alpha1.AkkaCluster{}
+		client.Get(context.TODO(), name, cluster)
+		cluster.Status.Load = &appv1alpha1.AkkaClusterLoadStatus{Members: 3, Shards: &shards}
+		if change != nil {
+			change(cluster.Status)
+		}
+		client.Status().Update(context.TODO(), cluster)
+		if _, err := r.Reconcile(req); err != nil {
+			t.Fatalf("reconcile error: %v", err)
+		}
+		cluster = &appv1alpha1.AkkaCluster{}
+		client.Get(context.TODO(), name, cluster)
+		return cluster
+	}
+
+	// scaling up takes one step at a time
+	cluster := setLoad(48, nil)
+	if *cluster.Spec.Replicas != 4 || cluster.Status.Autoscaling == nil || cluster.Status.Autoscaling.DesiredReplicas != 5 {
+		t.Fatalf("expected scale up by one towards 5, but got %d %#v", *cluster.Spec.Replicas, cluster.Status.Autoscaling)
+	}
+	if decisions := cluster.Status.Autoscaling.Decisions; len(decisions) != 1 || decisions[0].From != 3 || decisions[0].To != 4 ||
+		decisions[0].Reason != "48 shards at 10 per member" {
+		t.Errorf("expected scale up decision in status, but got %#v", decisions)
+	}
+
+	// scaling down asks the youngest member to leave before lowering replicas
+	cluster = setLoad(5, nil)
+	scaleDown := cluster.Status.Autoscaling.ScaleDown
+	if *cluster.Spec.Replicas != 4 || scaleDown == nil || scaleDown.Replicas != 3 ||
+		len(scaleDown.Members) != 1 || scaleDown.Members[0] != generateNodeAddress("10.0.0.3") {
+		t.Fatalf("expected youngest member to leave first, but got %d %#v", *cluster.Spec.Replicas, scaleDown)
+	}
+	if len(writer.left) != 1 || writer.left[0] != "10.0.0.3" {
+		t.Errorf("expected 10.0.0.3 to be asked to leave, but got %v", writer.left)
+	}
+	pod := &corev1.Pod{}
+	client.Get(context.TODO(), types.NamespacedName{Namespace: name.Namespace, Name: "pod-3"}, pod)
+	if pod.Annotations[podDeletionCostAnnotation] == "" {
+		t.Errorf("expected leaving pod to be deleted first, but got %v", pod.Annotations)
+	}
+
+	// while the member is up, replicas stay
+	cluster = setLoad(5, nil)
+	if *cluster.Spec.Replicas != 4 || len(writer.left) != 1 {
+		t.Errorf("expected to wait for the member to leave, but got %d %v", *cluster.Spec.Replicas, writer.left)
+	}
+
+	// once it leaves, replicas go down
+	cluster = setLoad(5, func(status *appv1alpha1.AkkaClusterStatus) {
+		status.Cluster.Members[2].Status = "Exiting"
+	})
+	if *cluster.Spec.Replicas != 3 || cluster.Status.Autoscaling.ScaleDown != nil {
+		t.Errorf("expected scale down once the member left, but got %d %#v", *cluster.Spec.Replicas, cluster.Status.Autoscaling)
+	}
+	if decisions := cluster.Status.Autoscaling.Decisions; len(decisions) != 2 || decisions[1].From != 4 || decisions[1].To != 3 {
+		t.Errorf("expected scale down decision in status, but got %#v", decisions)
+	}
+
+	// without autoscaling, its status goes away and replicas stay as they are
+	cluster.Spec.Autoscaling = nil
+	client.Update(context.TODO(), cluster)
+	cluster = setLoad(5, nil)
+	if *cluster.Spec.Replicas != 3 || cluster.Status.Autoscaling != nil {
+		t.Errorf("expected autoscaler to let go, but got %d %#v", *cluster.Spec.Replicas, cluster.Status.Autoscaling)
+	}
+}
+
+// testVersion is a discovery.ServerVersionInterface for an API server of the given version.
+type testVersion string
+
+func (v testVersion) ServerVersion() (*version.Info, error) {
+	return &version.Info{GitVersion: string(v)}, nil
+}
+
+func TestAutoscalingUnsupported(t *testing.T) {
+	name := types.NamespacedName{
+		Name:      "akka-cluster-test",
+		Namespace: "akka-cluster-namespace",
+	}
+	replicas := int32(3)
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = name.Name
+	akkaCluster.Namespace = name.Namespace
+	akkaCluster.Spec.Replicas = &replicas
+	akkaCluster.Spec.Template.Spec.Containers = []corev1.Container{{Name: "main", Image: "akka-cluster:1.0.0"}}
+	akkaCluster.Spec.Autoscaling = &appv1alpha1.AkkaClusterAutoscalingSpec{
+		MinReplicas: 1,
+		MaxReplicas: 5,
+		Shards:      &appv1alpha1.AkkaClusterShardsLoadSpec{EntityTypes: []string{"cart"}, TargetPerMember: 10},
+	}
+	shards := int32(0)
+	akkaCluster.Status = &appv1alpha1.AkkaClusterStatus{Load: &appv1alpha1.AkkaClusterLoadStatus{Members: 3, Shards: &shards}}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster)
+	client := newApplyClient(scheme, akkaCluster)
+	writer := &testWriter{}
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme, discovery: testVersion("v1.21.14"),
+		recorder: record.NewFakeRecorder(100), writer: writer}
+	req := reconcile.Request{NamespacedName: name}
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+
+	// before pod-deletion-cost, the ReplicaSet might remove members that never left
+	cluster := &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if *cluster.Spec.Replicas != 3 || len(writer.left) != 0 || cluster.Status.Autoscaling != nil {
+		t.Errorf("expected no autoscaling before Kubernetes 1.22, but got %d %v %#v", *cluster.Spec.Replicas, writer.left, cluster.Status.Autoscaling)
+	}
+	condition := findCondition(cluster.Status, appv1alpha1.AkkaClusterAutoscalingUnsupported)
+	if condition == nil || condition.Status != corev1.ConditionTrue || !strings.Contains(condition.Message, "v1.21.14") {
+		t.Errorf("expected AutoscalingUnsupported condition naming the version, but got %+v", condition)
+	}
+
+	// once the API server is upgraded, the autoscaler runs
+	r.discovery = testVersion("v1.22.0")
+	if _, err := r.Reconcile(req); err != nil {
+		t.Fatalf("reconcile error: %v", err)
+	}
+	cluster = &appv1alpha1.AkkaCluster{}
+	client.Get(context.TODO(), name, cluster)
+	if cluster.Status.Autoscaling == nil || cluster.Status.Autoscaling.DesiredReplicas != 1 {
+		t.Errorf("expected autoscaling after the upgrade, but got %#v", cluster.Status.Autoscaling)
+	}
+	if isConditionTrue(cluster.Status, appv1alpha1.AkkaClusterAutoscalingUnsupported) {
+		t.Errorf("expected AutoscalingUnsupported condition to be false, but got %+v", cluster.Status.Conditions)
+	}
+}
+
+func TestFinishScaleDown(t *testing.T) {
+	a, b, c := generateNodeAddress("10.0.0.1"), generateNodeAddress("10.0.0.2"), generateNodeAddress("10.0.0.3")
+	started := time.Unix(10000, 0)
+	status := &appv1alpha1.AkkaClusterStatus{Autoscaling: &appv1alpha1.AkkaClusterAutoscalingStatus{
+		ScaleDown: &appv1alpha1.AkkaClusterScaleDownStatus{Replicas: 3, Members: []string{b, c}, Started: metav1.NewTime(started), Reason: "idle"},
+	}}
+	status.Cluster.Members = []appv1alpha1.AkkaClusterMemberStatus{{Node: a, Status: "Up"}, {Node: b, Status: "Leaving"}, {Node: c, Status: "Exiting"}}
+	r := &ReconcileAkkaCluster{recorder: record.NewFakeRecorder(10)}
+	cluster := &appv1alpha1.AkkaCluster{}
+
+	// replicas go down by the member that is exiting, before its container can restart
+	replicas, wait := r.finishScaleDown(cluster, status, 5, started.Add(time.Second))
+	if replicas == nil || *replicas != 4 || wait == 0 {
+		t.Fatalf("expected to scale down by the exiting member and wait for the other, but got %v %v", replicas, wait)
+	}
+	if scaleDown := status.Autoscaling.ScaleDown; scaleDown == nil || len(scaleDown.Members) != 1 || scaleDown.Members[0] != b {
+		t.Errorf("expected to keep waiting for %s only, but got %#v", b, scaleDown)
+	}
+
+	// the exited member restarting and rejoining doesn't hold up the rest
+	status.Cluster.Members = []appv1alpha1.AkkaClusterMemberStatus{{Node: a, Status: "Up"}, {Node: c, Status: "Joining"}}
+	replicas, wait = r.finishScaleDown(cluster, status, 4, started.Add(2*time.Second))
+	if replicas == nil || *replicas != 3 || wait != 0 || status.Autoscaling.ScaleDown != nil {
+		t.Errorf("expected scale down to finish once the other member was removed, but got %v %v %#v", replicas, wait, status.Autoscaling.ScaleDown)
+	}
+	if decisions := status.Autoscaling.Decisions; len(decisions) != 2 || decisions[0].To != 4 || decisions[1].To != 3 {
+		t.Errorf("expected a decision for each step, but got %#v", decisions)
+	}
+}
+
+func TestMarkForDeletion(t *testing.T) {
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Namespace = "akka-cluster-namespace"
+	akkaCluster.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"app": "shop"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"backend"}},
+		},
+	}
+	objs := []runtime.Object{}
+	for n, tier := range []string{"backend", "frontend"} {
+		pod := generatePod("10.0.0.1")
+		pod.Name = fmt.Sprintf("pod-%d", n+1)
+		pod.Namespace = akkaCluster.Namespace
+		pod.Labels = map[string]string{"app": "shop", "tier": tier}
+		objs = append(objs, pod)
+	}
+	client := newApplyClient(scheme.Scheme, objs...)
+	r := &ReconcileAkkaCluster{options: DefaultOptions(), client: client, scheme: scheme.Scheme}
+	if err := r.markForDeletion(akkaCluster, func(string) bool { return true }); err != nil {
+		t.Fatal(err)
+	}
+	for n, want := range []bool{true, false} {
+		pod := &corev1.Pod{}
+		client.Get(context.TODO(), types.NamespacedName{Namespace: akkaCluster.Namespace, Name: fmt.Sprintf("pod-%d", n+1)}, pod)
+		if marked := pod.Annotations[podDeletionCostAnnotation] != ""; marked != want {
+			t.Errorf("expected pod-%d marked %v by the full selector, but got %v", n+1, want, marked)
+		}
+	}
+
+	akkaCluster.Spec.Selector.MatchExpressions[0].Operator = "Near"
+	if err := r.markForDeletion(akkaCluster, func(string) bool { return true }); err == nil {
+		t.Errorf("expected an invalid selector to be an error")
+	}
+}
+
+func TestStabilize(t *testing.T) {
+	window := int32(300)
+	spec := &appv1alpha1.AkkaClusterAutoscalingSpec{
+		MinReplicas:                   2,
+		MaxReplicas:                   10,
+		ScaleUpStabilizationSeconds:   &window,
+		ScaleDownStabilizationSeconds: &window,
+	}
+	now := time.Unix(10000, 0)
+	ago := func(seconds int) metav1.Time { return metav1.NewTime(now.Add(-time.Duration(seconds) * time.Second)) }
+	tests := []struct {
+		name            string
+		current         int32
+		recommendations []appv1alpha1.AkkaClusterScaleRecommendation
+		want            int32
+	}{
+		{"no recommendations", 4, nil, 4},
+		{"below min", 1, nil, 2},
+		{"above max", 12, nil, 10},
+		{"up held by window", 4, []appv1alpha1.AkkaClusterScaleRecommendation{{Time: ago(100), Replicas: 4}, {Time: ago(10), Replicas: 6}}, 4},
+		{"up after window", 4, []appv1alpha1.AkkaClusterScaleRecommendation{{Time: ago(400), Replicas: 4}, {Time: ago(301), Replicas: 6}}, 5},
+		{"up to lowest in window", 4, []appv1alpha1.AkkaClusterScaleRecommendation{{Time: ago(400), Replicas: 8}, {Time: ago(10), Replicas: 6}}, 5},
+		{"down held by window", 6, []appv1alpha1.AkkaClusterScaleRecommendation{{Time: ago(400), Replicas: 6}, {Time: ago(10), Replicas: 3}}, 6},
+		{"down to highest in window", 6, []appv1alpha1.AkkaClusterScaleRecommendation{{Time: ago(400), Replicas: 5}, {Time: ago(10), Replicas: 3}}, 5},
+	}
+	for _, test := range tests {
+		status := &appv1alpha1.AkkaClusterAutoscalingStatus{Recommendations: test.recommendations}
+		if got := stabilize(spec, status, test.current, now); got != test.want {
+			t.Errorf("%s: expected %d replicas, but got %d", test.name, test.want, got)
+		}
+	}
+
+	// recommendations no window reaches are dropped, except the one in effect at its start
+	status := &appv1alpha1.AkkaClusterAutoscalingStatus{Recommendations: []appv1alpha1.AkkaClusterScaleRecommendation{
+		{Time: ago(900), Replicas: 3}, {Time: ago(600), Replicas: 4}, {Time: ago(100), Replicas: 5},
+	}}
+	recordRecommendation(spec, status, 5, now)
+	if len(status.Recommendations) != 2 || status.Recommendations[0].Replicas != 4 {
+		t.Errorf("expected old recommendations pruned and no repeat, but got %v", status.Recommendations)
+	}
+}
+
+func TestParseMetric(t *testing.T) {
+	body := []byte(`# HELP queue_depth Messages waiting.
+# TYPE queue_depth gauge
+queue_depth{shard="1",region="a b"} 12
+queue_depth{shard="2"} 3.5 1600000000000
+queue_depth_max 99
+`)
+	if sum, err := parseMetric(body, "queue_depth"); err != nil || sum != 15.5 {
+		t.Errorf("expected series summed, but got %v %v", sum, err)
+	}
+	if _, err := parseMetric(body, "queue"); err == nil {
+		t.Errorf("expected a metric that is only a prefix not to be found")
+	}
+	if _, err := parseMetric([]byte("queue_depth oops\n"), "queue_depth"); err == nil {
+		t.Errorf("expected an error for a bad value")
+	}
+}
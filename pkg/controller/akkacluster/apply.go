@@ -0,0 +1,166 @@
+package akkacluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// On server-side apply:
+//
+// Generated resources are written with server-side apply under the fieldManager below,
+// rather than as a merge patch of the whole wanted object. The API server then tracks
+// which fields the operator owns, so list entries that other controllers add, like
+// injected sidecar containers or env, are left alone, and fields the operator stops
+// setting are removed from the live resource on the next apply.
+//
+// Ignored fields are left out of the apply, so another controller can own them without
+// the operator writing back a value from its cache over theirs. But leaving out a field
+// the operator owns from an earlier apply, like one set on create, would have the API
+// server remove it. So such fields are first handed off: their live values are applied
+// once under the ignoredFieldManager, without forcing, which shares their ownership. That
+// apply conflicts rather than write a stale value over one changed since it was read.
+//
+// Resources created or patched before server-side apply have their fields owned by an
+// update of the same field manager, not by its applies, so an apply would never remove
+// them. Such resources are migrated once, before their first apply, by turning those
+// updates into the apply of fieldManager.
+//
+// SubsetEqual can't see a field that is no longer wanted, so each wanted resource also
+// carries a hash of itself in the appliedHashAnnotation. Any change to the wanted object,
+// including a removal, changes the hash and so triggers a new apply.
+
+const (
+	// fieldManager owns the fields of generated resources set by the operator.
+	fieldManager = "akka-cluster-operator"
+	// ignoredFieldManager keeps ignored fields that fieldManager let go of.
+	ignoredFieldManager = "akka-cluster-operator-ignored"
+	// appliedHashAnnotation holds a hash of the last applied configuration.
+	appliedHashAnnotation = "app.lightbend.com/applied-hash"
+)
+
+// stampAppliedHash sets the appliedHashAnnotation on a wanted resource to a hash of the
+// resource as applied, without that annotation.
+func stampAppliedHash(resource GenericResource, ignored []FieldPath) error {
+	annotations := resource.GetAnnotations()
+	delete(annotations, appliedHashAnnotation)
+	pruned, err := pruneFields(resource, ignored)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(pruned)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[appliedHashAnnotation] = hex.EncodeToString(sum[:])[:16]
+	resource.SetAnnotations(annotations)
+	return nil
+}
+
+// apply creates or updates a wanted resource with server-side apply, forcing ownership
+// of the fields it sets. Ignored fields are left out, after handing off those it owns in
+// the live resource, if any, so the apply neither reverts nor drops what others set there.
+func (r *ReconcileAkkaCluster) apply(resource GenericResource, live runtime.Object, ignored []FieldPath) error {
+	// apply patches are sent as is, so they must name their own kind
+	gvk, err := apiutil.GVKForObject(resource, r.scheme)
+	if err != nil {
+		return err
+	}
+	resource.GetObjectKind().SetGroupVersionKind(gvk)
+	if err := r.migrateOwnership(live); err != nil {
+		return err
+	}
+	if err := r.handOff(resource, live, ignored); err != nil {
+		return err
+	}
+	pruned, err := pruneFields(resource, ignored)
+	if err != nil {
+		return err
+	}
+	return r.client.Patch(context.TODO(), pruned, client.Apply, client.ForceOwnership, client.FieldOwner(fieldManager))
+}
+
+// migrateOwnership moves the fields of live owned by updates of the fieldManager to its
+// apply, if it hasn't applied live yet. It patches with the resourceVersion read, so it
+// conflicts rather than take fields another update set since.
+func (r *ReconcileAkkaCluster) migrateOwnership(live runtime.Object) error {
+	liveMeta, ok := live.(metav1.Object)
+	if !ok {
+		return nil
+	}
+	managed := liveMeta.GetManagedFields()
+	migrated := false
+	for i, entry := range managed {
+		if entry.Manager != fieldManager {
+			continue
+		}
+		if entry.Operation == metav1.ManagedFieldsOperationApply {
+			return nil
+		}
+		managed[i].Operation = metav1.ManagedFieldsOperationApply
+		migrated = true
+	}
+	if !migrated {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": liveMeta.GetResourceVersion(),
+			"managedFields":   managed,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return r.client.Patch(context.TODO(), live, client.RawPatch(types.MergePatchType, patch))
+}
+
+// handOff applies the live values of ignored fields still owned by the fieldManager under
+// the ignoredFieldManager, so the next apply can leave them out without removing them.
+func (r *ReconcileAkkaCluster) handOff(resource GenericResource, live runtime.Object, ignored []FieldPath) error {
+	liveMeta, ok := live.(metav1.Object)
+	if !ok || len(ignored) == 0 {
+		return nil
+	}
+	var owned map[string]interface{}
+	for _, entry := range liveMeta.GetManagedFields() {
+		if entry.Manager == fieldManager && entry.Operation == metav1.ManagedFieldsOperationApply && entry.FieldsV1 != nil {
+			if err := json.Unmarshal(entry.FieldsV1.Raw, &owned); err != nil {
+				return err
+			}
+		}
+	}
+	if owned == nil {
+		return nil
+	}
+	liveContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+	if err != nil {
+		return err
+	}
+	content := map[string]interface{}{}
+	for _, path := range ignored {
+		if path.ownedIn(owned, liveContent) {
+			path.extractTo(liveContent, content)
+		}
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	handedOff := &unstructured.Unstructured{Object: content}
+	handedOff.SetGroupVersionKind(resource.GetObjectKind().GroupVersionKind())
+	handedOff.SetName(resource.GetName())
+	handedOff.SetNamespace(resource.GetNamespace())
+	return r.client.Patch(context.TODO(), handedOff, client.Apply, client.FieldOwner(ignoredFieldManager))
+}
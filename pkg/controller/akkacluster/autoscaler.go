@@ -0,0 +1,607 @@
+package akkacluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
+)
+
+// On autoscaling:
+//
+// A HorizontalPodAutoscaler on CPU sees little of what loads an Akka cluster, and removes
+// pods without asking their members to leave first. With spec.autoscaling set, the
+// StatusActor also reads the load on the Up members, as shards hosted or as a metric the
+// application exposes, and keeps polling at a steady interval so the load stays fresh.
+// Reconcile turns the load into a recommended number of replicas, damps it over the
+// stabilization windows like an HPA does, and sets spec.replicas of the AkkaCluster.
+//
+// Scaling up only takes new pods. Scaling down first asks the youngest members to Leave,
+// never the oldest, and marks their pods for the ReplicaSet to delete first. spec.replicas
+// is lowered as each of them reaches Exiting or is removed, or after scaleDownTimeout, so
+// shards are handed over before pods go away, and pods don't restart into the cluster.
+// ReplicaSets only honor the mark from Kubernetes 1.22, and before that would remove any
+// pod, so on older API servers the autoscaler refuses to run and says so in a condition.
+// Recommendations and decisions are recorded in status.autoscaling, which survives a
+// change of operator leader.
+
+const (
+	// autoscalingPollInterval is how often load is read when status polling has no steady
+	// interval of its own.
+	autoscalingPollInterval = 15 * time.Second
+	// autoscaleRetryInterval is how often a held recommendation is looked at again.
+	autoscaleRetryInterval = 15 * time.Second
+	// defaultScaleDownStabilization is the scale down window when none is given.
+	defaultScaleDownStabilization = 300 * time.Second
+	// scaleDownTimeout bounds how long a scale down waits for members to leave.
+	scaleDownTimeout = 2 * time.Minute
+	// maxScaleDecisions is how many recent decisions status keeps.
+	maxScaleDecisions = 10
+	// podDeletionCostAnnotation ranks pods for deletion when a ReplicaSet scales down, on
+	// Kubernetes 1.22 and later.
+	podDeletionCostAnnotation = "controller.kubernetes.io/pod-deletion-cost"
+)
+
+// deletionCostVersion is the first Kubernetes version whose ReplicaSets honor
+// podDeletionCostAnnotation.
+var deletionCostVersion = version.MustParseGeneric("1.22")
+
+// readLoad sets the load on the Up members of a cluster on status, reading the members in
+// parallel, each read bounded by timeout. Load is left unknown if any member can't be
+// read, so a member that is slow to answer doesn't look like spare capacity.
+func (a *StatusActor) readLoad(cluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus, timeout time.Duration) {
+	spec := cluster.Spec.Autoscaling
+	status.Load = nil
+	if spec == nil {
+		return
+	}
+	hosts := []string{}
+	for _, member := range status.Cluster.Members {
+		if member.Status == "Up" {
+			hosts = append(hosts, management.NodeHost(member.Node))
+		}
+	}
+	if len(hosts) == 0 {
+		return
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var shards int32
+	metric, failed := 0.0, false
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			memberShards, memberMetric, err := a.readMemberLoad(spec, host, status.ManagementPort, timeout)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Info("StatusActor could not read load", "host", host, "err", err)
+				failed = true
+				return
+			}
+			shards += memberShards
+			metric += memberMetric
+		}(host)
+	}
+	wg.Wait()
+	if failed {
+		return
+	}
+	load := &appv1alpha1.AkkaClusterLoadStatus{Members: int32(len(hosts))}
+	if spec.Shards != nil {
+		load.Shards = &shards
+	}
+	if spec.Metric != nil {
+		load.Metric = resource.NewMilliQuantity(int64(math.Round(metric*1000)), resource.DecimalSI)
+	}
+	status.Load = load
+}
+
+// readMemberLoad reads the shards and metric of the member at host.
+func (a *StatusActor) readMemberLoad(spec *appv1alpha1.AkkaClusterAutoscalingSpec, host string, port int32,
+	timeout time.Duration) (int32, float64, error) {
+
+	var shards int32
+	if spec.Shards != nil {
+		for _, entityType := range spec.Shards.EntityTypes {
+			link := management.ShardsURL(management.Endpoint(host, port), entityType)
+			body, err := management.ReadWithTimeout(a.reader, link, timeout)
+			if err != nil {
+				return 0, 0, err
+			}
+			details := &management.ShardDetails{}
+			if err := json.Unmarshal(body, details); err != nil {
+				return 0, 0, err
+			}
+			shards += int32(len(details.Regions))
+		}
+	}
+	metric := 0.0
+	if spec.Metric != nil {
+		path := spec.Metric.Path
+		if path == "" {
+			path = "/metrics"
+		}
+		body, err := management.ReadWithTimeout(a.reader, management.Endpoint(host, spec.Metric.Port)+path, timeout)
+		if err != nil {
+			return 0, 0, err
+		}
+		if metric, err = parseMetric(body, spec.Metric.Name); err != nil {
+			return 0, 0, err
+		}
+	}
+	return shards, metric, nil
+}
+
+// parseMetric sums the series of the named metric in the Prometheus text format.
+func parseMetric(body []byte, name string) (float64, error) {
+	sum, found := 0.0, false
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		text := strings.TrimSpace(string(line))
+		if text == "" || strings.HasPrefix(text, "#") || !strings.HasPrefix(text, name) {
+			continue
+		}
+		rest := text[len(name):]
+		if strings.HasPrefix(rest, "{") {
+			end := strings.LastIndex(rest, "}")
+			if end < 0 {
+				return 0, fmt.Errorf("metric %s: unterminated labels in %q", name, text)
+			}
+			rest = rest[end+1:]
+		} else if !strings.HasPrefix(rest, " ") && !strings.HasPrefix(rest, "\t") {
+			continue // another metric with name as a prefix
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return 0, fmt.Errorf("metric %s: no value in %q", name, text)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("metric %s: %v", name, err)
+		}
+		sum += value
+		found = true
+	}
+	if !found {
+		return 0, fmt.Errorf("metric %s not found", name)
+	}
+	return sum, nil
+}
+
+// recommend returns the replicas the load calls for, within min and max, and why. It
+// returns false if the load is unknown.
+func recommend(spec *appv1alpha1.AkkaClusterAutoscalingSpec, load *appv1alpha1.AkkaClusterLoadStatus) (int32, string, bool) {
+	if load == nil {
+		return 0, "", false
+	}
+	replicas, reasons := int32(0), []string{}
+	if spec.Shards != nil && spec.Shards.TargetPerMember > 0 && load.Shards != nil {
+		n := ceilDiv(int64(*load.Shards), int64(spec.Shards.TargetPerMember))
+		reasons = append(reasons, fmt.Sprintf("%d shards at %d per member", *load.Shards, spec.Shards.TargetPerMember))
+		replicas = maxInt32(replicas, n)
+	}
+	if spec.Metric != nil && spec.Metric.TargetPerMember.Sign() > 0 && load.Metric != nil {
+		n := ceilDiv(load.Metric.MilliValue(), spec.Metric.TargetPerMember.MilliValue())
+		reasons = append(reasons, fmt.Sprintf("%s %s at %s per member",
+			spec.Metric.Name, load.Metric.String(), spec.Metric.TargetPerMember.String()))
+		replicas = maxInt32(replicas, n)
+	}
+	if len(reasons) == 0 {
+		return 0, "", false
+	}
+	return clamp(replicas, spec.MinReplicas, spec.MaxReplicas), strings.Join(reasons, ", "), true
+}
+
+// ceilDiv divides rounding up, as a replica count.
+func ceilDiv(n, d int64) int32 {
+	if d <= 0 {
+		return 0
+	}
+	q := (n + d - 1) / d
+	if q > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(q)
+}
+
+func maxInt32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// clamp keeps replicas within min and max, where max wins if they cross.
+func clamp(replicas, min, max int32) int32 {
+	return minInt32(maxInt32(replicas, min), max)
+}
+
+// stabilizationWindows resolves the scale up and scale down windows of spec.
+func stabilizationWindows(spec *appv1alpha1.AkkaClusterAutoscalingSpec) (time.Duration, time.Duration) {
+	up, down := time.Duration(0), defaultScaleDownStabilization
+	if spec.ScaleUpStabilizationSeconds != nil {
+		up = time.Duration(*spec.ScaleUpStabilizationSeconds) * time.Second
+	}
+	if spec.ScaleDownStabilizationSeconds != nil {
+		down = time.Duration(*spec.ScaleDownStabilizationSeconds) * time.Second
+	}
+	return up, down
+}
+
+// maxStep is the most members one decision of spec adds or removes.
+func maxStep(spec *appv1alpha1.AkkaClusterAutoscalingSpec) int32 {
+	if spec.MaxStep != nil && *spec.MaxStep > 0 {
+		return *spec.MaxStep
+	}
+	return 1
+}
+
+// recordRecommendation adds a recommendation to status, if it differs from the last, and
+// drops those that no stabilization window reaches any more. The last recommendation from
+// before a window is kept, as it was still in effect when the window started.
+func recordRecommendation(spec *appv1alpha1.AkkaClusterAutoscalingSpec, status *appv1alpha1.AkkaClusterAutoscalingStatus,
+	replicas int32, now time.Time) {
+
+	recommendations := status.Recommendations
+	if n := len(recommendations); n == 0 || recommendations[n-1].Replicas != replicas {
+		recommendations = append(recommendations, appv1alpha1.AkkaClusterScaleRecommendation{
+			Time: metav1.NewTime(now), Replicas: replicas,
+		})
+	}
+	up, down := stabilizationWindows(spec)
+	windowStart := now.Add(-up)
+	if down > up {
+		windowStart = now.Add(-down)
+	}
+	status.Recommendations = inWindow(recommendations, windowStart)
+	status.DesiredReplicas = replicas
+}
+
+// stabilize returns the replicas to scale to from current, given the recommendations in
+// status. Scaling up goes to the lowest recommendation within the scale up window, and
+// scaling down to the highest within the scale down window, by at most MaxStep. Replicas
+// outside min and max are brought within at once.
+func stabilize(spec *appv1alpha1.AkkaClusterAutoscalingSpec, status *appv1alpha1.AkkaClusterAutoscalingStatus,
+	current int32, now time.Time) int32 {
+
+	if bounded := clamp(current, spec.MinReplicas, spec.MaxReplicas); bounded != current {
+		return bounded
+	}
+	if len(status.Recommendations) == 0 {
+		return current
+	}
+	up, down := stabilizationWindows(spec)
+	step := maxStep(spec)
+	latest := status.Recommendations[len(status.Recommendations)-1].Replicas
+	switch {
+	case latest > current:
+		target := latest
+		for _, r := range inWindow(status.Recommendations, now.Add(-up)) {
+			target = minInt32(target, r.Replicas)
+		}
+		if target > current {
+			return minInt32(target, current+step)
+		}
+	case latest < current:
+		target := latest
+		for _, r := range inWindow(status.Recommendations, now.Add(-down)) {
+			target = maxInt32(target, r.Replicas)
+		}
+		if target < current {
+			return maxInt32(target, current-step)
+		}
+	}
+	return current
+}
+
+// inWindow returns the recommendations in effect since start: those made after it, and
+// the last one made before it.
+func inWindow(recommendations []appv1alpha1.AkkaClusterScaleRecommendation, start time.Time) []appv1alpha1.AkkaClusterScaleRecommendation {
+	first := 0
+	for i := range recommendations {
+		if !recommendations[i].Time.Time.After(start) {
+			first = i
+		}
+	}
+	return recommendations[first:]
+}
+
+// replicasOf is the number of replicas an AkkaCluster asks for.
+func replicasOf(akkaCluster *appv1alpha1.AkkaCluster) int32 {
+	if akkaCluster.Spec.Replicas == nil {
+		return 1
+	}
+	return *akkaCluster.Spec.Replicas
+}
+
+// autoscale runs the autoscaler of an AkkaCluster on its status. It returns the status with
+// the autoscaler's records, the replicas to scale to if they change now, and when to look
+// again if waiting on something.
+func (r *ReconcileAkkaCluster) autoscale(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus) (
+	*appv1alpha1.AkkaClusterStatus, *int32, time.Duration) {
+
+	spec := akkaCluster.Spec.Autoscaling
+	if spec == nil {
+		if status != nil {
+			status.Autoscaling = nil
+		}
+		return r.reportAutoscalingUnsupported(akkaCluster, status, ""), nil, 0
+	}
+	unsupported, err := r.deletionCostUnsupported()
+	if err != nil {
+		log.Info("could not read the Kubernetes version for autoscaling", "err", err)
+		return status, nil, autoscaleRetryInterval
+	}
+	status = r.reportAutoscalingUnsupported(akkaCluster, status, unsupported)
+	if unsupported != "" {
+		return status, nil, 0
+	}
+	if status == nil {
+		status = &appv1alpha1.AkkaClusterStatus{}
+	}
+	if status.Autoscaling == nil {
+		status.Autoscaling = &appv1alpha1.AkkaClusterAutoscalingStatus{}
+	}
+	now := time.Now()
+	current := replicasOf(akkaCluster)
+	if status.Autoscaling.ScaleDown != nil {
+		replicas, wait := r.finishScaleDown(akkaCluster, status, current, now)
+		return status, replicas, wait
+	}
+
+	desired, reason, known := recommend(spec, status.Load)
+	if known {
+		recordRecommendation(spec, status.Autoscaling, desired, now)
+	} else {
+		reason = "load unknown"
+	}
+	target := stabilize(spec, status.Autoscaling, current, now)
+	if target > current {
+		r.recordDecision(akkaCluster, status.Autoscaling, current, target, reason, now)
+		return status, &target, 0
+	}
+	if target < current {
+		replicas := r.startScaleDown(akkaCluster, status, current, target, reason, now)
+		return status, replicas, teardownPollInterval
+	}
+	if known && desired != current {
+		// held by a stabilization window, which may pass without the load changing
+		return status, nil, autoscaleRetryInterval
+	}
+	return status, nil, 0
+}
+
+// deletionCostUnsupported returns the version of the API server if its ReplicaSets don't
+// honor podDeletionCostAnnotation, or else an empty string.
+func (r *ReconcileAkkaCluster) deletionCostUnsupported() (string, error) {
+	info, err := r.discovery.ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return "", err
+	}
+	if serverVersion.AtLeast(deletionCostVersion) {
+		return "", nil
+	}
+	return info.GitVersion, nil
+}
+
+// reportAutoscalingUnsupported sets the AutoscalingUnsupported condition on status, naming
+// the version of the API server if it is too old to autoscale on.
+func (r *ReconcileAkkaCluster) reportAutoscalingUnsupported(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus,
+	unsupported string) *appv1alpha1.AkkaClusterStatus {
+
+	if unsupported == "" {
+		return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterAutoscalingUnsupported, true, "Supported",
+			"spec.autoscaling is supported")
+	}
+	return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterAutoscalingUnsupported, false, "KubernetesTooOld",
+		fmt.Sprintf("spec.autoscaling is left alone, as Kubernetes %s may scale down members that never left the Akka cluster; it needs %s or later",
+			unsupported, deletionCostVersion))
+}
+
+// startScaleDown asks the youngest Up members to leave, so that spec.replicas can go from
+// current to target once they have. Members already leaving count towards the scale down.
+// If no member needs to leave, it returns target to scale to now.
+func (r *ReconcileAkkaCluster) startScaleDown(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus,
+	current, target int32, reason string, now time.Time) *int32 {
+
+	leaving, asked := []string{}, []string{}
+	for _, member := range r.leaveOrder(akkaCluster, status) {
+		if int32(len(leaving)) == current-target {
+			break
+		}
+		switch member.Status {
+		case "Leaving", "Exiting":
+			leaving = append(leaving, member.Node)
+		case "Up":
+			if member.Node != status.Cluster.Oldest {
+				leaving = append(leaving, member.Node)
+				asked = append(asked, member.Node)
+			}
+		}
+	}
+	if len(leaving) == 0 {
+		r.recordDecision(akkaCluster, status.Autoscaling, current, target, reason, now)
+		return &target
+	}
+
+	hosts := make(map[string]bool)
+	for _, node := range asked {
+		hosts[management.NodeHost(node)] = true
+	}
+	// without the pods marked, the ReplicaSet might remove members that never left
+	if err := r.markForDeletion(akkaCluster, func(podIP string) bool { return hosts[podIP] }); err != nil {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "ScaleDown",
+			"failed to mark pods of members %s for deletion: %v", strings.Join(asked, ", "), err)
+		return nil
+	}
+	port := status.ManagementPort
+	if port == 0 {
+		port = r.options.Management.FallbackPort()
+	}
+	left := []string{}
+	for _, node := range leaving {
+		if contains(asked, node) {
+			if _, err := management.OperateMember(r.writer, management.Endpoint(management.NodeHost(node), port), node, management.Leave); err != nil {
+				r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "ScaleDown",
+					"failed to ask member %s to leave: %v", node, err)
+				continue
+			}
+		}
+		left = append(left, node)
+	}
+	if len(left) == 0 {
+		return nil
+	}
+	status.Autoscaling.ScaleDown = &appv1alpha1.AkkaClusterScaleDownStatus{
+		Replicas: current - int32(len(left)),
+		Members:  left,
+		Started:  metav1.NewTime(now),
+		Reason:   reason,
+	}
+	r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "ScaleDown",
+		"asked members %s to leave, to scale from %d to %d replicas: %s",
+		strings.Join(left, ", "), current, status.Autoscaling.ScaleDown.Replicas, reason)
+	return nil
+}
+
+// finishScaleDown lowers spec.replicas by the members of a scale down as they leave, so
+// that a member whose container restarts after leaving isn't bootstrapped back in. It
+// returns the replicas to scale to, if any, and how long to wait for the others. After
+// scaleDownTimeout, it scales down to the target anyway.
+func (r *ReconcileAkkaCluster) finishScaleDown(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus,
+	current int32, now time.Time) (*int32, time.Duration) {
+
+	scaleDown := status.Autoscaling.ScaleDown
+	statuses := make(map[string]string)
+	for _, member := range status.Cluster.Members {
+		statuses[member.Node] = member.Status
+	}
+	left, remaining := []string{}, []string{}
+	for _, node := range scaleDown.Members {
+		switch statuses[node] {
+		case "Exiting", "Removed", "Down":
+			left = append(left, node)
+		case "":
+			// gone from the cluster, unless membership isn't known
+			if len(status.Cluster.Members) > 0 {
+				left = append(left, node)
+			} else {
+				remaining = append(remaining, node)
+			}
+		default:
+			remaining = append(remaining, node)
+		}
+	}
+
+	replicas := current - int32(len(left))
+	wait := teardownPollInterval
+	if len(remaining) > 0 && now.Sub(scaleDown.Started.Time) >= scaleDownTimeout {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "ScaleDownTimeout",
+			"members %s did not leave within %s, scaling down anyway", strings.Join(remaining, ", "), scaleDownTimeout)
+		replicas, remaining = scaleDown.Replicas, nil
+	}
+	if len(remaining) == 0 {
+		status.Autoscaling.ScaleDown = nil
+		wait = 0
+	} else {
+		scaleDown.Members = remaining
+	}
+	if replicas < scaleDown.Replicas {
+		replicas = scaleDown.Replicas
+	}
+	if replicas >= current {
+		// nobody left yet, or spec.replicas was changed by hand meanwhile
+		return nil, wait
+	}
+	r.recordDecision(akkaCluster, status.Autoscaling, current, replicas, scaleDown.Reason, now)
+	return &replicas, wait
+}
+
+// markForDeletion ranks the pods of akkaCluster whose IPs are marked first for deletion
+// when the ReplicaSet scales down. Failing to mark a pod is only logged, as it leaves the
+// Akka cluster either way, but failing to find the pods is an error.
+func (r *ReconcileAkkaCluster) markForDeletion(akkaCluster *appv1alpha1.AkkaCluster, marked func(podIP string) bool) error {
+	selector, err := metav1.LabelSelectorAsSelector(akkaCluster.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	pods := &corev1.PodList{}
+	if err := r.client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     akkaCluster.Namespace,
+		LabelSelector: selector,
+	}); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !marked(pod.Status.PodIP) {
+			continue
+		}
+		ranked := pod.DeepCopy()
+		if ranked.Annotations == nil {
+			ranked.Annotations = make(map[string]string)
+		}
+		ranked.Annotations[podDeletionCostAnnotation] = strconv.Itoa(math.MinInt32)
+		if err := r.client.Patch(context.TODO(), ranked, client.MergeFrom(pod)); err != nil {
+			log.Info("could not mark pod for deletion", "pod", pod.Name, "err", err)
+		}
+	}
+	return nil
+}
+
+// recordDecision records a change of replicas in status and as an Event.
+func (r *ReconcileAkkaCluster) recordDecision(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterAutoscalingStatus,
+	from, to int32, reason string, now time.Time) {
+
+	decision := appv1alpha1.AkkaClusterScaleDecision{Time: metav1.NewTime(now), From: from, To: to, Reason: reason}
+	status.Decisions = append(status.Decisions, decision)
+	if len(status.Decisions) > maxScaleDecisions {
+		status.Decisions = status.Decisions[len(status.Decisions)-maxScaleDecisions:]
+	}
+	status.LastScaleTime = &decision.Time
+	r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "Autoscaled", "scaled from %d to %d replicas: %s", from, to, reason)
+}
+
+// scale sets spec.replicas of the AkkaCluster as stored, leaving the rest of it alone.
+func (r *ReconcileAkkaCluster) scale(akkaCluster *appv1alpha1.AkkaCluster, replicas int32) error {
+	scaled := akkaCluster.DeepCopy()
+	scaled.Spec.Replicas = &replicas
+	return r.client.Patch(context.TODO(), scaled, client.MergeFrom(akkaCluster))
+}
+
+// contains is true if list has s.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package akkacluster
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
+)
+
+const (
+	// breakerThreshold is how many reads in a row must fail before a host's circuit opens.
+	breakerThreshold = 3
+	// breakerCooldown is how long an open circuit fails reads before trying the host again.
+	breakerCooldown = 30 * time.Second
+)
+
+// circuit is the health of one management host.
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	// trial is true while the single read let through after the cooldown is in flight.
+	trial bool
+}
+
+// circuitBreakerReader is a management.Reader that stops reading from a host after
+// breakerThreshold failures in a row. While its circuit is open, reads fail at once instead
+// of waiting out a timeout. After breakerCooldown, one read is let through: success closes
+// the circuit, failure opens it again. Pods come and go with their IPs, so hosts that
+// succeed are forgotten.
+type circuitBreakerReader struct {
+	reader    management.Reader
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*circuit
+}
+
+func newCircuitBreakerReader(reader management.Reader) *circuitBreakerReader {
+	return &circuitBreakerReader{
+		reader:    reader,
+		threshold: breakerThreshold,
+		cooldown:  breakerCooldown,
+		now:       time.Now,
+		hosts:     make(map[string]*circuit),
+	}
+}
+
+func (b *circuitBreakerReader) ReadURL(link string) ([]byte, error) {
+	return b.ReadURLTimeout(link, 0)
+}
+
+// ReadURLTimeout passes timeout on to the underlying reader, if it takes one.
+func (b *circuitBreakerReader) ReadURLTimeout(link string, timeout time.Duration) ([]byte, error) {
+	host := link
+	if parsed, err := url.Parse(link); err == nil {
+		host = parsed.Host
+	}
+	if err := b.allow(host); err != nil {
+		return nil, err
+	}
+	body, err := management.ReadWithTimeout(b.reader, link, timeout)
+	b.record(host, err)
+	return body, err
+}
+
+// allow returns an error if the circuit of host is open.
+func (b *circuitBreakerReader) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.hosts[host]
+	if !ok || c.failures < b.threshold {
+		return nil
+	}
+	if c.trial || b.now().Before(c.openUntil) {
+		return fmt.Errorf("circuit open for %s after %d failures", host, c.failures)
+	}
+	c.trial = true
+	return nil
+}
+
+// record counts a failed read against host, or closes its circuit on success.
+func (b *circuitBreakerReader) record(host string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.hosts, host)
+		return
+	}
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+	c.failures++
+	c.trial = false
+	if c.failures >= b.threshold {
+		c.openUntil = b.now().Add(b.cooldown)
+		if c.failures == b.threshold {
+			log.Info("management endpoint circuit opened", "host", host, "cooldown", b.cooldown)
+		}
+	}
+}
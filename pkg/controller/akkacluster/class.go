@@ -0,0 +1,134 @@
+package akkacluster
+
+import (
+	"context"
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// On AkkaClusterClasses:
+//
+// An AkkaCluster naming a class in spec.className is reconciled with its effective spec:
+// the class, with the AkkaCluster's own fields merged over it like a strategic merge patch.
+// Only the effective spec is used, to generate resources and poll status, and it is never
+// written back, so the stored AkkaCluster keeps just what was set on it. Classes are
+// watched, so a change to a class rolls the Deployments of all its AkkaClusters.
+
+// applyClass merges the AkkaClusterClass named by the AkkaCluster into it. Returns false if
+// the AkkaCluster names a class that doesn't exist.
+func (r *ReconcileAkkaCluster) applyClass(akkaCluster *appv1alpha1.AkkaCluster) (bool, error) {
+	if akkaCluster.Spec.ClassName == "" {
+		return true, nil
+	}
+	class := &appv1alpha1.AkkaClusterClass{}
+	key := types.NamespacedName{Namespace: akkaCluster.Namespace, Name: akkaCluster.Spec.ClassName}
+	err := r.client.Get(context.TODO(), key, class)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, mergeClass(akkaCluster, class)
+}
+
+// mergeClass sets the fields of the AkkaCluster that a class has to the class fields merged
+// with its own, which take precedence.
+func mergeClass(akkaCluster *appv1alpha1.AkkaCluster, class *appv1alpha1.AkkaClusterClass) error {
+	local := &appv1alpha1.AkkaClusterClassSpec{
+		Template:           akkaCluster.Spec.Template,
+		SplitBrain:         akkaCluster.Spec.SplitBrain,
+		GeneratedResources: akkaCluster.Spec.GeneratedResources,
+		StatusPolling:      akkaCluster.Spec.StatusPolling,
+	}
+	original, err := toMap(&class.Spec)
+	if err != nil {
+		return err
+	}
+	patch, err := toMap(local)
+	if err != nil {
+		return err
+	}
+	// a field left out of the AkkaCluster is inherited, rather than deleted as null would be
+	dropNulls(patch)
+	merged, err := strategicpatch.StrategicMergeMapPatch(original, patch, appv1alpha1.AkkaClusterClassSpec{})
+	if err != nil {
+		return err
+	}
+	effective := &appv1alpha1.AkkaClusterClassSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(merged, effective); err != nil {
+		return err
+	}
+	akkaCluster.Spec.Template = effective.Template
+	akkaCluster.Spec.SplitBrain = effective.SplitBrain
+	akkaCluster.Spec.GeneratedResources = effective.GeneratedResources
+	akkaCluster.Spec.StatusPolling = effective.StatusPolling
+	return nil
+}
+
+// toMap converts obj to its JSON map.
+func toMap(obj interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	return m, json.Unmarshal(data, &m)
+}
+
+// dropNulls removes null values from a JSON map, at any depth.
+func dropNulls(m map[string]interface{}) {
+	for key, value := range m {
+		switch value := value.(type) {
+		case nil:
+			delete(m, key)
+		case map[string]interface{}:
+			dropNulls(value)
+		case []interface{}:
+			for _, item := range value {
+				if item, ok := item.(map[string]interface{}); ok {
+					dropNulls(item)
+				}
+			}
+		}
+	}
+}
+
+// reportClass sets the ClassNotFound condition on status.
+func (r *ReconcileAkkaCluster) reportClass(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus,
+	found bool) *appv1alpha1.AkkaClusterStatus {
+
+	if found {
+		return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterClassNotFound, true, "ClassFound",
+			"generated resources are reconciled")
+	}
+	return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterClassNotFound, false, "ClassMissing",
+		"AkkaClusterClass "+akkaCluster.Spec.ClassName+" not found, generated resources are left as they are")
+}
+
+// clustersOfClass maps an AkkaClusterClass to requests for the AkkaClusters naming it.
+func (r *ReconcileAkkaCluster) clustersOfClass(obj handler.MapObject) []reconcile.Request {
+	clusters := &appv1alpha1.AkkaClusterList{}
+	if err := r.client.List(context.TODO(), clusters, client.InNamespace(obj.Meta.GetNamespace())); err != nil {
+		log.Error(err, "failed to list AkkaClusters of class", "class", obj.Meta.GetNamespace()+"/"+obj.Meta.GetName())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, cluster := range clusters.Items {
+		if cluster.Spec.ClassName == obj.Meta.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name},
+			})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,165 @@
+package akkacluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// On cluster-scoped RBAC:
+//
+// Rules in spec.rbac.clusterRules are granted by a ClusterRole and ClusterRoleBinding.
+// Owner references can't point from cluster-scoped objects to a namespaced AkkaCluster, so
+// these are tied to their AkkaCluster by the owner labels below instead: labels map watch
+// events back to the AkkaCluster, mark what it may reconcile, and find what to delete. As
+// garbage collection won't take them either, the clusterRBACFinalizer holds deletion of
+// the AkkaCluster until they are deleted.
+//
+// Watching ClusterRoles takes permissions a namespaced operator doesn't have, and its
+// cache would never sync without them, so this is off unless Options.ClusterRBAC is set.
+
+const (
+	// clusterRBACFinalizer holds deletion of an AkkaCluster until its cluster-scoped RBAC
+	// is deleted.
+	clusterRBACFinalizer = "app.lightbend.com/cluster-rbac"
+	// ownerNamespaceLabel and ownerNameLabel name the AkkaCluster of a cluster-scoped
+	// resource. Names too long for a label value are shortened, so ownerNameAnnotation
+	// holds the full name.
+	ownerNamespaceLabel = "app.lightbend.com/akkacluster-namespace"
+	ownerNameLabel      = "app.lightbend.com/akkacluster-name"
+	ownerNameAnnotation = "app.lightbend.com/akkacluster-name"
+)
+
+// clusterScopedGeneratedResourceTypes returns the cluster-scoped GenericResources that
+// might be generated.
+func clusterScopedGeneratedResourceTypes() []GenericResource {
+	return []GenericResource{
+		&rbac.ClusterRoleBinding{},
+		&rbac.ClusterRole{},
+	}
+}
+
+// isClusterScoped is true for generated resources without a namespace.
+func isClusterScoped(resource metav1.Object) bool {
+	return resource.GetNamespace() == ""
+}
+
+// clusterScopedName names the cluster-scoped resources generated for akkaCluster, unique
+// across namespaces.
+func clusterScopedName(akkaCluster *appv1alpha1.AkkaCluster) string {
+	return akkaCluster.Namespace + "-" + generatedName(akkaCluster)
+}
+
+// ownerLabels mark a cluster-scoped resource as generated for akkaCluster.
+func ownerLabels(akkaCluster *appv1alpha1.AkkaCluster) map[string]string {
+	return map[string]string{
+		ownerNamespaceLabel: akkaCluster.Namespace,
+		ownerNameLabel:      ownerLabelValue(akkaCluster.Name),
+	}
+}
+
+// ownerAnnotations carry the full name of akkaCluster on a cluster-scoped resource.
+func ownerAnnotations(akkaCluster *appv1alpha1.AkkaCluster) map[string]string {
+	return map[string]string{ownerNameAnnotation: akkaCluster.Name}
+}
+
+// ownerLabelValue fits an AkkaCluster name into a label value, replacing the end of a
+// long name with a hash of all of it.
+func ownerLabelValue(name string) string {
+	if len(name) <= validation.LabelValueMaxLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:16]
+	return name[:validation.LabelValueMaxLength-len(hash)-1] + "-" + hash
+}
+
+// ownerName is the AkkaCluster name of a cluster-scoped resource, from its annotation or,
+// on resources labelled before it was added, its label.
+func ownerName(obj metav1.Object) string {
+	if name := obj.GetAnnotations()[ownerNameAnnotation]; name != "" {
+		return name
+	}
+	return obj.GetLabels()[ownerNameLabel]
+}
+
+// wantsClusterRBAC is true if the AkkaCluster has cluster rules for a generated
+// ServiceAccount.
+func wantsClusterRBAC(akkaCluster *appv1alpha1.AkkaCluster) bool {
+	spec := akkaCluster.Spec.RBAC
+	return spec != nil && len(spec.ClusterRules) > 0 && akkaCluster.Spec.Template.Spec.ServiceAccountName == ""
+}
+
+// ownerOfClusterScoped maps a cluster-scoped resource to a request for its AkkaCluster.
+func ownerOfClusterScoped(obj handler.MapObject) []reconcile.Request {
+	namespace, name := obj.Meta.GetLabels()[ownerNamespaceLabel], ownerName(obj.Meta)
+	if namespace == "" || name == "" {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: namespace, Name: name},
+	}}
+}
+
+// checkClusterScopedOwnership is checkOwnership for cluster-scoped resources, which are
+// owned by their labels.
+func (r *ReconcileAkkaCluster) checkClusterScopedOwnership(akkaCluster *appv1alpha1.AkkaCluster, kind string, live metav1.Object) error {
+	namespace, name := live.GetLabels()[ownerNamespaceLabel], ownerName(live)
+	if namespace == akkaCluster.Namespace && name == akkaCluster.Name {
+		return nil
+	}
+	if namespace != "" || name != "" {
+		return &nameCollision{kind: kind, name: live.GetName(), owner: "AkkaCluster " + namespace + "/" + name}
+	}
+	spec := akkaCluster.Spec.GeneratedResources
+	if spec == nil || spec.AdoptionPolicy != appv1alpha1.AdoptIfUnowned {
+		return &nameCollision{kind: kind, name: live.GetName()}
+	}
+	if r.recorder != nil {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "Adopted", "adopted existing %s %s", kind, live.GetName())
+	}
+	return nil
+}
+
+// releaseClusterRBAC deletes the cluster-scoped resources of a deleted AkkaCluster, and
+// then removes its clusterRBACFinalizer.
+func (r *ReconcileAkkaCluster) releaseClusterRBAC(akkaCluster *appv1alpha1.AkkaCluster) error {
+	if !hasFinalizer(akkaCluster, clusterRBACFinalizer) {
+		return nil
+	}
+	if r.options.ClusterRBAC {
+		errs := []error{}
+		for _, resourceType := range clusterScopedGeneratedResourceTypes() {
+			items, err := r.listGenerated(resourceType, client.MatchingLabels(ownerLabels(akkaCluster)))
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				if err := r.client.Delete(context.TODO(), item); err != nil && !errors.IsNotFound(err) {
+					errs = append(errs, err)
+				}
+			}
+		}
+		if err := utilerrors.NewAggregate(errs); err != nil {
+			return err
+		}
+	} else if r.recorder != nil {
+		r.recorder.Event(akkaCluster, corev1.EventTypeWarning, "ClusterRBACDisabled",
+			"cluster-scoped RBAC is left behind, as the operator runs without --cluster-rbac")
+	}
+	removeFinalizer(akkaCluster, clusterRBACFinalizer)
+	return r.client.Update(context.TODO(), akkaCluster)
+}
@@ -0,0 +1,78 @@
+package akkacluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// collisionRetryInterval is how often a name collision is checked again. The colliding
+// resource isn't owned, so no watch fires when it goes away.
+const collisionRetryInterval = time.Minute
+
+// nameCollision is a generated resource whose name is taken by a resource the AkkaCluster
+// doesn't control.
+type nameCollision struct {
+	kind  string
+	name  string
+	owner string
+}
+
+func (c *nameCollision) Error() string {
+	if c.owner != "" {
+		return fmt.Sprintf("%s %s exists and is controlled by %s", c.kind, c.name, c.owner)
+	}
+	return fmt.Sprintf("%s %s exists and is not controlled by this AkkaCluster", c.kind, c.name)
+}
+
+// checkOwnership returns a nameCollision if the live resource of a generated name can't be
+// reconciled. Resources controlled by something else never are, while unowned resources
+// are adopted only if the AkkaCluster asks for it.
+func (r *ReconcileAkkaCluster) checkOwnership(akkaCluster *appv1alpha1.AkkaCluster, kind string, live runtime.Object) error {
+	liveMeta, err := meta.Accessor(live)
+	if err != nil {
+		return err
+	}
+	if isClusterScoped(liveMeta) {
+		return r.checkClusterScopedOwnership(akkaCluster, kind, liveMeta)
+	}
+	if metav1.IsControlledBy(liveMeta, akkaCluster) {
+		return nil
+	}
+	if owner := metav1.GetControllerOf(liveMeta); owner != nil {
+		return &nameCollision{kind: kind, name: liveMeta.GetName(), owner: owner.Kind + " " + owner.Name}
+	}
+	spec := akkaCluster.Spec.GeneratedResources
+	if spec == nil || spec.AdoptionPolicy != appv1alpha1.AdoptIfUnowned {
+		return &nameCollision{kind: kind, name: liveMeta.GetName()}
+	}
+	if r.recorder != nil {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "Adopted", "adopted existing %s %s", kind, liveMeta.GetName())
+	}
+	return nil
+}
+
+// reportCollisions sets the NameCollision condition on status.
+func (r *ReconcileAkkaCluster) reportCollisions(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus,
+	collisions []*nameCollision) *appv1alpha1.AkkaClusterStatus {
+
+	if len(collisions) == 0 {
+		return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterNameCollision, true, "NoCollision",
+			"all generated resources are controlled by this AkkaCluster")
+	}
+	messages := []string{}
+	for _, collision := range collisions {
+		messages = append(messages, collision.Error())
+	}
+	sort.Strings(messages)
+	return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterNameCollision, false, "ResourceExists",
+		strings.Join(messages, "; "))
+}
@@ -0,0 +1,113 @@
+package akkacluster
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// reconcileConditions are owned by Reconcile rather than the StatusActor, so they are
+// carried over from the AkkaCluster when taking status from the StatusActor.
+var reconcileConditions = []appv1alpha1.AkkaClusterConditionType{
+	appv1alpha1.AkkaClusterPaused,
+	appv1alpha1.AkkaClusterNameCollision,
+	appv1alpha1.AkkaClusterClassNotFound,
+	appv1alpha1.AkkaClusterLeaseUnavailable,
+	appv1alpha1.AkkaClusterRulesRefused,
+	appv1alpha1.AkkaClusterAutoscalingUnsupported,
+	appv1alpha1.AkkaClusterNameChangeRefused,
+}
+
+// findCondition returns the condition of the given type, or nil if there is none.
+func findCondition(status *appv1alpha1.AkkaClusterStatus, conditionType appv1alpha1.AkkaClusterConditionType) *appv1alpha1.AkkaClusterCondition {
+	if status == nil {
+		return nil
+	}
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// setCondition adds or updates a condition on status. LastTransitionTime only moves when
+// the condition status changes, so setting the same condition repeatedly is a no-op and
+// won't trigger needless status updates. Returns true if the condition status changed.
+func setCondition(status *appv1alpha1.AkkaClusterStatus, conditionType appv1alpha1.AkkaClusterConditionType,
+	conditionStatus corev1.ConditionStatus, reason, message string) bool {
+
+	existing := findCondition(status, conditionType)
+	if existing == nil {
+		status.Conditions = append(status.Conditions, appv1alpha1.AkkaClusterCondition{
+			Type:               conditionType,
+			Status:             conditionStatus,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		})
+		return true
+	}
+	changed := existing.Status != conditionStatus
+	if changed {
+		existing.Status = conditionStatus
+		existing.LastTransitionTime = metav1.Now()
+	}
+	existing.Reason = reason
+	existing.Message = message
+	return changed
+}
+
+// reportCondition sets a condition owned by Reconcile on status: true unless ok, with the
+// given reason and message either way. An Event with the same reason and message is
+// recorded when the condition changes, a Warning when it turns true. AkkaClusters that
+// were always ok don't get the condition at all. Returns the status, which is allocated if
+// needed.
+func (r *ReconcileAkkaCluster) reportCondition(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus,
+	conditionType appv1alpha1.AkkaClusterConditionType, ok bool, reason, message string) *appv1alpha1.AkkaClusterStatus {
+
+	if ok && findCondition(status, conditionType) == nil {
+		return status
+	}
+	if status == nil {
+		status = &appv1alpha1.AkkaClusterStatus{}
+	}
+	conditionStatus, eventType := corev1.ConditionTrue, corev1.EventTypeWarning
+	if ok {
+		conditionStatus, eventType = corev1.ConditionFalse, corev1.EventTypeNormal
+	}
+	if setCondition(status, conditionType, conditionStatus, reason, message) && r.recorder != nil {
+		r.recorder.Event(akkaCluster, eventType, reason, message)
+	}
+	return status
+}
+
+// isConditionTrue reports whether status has a condition of the given type set to true.
+func isConditionTrue(status *appv1alpha1.AkkaClusterStatus, conditionType appv1alpha1.AkkaClusterConditionType) bool {
+	c := findCondition(status, conditionType)
+	return c != nil && c.Status == corev1.ConditionTrue
+}
+
+// copyCondition makes the condition of the given type on to match the one on from, adding,
+// replacing or removing it as needed while keeping the order of other conditions.
+func copyCondition(from, to *appv1alpha1.AkkaClusterStatus, conditionType appv1alpha1.AkkaClusterConditionType) {
+	source := findCondition(from, conditionType)
+	if target := findCondition(to, conditionType); target != nil && source != nil {
+		source.DeepCopyInto(target)
+		return
+	}
+	if source != nil {
+		to.Conditions = append(to.Conditions, *source.DeepCopy())
+		return
+	}
+	for i := range to.Conditions {
+		if to.Conditions[i].Type == conditionType {
+			to.Conditions = append(to.Conditions[:i], to.Conditions[i+1:]...)
+			if len(to.Conditions) == 0 {
+				to.Conditions = nil // as read back from the API, where it is omitted
+			}
+			return
+		}
+	}
+}
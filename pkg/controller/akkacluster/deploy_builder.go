@@ -1,8 +1,6 @@
 package akkacluster
 
 import (
-	"encoding/json"
-
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
@@ -10,6 +8,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 
 	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/config"
 )
 
 // GenericResource have both meta and runtime interfaces
@@ -29,35 +28,46 @@ func allPossibleGeneratedResourceTypes() []GenericResource {
 	}
 }
 
+// generatedName names the resources generated for akkaCluster, with any configured prefix
+// and suffix around the AkkaCluster name.
+func generatedName(akkaCluster *appv1alpha1.AkkaCluster) string {
+	spec := akkaCluster.Spec.GeneratedResources
+	if spec == nil {
+		return akkaCluster.Name
+	}
+	return spec.NamePrefix + akkaCluster.Name + spec.NameSuffix
+}
+
 // generateResources produces a list of rbac and deployment resources suitable for akkaCluster.
 // If akkaCluster resource does not specify needed options, we provide defaults. Note that these
 // objects are used as a subset reference for testing cluster object correctness, so be careful
 // not to fill in ephemeral fields here like timestamps, uuids. Return the expected reference objects.
-func generateResources(akkaCluster *appv1alpha1.AkkaCluster) []GenericResource {
+// Deployments get the given defaults where akkaCluster leaves them out.
+func generateResources(akkaCluster *appv1alpha1.AkkaCluster, defaults config.DeploymentDefaults) []GenericResource {
 	resources := []GenericResource{}
 
 	// if akkaCluster has no serviceAccount, generate rbac resources
 	if akkaCluster.Spec.Template.Spec.ServiceAccountName == "" {
 		// serviceAccount
 		serviceAccount := &corev1.ServiceAccount{}
-		serviceAccount.Name = akkaCluster.Name
+		serviceAccount.Name = generatedName(akkaCluster)
 		serviceAccount.Namespace = akkaCluster.Namespace
 
 		// role
 		role := &rbac.Role{}
-		role.Name = akkaCluster.Name
+		role.Name = generatedName(akkaCluster)
 		role.Namespace = akkaCluster.Namespace
-		role.Rules = []rbac.PolicyRule{
-			{
-				APIGroups: []string{""},
-				Resources: []string{"pods"},
-				Verbs:     []string{"get", "watch", "list"},
-			},
+		role.Rules = []rbac.PolicyRule{podReaderRule()}
+		if leaseMajority(akkaCluster) != nil {
+			role.Rules = append(role.Rules, leaseRule())
+		}
+		if akkaCluster.Spec.RBAC != nil {
+			role.Rules = append(role.Rules, akkaCluster.Spec.RBAC.ExtraRules...)
 		}
 
 		// rolebinding
 		roleBinding := &rbac.RoleBinding{}
-		roleBinding.Name = akkaCluster.Name
+		roleBinding.Name = generatedName(akkaCluster)
 		roleBinding.Namespace = akkaCluster.Namespace
 		roleBinding.RoleRef = rbac.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
@@ -76,52 +86,114 @@ func generateResources(akkaCluster *appv1alpha1.AkkaCluster) []GenericResource {
 
 		// enqueue rbac resources for creation later
 		resources = append(resources, serviceAccount, role, roleBinding)
+
+		// cluster-wide rules, tied to the AkkaCluster by labels rather than ownership
+		if akkaCluster.Spec.RBAC != nil && len(akkaCluster.Spec.RBAC.ClusterRules) > 0 {
+			clusterRole := &rbac.ClusterRole{}
+			clusterRole.Name = clusterScopedName(akkaCluster)
+			clusterRole.Labels = ownerLabels(akkaCluster)
+			clusterRole.Annotations = ownerAnnotations(akkaCluster)
+			clusterRole.Rules = akkaCluster.Spec.RBAC.ClusterRules
+
+			clusterRoleBinding := &rbac.ClusterRoleBinding{}
+			clusterRoleBinding.Name = clusterScopedName(akkaCluster)
+			clusterRoleBinding.Labels = ownerLabels(akkaCluster)
+			clusterRoleBinding.Annotations = ownerAnnotations(akkaCluster)
+			clusterRoleBinding.RoleRef = rbac.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     clusterRole.Name,
+			}
+			clusterRoleBinding.Subjects = []rbac.Subject{
+				{
+					Kind:      "ServiceAccount",
+					Name:      serviceAccount.Name,
+					Namespace: serviceAccount.Namespace,
+				},
+			}
+
+			resources = append(resources, clusterRole, clusterRoleBinding)
+		}
 	}
 
 	// default label selector, if none given
 	if akkaCluster.Spec.Selector == nil {
-		selectorKey := "app"
-		akkaCluster.Spec.Selector = &metav1.LabelSelector{
-			MatchLabels: map[string]string{
-				selectorKey: akkaCluster.Name,
-			},
-		}
+		akkaCluster.Spec.Selector = defaultSelector(akkaCluster, defaults.SelectorKey)
 		if akkaCluster.Spec.Template.Labels == nil {
 			akkaCluster.Spec.Template.Labels = make(map[string]string)
 		}
-		akkaCluster.Spec.Template.Labels[selectorKey] = akkaCluster.Name
+		akkaCluster.Spec.Template.Labels[defaults.SelectorKey] = akkaCluster.Name
 	}
 
 	// default strategy, if none given
-	if akkaCluster.Spec.Strategy.Type == "" {
-		// use json serializer here as easier to read
-		json.Unmarshal([]byte(`{
-			"type": "`+appsv1.RollingUpdateDeploymentStrategyType+`",
-			"rollingUpdate": {
-				"maxSurge": 1,
-				"maxUnavailable": 0
-			}
-		}`), &akkaCluster.Spec.Strategy)
+	if akkaCluster.Spec.Strategy.Type == "" && defaults.Strategy != nil {
+		akkaCluster.Spec.Strategy = *defaults.Strategy.DeepCopy()
 	}
 
-	// env settings
+	// env settings, unless turned off or already set
 	for i := range akkaCluster.Spec.Template.Spec.Containers {
+		if !wantsBootstrapEnv(akkaCluster) || hasEnv(&akkaCluster.Spec.Template.Spec.Containers[i], defaults.BootstrapServiceNameEnv) {
+			continue
+		}
 		akkaCluster.Spec.Template.Spec.Containers[i].Env = append(akkaCluster.Spec.Template.Spec.Containers[i].Env,
 			corev1.EnvVar{
-				Name:  "AKKA_CLUSTER_BOOTSTRAP_SERVICE_NAME",
+				Name:  defaults.BootstrapServiceNameEnv,
 				Value: akkaCluster.Name,
 			},
 			// TODO CONTACT_PT_NR
 		)
 	}
 
+	// split brain resolver settings, unless the container picks its own
+	if leaseMajority(akkaCluster) != nil {
+		for i := range akkaCluster.Spec.Template.Spec.Containers {
+			addResolverOptions(&akkaCluster.Spec.Template.Spec.Containers[i], leaseName(akkaCluster))
+		}
+	}
+
 	// set up deployment spec
 	deployment := &appsv1.Deployment{}
-	deployment.Name = akkaCluster.Name
+	deployment.Name = generatedName(akkaCluster)
 	deployment.Namespace = akkaCluster.Namespace
-	deployment.Spec = akkaCluster.Spec
+	deployment.Spec = akkaCluster.Spec.DeploymentSpec
 
 	resources = append(resources, deployment)
 
 	return resources
 }
+
+// defaultSelector selects the pods of an AkkaCluster that has no selector by its name, in
+// the label of selectorKey.
+func defaultSelector(akkaCluster *appv1alpha1.AkkaCluster, selectorKey string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			selectorKey: akkaCluster.Name,
+		},
+	}
+}
+
+// wantsBootstrapEnv is true unless the AkkaCluster turns off the bootstrap env setting.
+func wantsBootstrapEnv(akkaCluster *appv1alpha1.AkkaCluster) bool {
+	spec := akkaCluster.Spec.GeneratedResources
+	return spec == nil || spec.BootstrapEnv == nil || *spec.BootstrapEnv
+}
+
+// hasEnv is true if the container sets the named env var.
+func hasEnv(container *corev1.Container, name string) bool {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// podReaderRule lets the generated ServiceAccount list pods, as Akka Management does to
+// find its peers.
+func podReaderRule() rbac.PolicyRule {
+	return rbac.PolicyRule{
+		APIGroups: []string{""},
+		Resources: []string{"pods"},
+		Verbs:     []string{"get", "watch", "list"},
+	}
+}
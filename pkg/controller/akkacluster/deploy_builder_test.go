@@ -83,7 +83,7 @@ func TestGenerateResources(t *testing.T) {
 
 		obj := decoder(akkaClusterYaml)
 		base, _ := obj.(*appv1alpha1.AkkaCluster)
-		res := generateResources(base)
+		res := generateResources(base, DefaultOptions().Deployment)
 
 		res = append(res, base)
 		for _, r := range res {
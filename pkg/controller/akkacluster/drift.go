@@ -0,0 +1,36 @@
+package akkacluster
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// maxEventDiffs bounds the number of field diffs spelled out in a drift Event.
+const maxEventDiffs = 5
+
+// describeDrift lists up to max diffs, noting how many more there are.
+func describeDrift(diffs []Diff, max int) string {
+	lines := []string{}
+	for i, diff := range diffs {
+		if i == max {
+			lines = append(lines, fmt.Sprintf("and %d more", len(diffs)-max))
+			break
+		}
+		lines = append(lines, diff.String())
+	}
+	return strings.Join(lines, "; ")
+}
+
+// reportDrift records an Event on the AkkaCluster explaining why a generated resource is
+// about to be applied again.
+func (r *ReconcileAkkaCluster) reportDrift(akkaCluster *appv1alpha1.AkkaCluster, kind string, diffs []Diff) {
+	if r.recorder == nil || len(diffs) == 0 {
+		return
+	}
+	r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "DriftDetected",
+		"%s differs from wanted: %s", kind, describeDrift(diffs, maxEventDiffs))
+}
@@ -0,0 +1,283 @@
+package akkacluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// FieldPath is a parsed path into a resource, in the segments SubsetDiff uses for its
+// paths: .name for fields, and [0], [name=main], [key] or [*] for list elements and map
+// values. [*] matches every element.
+type FieldPath []string
+
+// ParseFieldPath parses paths like spec.template.spec.containers[*].resources.
+func ParseFieldPath(path string) (FieldPath, error) {
+	segments := FieldPath{}
+	for rest := path; rest != ""; {
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 2 {
+				return nil, fmt.Errorf("invalid field path %q: unterminated or empty brackets", path)
+			}
+			segments = append(segments, rest[:end+1])
+			rest = rest[end+1:]
+		case rest[0] == '.' && len(segments) > 0:
+			rest = rest[1:]
+			fallthrough
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid field path %q: empty field name", path)
+			}
+			segments = append(segments, "."+rest[:end])
+			rest = rest[end:]
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("invalid field path %q: empty", path)
+	}
+	return segments, nil
+}
+
+func (p FieldPath) String() string {
+	return strings.TrimPrefix(strings.Join(p, ""), ".")
+}
+
+// matches is true if path, as segments, is the same as p, with [*] matching any element.
+func (p FieldPath) matches(path []string) bool {
+	if len(path) != len(p) {
+		return false
+	}
+	for i := range p {
+		if p[i] != path[i] && !(p[i] == "[*]" && strings.HasPrefix(path[i], "[")) {
+			return false
+		}
+	}
+	return true
+}
+
+// removeFrom deletes whatever p matches from the unstructured content of a resource.
+func (p FieldPath) removeFrom(content map[string]interface{}) {
+	removeSegments(content, p)
+}
+
+func removeSegments(value interface{}, segments []string) {
+	if len(segments) == 0 {
+		return
+	}
+	segment, last := segments[0], len(segments) == 1
+	switch v := value.(type) {
+	case map[string]interface{}:
+		key := strings.TrimPrefix(segment, ".")
+		if strings.HasPrefix(segment, "[") {
+			key = segment[1 : len(segment)-1]
+		}
+		for k, child := range v {
+			if k != key && segment != "[*]" {
+				continue
+			}
+			if last {
+				delete(v, k)
+			} else {
+				removeSegments(child, segments[1:])
+			}
+		}
+	case []interface{}:
+		// paths end in a field, as removing whole elements would shift the others
+		if last {
+			return
+		}
+		for i, child := range v {
+			if listElementMatches(segment, i, child) {
+				removeSegments(child, segments[1:])
+			}
+		}
+	}
+}
+
+// listElementMatches is true if segment, like [*], [2] or [name=main], selects element i.
+func listElementMatches(segment string, i int, element interface{}) bool {
+	selector := strings.TrimSuffix(strings.TrimPrefix(segment, "["), "]")
+	if selector == "*" {
+		return true
+	}
+	if index, err := strconv.Atoi(selector); err == nil {
+		return index == i
+	}
+	parts := strings.SplitN(selector, "=", 2)
+	fields, ok := element.(map[string]interface{})
+	return ok && len(parts) == 2 && fmt.Sprint(fields[parts[0]]) == parts[1]
+}
+
+// segmentKey is the map key a segment like .name or [key] selects.
+func segmentKey(segment string) string {
+	if strings.HasPrefix(segment, "[") {
+		return segment[1 : len(segment)-1]
+	}
+	return strings.TrimPrefix(segment, ".")
+}
+
+// ownedIn is true if fields, the managed fields of one field manager, include whatever p
+// matches in content, the unstructured resource they describe.
+func (p FieldPath) ownedIn(fields map[string]interface{}, content map[string]interface{}) bool {
+	return ownedSegments(fields, content, p)
+}
+
+func ownedSegments(fields map[string]interface{}, value interface{}, segments []string) bool {
+	if len(segments) == 0 {
+		return true
+	}
+	segment, rest := segments[0], segments[1:]
+	switch v := value.(type) {
+	case map[string]interface{}:
+		key := segmentKey(segment)
+		for k, child := range v {
+			if k != key && segment != "[*]" {
+				continue
+			}
+			if owned, ok := fields["f:"+k].(map[string]interface{}); ok && ownedSegments(owned, child, rest) {
+				return true
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if !listElementMatches(segment, i, child) {
+				continue
+			}
+			if owned := elementFields(fields, child); owned != nil && ownedSegments(owned, child, rest) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// elementFields finds the managed fields of a list element, listed under the values of its
+// keys like k:{"name":"main"}.
+func elementFields(fields map[string]interface{}, element interface{}) map[string]interface{} {
+	values, ok := element.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for key, owned := range fields {
+		keys := map[string]interface{}{}
+		if !strings.HasPrefix(key, "k:") || json.Unmarshal([]byte(key[2:]), &keys) != nil {
+			continue
+		}
+		matches := true
+		for k, want := range keys {
+			if fmt.Sprint(values[k]) != fmt.Sprint(want) {
+				matches = false
+			}
+		}
+		if matches {
+			fields, _ := owned.(map[string]interface{})
+			return fields
+		}
+	}
+	return nil
+}
+
+// extractTo copies whatever p matches in the unstructured content of from onto to, along
+// with the fields leading to it. List elements are only taken by name, which identifies
+// them in an apply.
+func (p FieldPath) extractTo(from, to map[string]interface{}) {
+	extractSegments(from, to, p)
+}
+
+func extractSegments(from, to interface{}, segments []string) interface{} {
+	if len(segments) == 0 {
+		return runtime.DeepCopyJSONValue(from)
+	}
+	segment, rest := segments[0], segments[1:]
+	switch source := from.(type) {
+	case map[string]interface{}:
+		target, _ := to.(map[string]interface{})
+		if target == nil {
+			target = map[string]interface{}{}
+		}
+		key := segmentKey(segment)
+		for k, child := range source {
+			if k != key && segment != "[*]" {
+				continue
+			}
+			// leave out what leads nowhere, like a list without named elements
+			if extracted := extractSegments(child, target[k], rest); len(rest) == 0 || !isEmptyJSON(extracted) {
+				target[k] = extracted
+			}
+		}
+		return target
+	case []interface{}:
+		target, _ := to.([]interface{})
+		for i, child := range source {
+			fields, ok := child.(map[string]interface{})
+			if !ok || fields["name"] == nil || !listElementMatches(segment, i, child) {
+				continue
+			}
+			found := false
+			for j, existing := range target {
+				if element, ok := existing.(map[string]interface{}); ok && element["name"] == fields["name"] {
+					target[j], found = extractSegments(child, element, rest), true
+				}
+			}
+			if !found {
+				target = append(target, extractSegments(child, map[string]interface{}{"name": fields["name"]}, rest))
+			}
+		}
+		return target
+	}
+	return to
+}
+
+// isEmptyJSON is true for nil, and for empty maps and lists.
+func isEmptyJSON(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return value == nil
+}
+
+// pruneFields converts a resource to unstructured form without the ignored paths, so that
+// applying the result leaves those fields to whichever controller sets them.
+func pruneFields(resource runtime.Object, ignored []FieldPath) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(resource)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range ignored {
+		path.removeFrom(content)
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// ignoredFields parses the ignored field paths of the AkkaCluster, which are paths in its
+// Deployment. Paths that don't parse are reported in an Event and otherwise skipped.
+func (r *ReconcileAkkaCluster) ignoredFields(akkaCluster *appv1alpha1.AkkaCluster) []FieldPath {
+	paths := []FieldPath{}
+	for _, field := range akkaCluster.Spec.IgnoredFields {
+		path, err := ParseFieldPath(field)
+		if err != nil {
+			if r.recorder != nil {
+				r.recorder.Event(akkaCluster, corev1.EventTypeWarning, "InvalidIgnoredField", err.Error())
+			}
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
@@ -0,0 +1,144 @@
+package akkacluster
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	valid := map[string]FieldPath{
+		"spec.replicas": {".spec", ".replicas"},
+		"spec.template.spec.containers[*].resources":      {".spec", ".template", ".spec", ".containers", "[*]", ".resources"},
+		"spec.template.spec.containers[name=main].env[0]": {".spec", ".template", ".spec", ".containers", "[name=main]", ".env", "[0]"},
+		"metadata.annotations[example.com/owner.team]":    {".metadata", ".annotations", "[example.com/owner.team]"},
+	}
+	for path, expected := range valid {
+		parsed, err := ParseFieldPath(path)
+		if err != nil {
+			t.Errorf("expected %s to parse, but got %v", path, err)
+			continue
+		}
+		if !reflect.DeepEqual(parsed, expected) {
+			t.Errorf("expected %s to parse as %v, but got %v", path, expected, parsed)
+		}
+		if parsed.String() != path {
+			t.Errorf("expected %s to print as itself, but got %s", path, parsed)
+		}
+	}
+	for _, path := range []string{"", ".spec", "spec..replicas", "spec.containers[", "spec.containers[]"} {
+		if _, err := ParseFieldPath(path); err == nil {
+			t.Errorf("expected %q not to parse", path)
+		}
+	}
+}
+
+func TestPruneFields(t *testing.T) {
+	replicas := int32(3)
+	deployment := &appsv1.Deployment{}
+	deployment.Spec.Replicas = &replicas
+	deployment.Spec.Template.Spec.Containers = []corev1.Container{
+		{Name: "main", Image: "akka-cluster:1.0.0", Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+		}},
+		{Name: "sidecar", Image: "proxy:1.0.0"},
+	}
+	ignored := []FieldPath{}
+	for _, path := range []string{"spec.replicas", "spec.template.spec.containers[*].resources", "spec.template.spec.containers[name=sidecar].image"} {
+		parsed, _ := ParseFieldPath(path)
+		ignored = append(ignored, parsed)
+	}
+	pruned, err := pruneFields(deployment, ignored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(pruned.Object, "spec", "replicas"); found {
+		t.Error("expected replicas to be pruned")
+	}
+	containers, _, _ := unstructured.NestedSlice(pruned.Object, "spec", "template", "spec", "containers")
+	if len(containers) != 2 {
+		t.Fatalf("expected containers to be kept, but got %v", containers)
+	}
+	main, sidecar := containers[0].(map[string]interface{}), containers[1].(map[string]interface{})
+	if _, found := main["resources"]; found {
+		t.Errorf("expected resources to be pruned, but got %v", main)
+	}
+	if main["image"] != "akka-cluster:1.0.0" {
+		t.Errorf("expected main image to be kept, but got %v", main)
+	}
+	if _, found := sidecar["image"]; found {
+		t.Errorf("expected sidecar image to be pruned, but got %v", sidecar)
+	}
+
+	// the typed resource itself is left alone
+	if *deployment.Spec.Replicas != 3 {
+		t.Errorf("expected wanted replicas to be untouched")
+	}
+
+	// the wanted values are left to be set elsewhere, like in a hand-off
+	content, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
+	extracted := map[string]interface{}{}
+	for _, path := range ignored {
+		path.extractTo(content, extracted)
+	}
+	expected := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "main", "resources": map[string]interface{}{
+							"limits": map[string]interface{}{"cpu": "1"},
+						}},
+						map[string]interface{}{"name": "sidecar", "resources": map[string]interface{}{}, "image": "proxy:1.0.0"},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(extracted, expected) {
+		t.Errorf("expected only ignored fields and container names extracted, but got %v", extracted)
+	}
+}
+
+func TestOwnedIn(t *testing.T) {
+	content := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "main", "resources": map[string]interface{}{}},
+						map[string]interface{}{"name": "sidecar", "resources": map[string]interface{}{}},
+					},
+				},
+			},
+		},
+	}
+	owned := map[string]interface{}{}
+	json.Unmarshal([]byte(`{"f:spec":{"f:replicas":{},"f:template":{"f:spec":{"f:containers":{
+		"k:{\"name\":\"main\"}":{".":{},"f:name":{},"f:resources":{}}}}}}}`), &owned)
+	tests := []struct {
+		path  string
+		owned bool
+	}{
+		{"spec.replicas", true},
+		{"spec.paused", false},
+		{"spec.template.spec.containers[*].resources", true},
+		{"spec.template.spec.containers[name=main].resources", true},
+		{"spec.template.spec.containers[1].resources", false},
+		{"spec.template.spec.containers[name=sidecar].resources", false},
+	}
+	for _, test := range tests {
+		path, _ := ParseFieldPath(test.path)
+		if owned := path.ownedIn(owned, content); owned != test.owned {
+			t.Errorf("%s: expected owned %v, but got %v", test.path, test.owned, owned)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package akkacluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// On grantable rules:
+//
+// The operator creates the Roles and ClusterRoles of spec.rbac, so without a limit anyone
+// who may create an AkkaCluster could grant their ServiceAccount whatever the operator may
+// do, or more where it holds bind or escalate. Options.GrantableRules is that limit, and
+// rules it doesn't cover are left out of the generated resources. A wildcard asked for is
+// only covered by a wildcard in GrantableRules, never by a list of names.
+//
+// Rules copied from an adopted Role were granted before the operator took over, and are
+// kept even where GrantableRules don't cover them, so adopting never narrows the Role. As
+// anyone who may edit the AkkaCluster could edit the adoptedRulesAnnotation listing them,
+// only those the live Role still has are kept this way: the annotation can keep a rule,
+// but never add one.
+
+// refuseRules drops the rules of spec.rbac that GrantableRules don't cover from the
+// effective akkaCluster, returning a description of each one dropped. Extra rules in
+// granted are kept. Cluster rules are all dropped while ClusterRBAC is off.
+func (r *ReconcileAkkaCluster) refuseRules(akkaCluster *appv1alpha1.AkkaCluster, granted []rbac.PolicyRule) []string {
+	spec := akkaCluster.Spec.RBAC
+	if spec == nil {
+		return nil
+	}
+	refused := []string{}
+	if !r.options.ClusterRBAC && wantsClusterRBAC(akkaCluster) {
+		refused = append(refused, "spec.rbac.clusterRules (the operator runs without --cluster-rbac)")
+		spec.ClusterRules = nil
+	}
+	keep := func(field string, rules, granted []rbac.PolicyRule) []rbac.PolicyRule {
+		kept := []rbac.PolicyRule{}
+		for i, rule := range rules {
+			if coversRule(r.options.GrantableRules, rule) || hasRule(granted, rule) {
+				kept = append(kept, rule)
+				continue
+			}
+			refused = append(refused, fmt.Sprintf("%s[%d] (%s)", field, i, describeRule(rule)))
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return kept
+	}
+	spec.ExtraRules = keep("spec.rbac.extraRules", spec.ExtraRules, granted)
+	spec.ClusterRules = keep("spec.rbac.clusterRules", spec.ClusterRules, nil)
+	return refused
+}
+
+// grantedRules are the rules of the adoptedRulesAnnotation that the live Role of the
+// generated name still has, unless another controller owns that Role.
+func (r *ReconcileAkkaCluster) grantedRules(akkaCluster *appv1alpha1.AkkaCluster) ([]rbac.PolicyRule, error) {
+	data, ok := akkaCluster.Annotations[adoptedRulesAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	adopted := []rbac.PolicyRule{}
+	if err := json.Unmarshal([]byte(data), &adopted); err != nil {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "RulesRefused", "invalid %s annotation: %v", adoptedRulesAnnotation, err)
+		return nil, nil
+	}
+	role := &rbac.Role{}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: akkaCluster.Namespace, Name: generatedName(akkaCluster)}, role)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if owner := metav1.GetControllerOf(role); owner != nil && owner.UID != akkaCluster.UID {
+		return nil, nil
+	}
+	granted := []rbac.PolicyRule{}
+	for _, rule := range adopted {
+		if hasRule(role.Rules, rule) {
+			granted = append(granted, rule)
+		}
+	}
+	return granted, nil
+}
+
+// coversRule is true if every verb, resource and resource name that rule grants is granted
+// by one of allowed.
+func coversRule(allowed []rbac.PolicyRule, rule rbac.PolicyRule) bool {
+	names := rule.ResourceNames
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	for _, group := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			for _, name := range names {
+				for _, verb := range rule.Verbs {
+					if !coversResource(allowed, group, resource, name, verb) {
+						return false
+					}
+				}
+			}
+		}
+	}
+	for _, path := range rule.NonResourceURLs {
+		for _, verb := range rule.Verbs {
+			if !coversURL(allowed, path, verb) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// coversResource is true if one of allowed grants verb on the named resource, or on all of
+// them if name is empty.
+func coversResource(allowed []rbac.PolicyRule, group, resource, name, verb string) bool {
+	for _, rule := range allowed {
+		if !hasItem(rule.APIGroups, group) || !hasItem(rule.Resources, resource) || !hasItem(rule.Verbs, verb) {
+			continue
+		}
+		if len(rule.ResourceNames) == 0 || (name != "" && contains(rule.ResourceNames, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// coversURL is true if one of allowed grants verb on path, where an allowed path ending in
+// * covers every path it prefixes.
+func coversURL(allowed []rbac.PolicyRule, path, verb string) bool {
+	for _, rule := range allowed {
+		if !hasItem(rule.Verbs, verb) {
+			continue
+		}
+		for _, allowedPath := range rule.NonResourceURLs {
+			if allowedPath == path || (strings.HasSuffix(allowedPath, "*") && strings.HasPrefix(path, strings.TrimSuffix(allowedPath, "*"))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasItem is true if items has item, or the wildcard.
+func hasItem(items []string, item string) bool {
+	return contains(items, rbac.ResourceAll) || contains(items, item)
+}
+
+// describeRule summarizes rule for conditions and Events.
+func describeRule(rule rbac.PolicyRule) string {
+	parts := []string{"verbs " + strings.Join(rule.Verbs, ",")}
+	if len(rule.Resources) > 0 {
+		groups := make([]string, len(rule.APIGroups))
+		for i, group := range rule.APIGroups {
+			groups[i] = fmt.Sprintf("%q", group)
+		}
+		parts = append(parts, "on "+strings.Join(rule.Resources, ",")+" in groups "+strings.Join(groups, ","))
+	}
+	if len(rule.ResourceNames) > 0 {
+		parts = append(parts, "named "+strings.Join(rule.ResourceNames, ","))
+	}
+	if len(rule.NonResourceURLs) > 0 {
+		parts = append(parts, "on "+strings.Join(rule.NonResourceURLs, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// reportRulesRefused sets the RulesRefused condition on status.
+func (r *ReconcileAkkaCluster) reportRulesRefused(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus,
+	refused []string) *appv1alpha1.AkkaClusterStatus {
+
+	if len(refused) == 0 {
+		return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterRulesRefused, true, "Grantable",
+			"all rules of spec.rbac are granted")
+	}
+	sort.Strings(refused)
+	return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterRulesRefused, false, "NotGrantable",
+		"not granted, as the operator configuration doesn't allow it: "+strings.Join(refused, "; "))
+}
@@ -0,0 +1,87 @@
+package akkacluster
+
+import (
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// maxTransitions is how many recent member transitions are kept for each cluster.
+const maxTransitions = 20
+
+// memberTransition is a change in the status or reachability of one member.
+type memberTransition struct {
+	Time metav1.Time `json:"time"`
+	Node string      `json:"node"`
+	// From is empty for a member seen for the first time.
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+}
+
+// memberHistory keeps the last seen state of the members of each cluster, and the recent
+// transitions between states. The first sighting of a cluster is only a baseline, so a
+// restarted operator doesn't report every member as new.
+type memberHistory struct {
+	mu          sync.Mutex
+	seen        map[types.NamespacedName]map[string]string
+	transitions map[types.NamespacedName][]memberTransition
+}
+
+func newMemberHistory() *memberHistory {
+	return &memberHistory{
+		seen:        make(map[types.NamespacedName]map[string]string),
+		transitions: make(map[types.NamespacedName][]memberTransition),
+	}
+}
+
+// memberState is the status of a member, marked when it is unreachable.
+func memberState(member memberSummary) string {
+	if !member.Reachable {
+		return member.Status + " (unreachable)"
+	}
+	return member.Status
+}
+
+// observe records the transitions from the last seen members of a cluster to members, and
+// returns the recent transitions, oldest first.
+func (h *memberHistory) observe(name types.NamespacedName, members []memberSummary) []memberTransition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	current := make(map[string]string)
+	for _, member := range members {
+		current[member.Node] = memberState(member)
+	}
+	if previous, known := h.seen[name]; known {
+		now := metav1.Now()
+		changes := []memberTransition{}
+		for node, state := range current {
+			if previous[node] != state {
+				changes = append(changes, memberTransition{Time: now, Node: node, From: previous[node], To: state})
+			}
+		}
+		for node, state := range previous {
+			if _, ok := current[node]; !ok {
+				changes = append(changes, memberTransition{Time: now, Node: node, From: state, To: "Removed"})
+			}
+		}
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Node < changes[j].Node })
+		transitions := append(h.transitions[name], changes...)
+		if len(transitions) > maxTransitions {
+			transitions = transitions[len(transitions)-maxTransitions:]
+		}
+		h.transitions[name] = transitions
+	}
+	h.seen[name] = current
+	return append([]memberTransition{}, h.transitions[name]...)
+}
+
+// forget drops the history of a cluster that is gone.
+func (h *memberHistory) forget(name types.NamespacedName) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.seen, name)
+	delete(h.transitions, name)
+}
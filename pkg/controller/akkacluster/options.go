@@ -0,0 +1,46 @@
+package akkacluster
+
+import (
+	rbac "k8s.io/api/rbac/v1"
+
+	"github.com/lightbend/akka-cluster-operator/pkg/config"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
+)
+
+// Options configure an AkkaCluster controller. They are passed to Add rather than set on
+// the package, so each controller, like each test, has its own.
+type Options struct {
+	// ReadAPIAddress is the address the read API is served on, like ":8080". Empty leaves
+	// the read API off.
+	ReadAPIAddress string
+	// ClusterRBAC lets AkkaClusters have cluster-scoped RBAC generated.
+	ClusterRBAC bool
+	// Deployment is what generated Deployments get when an AkkaCluster leaves it out.
+	Deployment config.DeploymentDefaults
+	// GrantableRules cover the rules that spec.rbac may ask for.
+	GrantableRules []rbac.PolicyRule
+	// StatusPolling is the polling policy of clusters that don't set spec.statusPolling.
+	StatusPolling StatusPolling
+	// Management finds the Akka Management endpoint of pods.
+	Management management.Ports
+}
+
+// OptionsFor returns the Options set by an operator configuration. ReadAPIAddress and
+// ClusterRBAC are operator flags, so they are left for the caller to set.
+func OptionsFor(c *config.OperatorConfig) Options {
+	options := Options{
+		Deployment:     c.Deployment,
+		GrantableRules: c.RBAC.GrantableRules,
+		Management:     management.Ports{Name: c.Management.PortName, Fallback: c.Management.Port},
+	}
+	override(&options.StatusPolling.QuiesceWindow, c.StatusPolling.QuiesceWindow)
+	override(&options.StatusPolling.MaxBackoff, c.StatusPolling.MaxBackoff)
+	override(&options.StatusPolling.Timeout, c.StatusPolling.Timeout)
+	override(&options.StatusPolling.SteadyInterval, c.StatusPolling.SteadyInterval)
+	return options
+}
+
+// DefaultOptions returns the Options of an operator without a configuration file or flags.
+func DefaultOptions() Options {
+	return OptionsFor(config.Default())
+}
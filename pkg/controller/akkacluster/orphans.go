@@ -0,0 +1,96 @@
+package akkacluster
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// deleteUnwanted deletes resources controlled by the AkkaCluster that are no longer among
+// the wanted resources, like the default ServiceAccount, Role and RoleBinding once the
+// AkkaCluster names its own ServiceAccount. Garbage collection only takes owned resources
+// when the owner goes away, so without this they would stay around for good. Ownership is
+// what tracks them: every kind the operator might generate is listed, and children
+// controlled by this AkkaCluster but not wanted are deleted. Cluster-scoped children are
+// found by their owner labels instead.
+func (r *ReconcileAkkaCluster) deleteUnwanted(akkaCluster *appv1alpha1.AkkaCluster, wantedResources []GenericResource) error {
+	wanted := make(map[string]bool)
+	for _, resource := range wantedResources {
+		gvk, err := apiutil.GVKForObject(resource, r.scheme)
+		if err != nil {
+			return err
+		}
+		wanted[gvk.Kind+"/"+resource.GetName()] = true
+	}
+
+	type generated struct {
+		resourceType GenericResource
+		listOption   client.ListOption
+	}
+	all := []generated{}
+	for _, resourceType := range allPossibleGeneratedResourceTypes() {
+		all = append(all, generated{resourceType, client.InNamespace(akkaCluster.Namespace)})
+	}
+	if r.options.ClusterRBAC {
+		for _, resourceType := range clusterScopedGeneratedResourceTypes() {
+			all = append(all, generated{resourceType, client.MatchingLabels(ownerLabels(akkaCluster))})
+		}
+	}
+
+	errs := []error{}
+	for _, g := range all {
+		gvk, err := apiutil.GVKForObject(g.resourceType, r.scheme)
+		if err != nil {
+			return err
+		}
+		items, err := r.listGenerated(g.resourceType, g.listOption)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, item := range items {
+			child, err := meta.Accessor(item)
+			if err != nil {
+				return err
+			}
+			if wanted[gvk.Kind+"/"+child.GetName()] || child.GetDeletionTimestamp() != nil ||
+				!(metav1.IsControlledBy(child, akkaCluster) || isClusterScoped(child)) {
+				continue
+			}
+			if err := r.client.Delete(context.TODO(), item); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			log.Info("deleted unwanted resource", "kind", gvk.Kind, "name", child.GetName())
+			if r.recorder != nil {
+				r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "UnwantedResourceDeleted",
+					"deleted %s %s, which is no longer generated", gvk.Kind, child.GetName())
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// listGenerated lists resources of the type of resourceType.
+func (r *ReconcileAkkaCluster) listGenerated(resourceType GenericResource, opts ...client.ListOption) ([]runtime.Object, error) {
+	gvk, err := apiutil.GVKForObject(resourceType, r.scheme)
+	if err != nil {
+		return nil, err
+	}
+	list, err := r.scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.client.List(context.TODO(), list, opts...); err != nil {
+		return nil, err
+	}
+	return meta.ExtractList(list)
+}
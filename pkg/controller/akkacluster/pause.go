@@ -0,0 +1,20 @@
+package akkacluster
+
+import (
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// isPaused is true if the AkkaCluster asks for generated resources to be left alone.
+func isPaused(akkaCluster *appv1alpha1.AkkaCluster) bool {
+	return akkaCluster.Annotations[appv1alpha1.PausedAnnotation] == "true"
+}
+
+// reportPaused sets the Paused condition on status.
+func (r *ReconcileAkkaCluster) reportPaused(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus) *appv1alpha1.AkkaClusterStatus {
+	if isPaused(akkaCluster) {
+		return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterPaused, false, "Paused",
+			"generated resources are not reconciled while "+appv1alpha1.PausedAnnotation+" is true")
+	}
+	return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterPaused, true, "Resumed",
+		"generated resources are reconciled")
+}
@@ -0,0 +1,62 @@
+package akkacluster
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// StatusPolling is how the StatusActor polls the status of a cluster. It is the resolved
+// form of AkkaClusterStatusPollingSpec.
+type StatusPolling struct {
+	// QuiesceWindow is how long StartPolling requests must settle before a poll, and the
+	// first step of the doubling backoff between polls that find no change.
+	QuiesceWindow time.Duration
+	// MaxBackoff is the longest backoff. Past it, polling stops until the next request.
+	MaxBackoff time.Duration
+	// Timeout bounds each read of a management endpoint.
+	Timeout time.Duration
+	// SteadyInterval, if positive, keeps polling at this interval past MaxBackoff instead
+	// of stopping.
+	SteadyInterval time.Duration
+}
+
+// defaultStatusPolling is the polling policy of an operator without a configuration file,
+// for anything neither a cluster nor the operator sets.
+var defaultStatusPolling = DefaultOptions().StatusPolling
+
+// pollingFor resolves the polling policy of a cluster: its spec, then the actor's policy,
+// then defaultStatusPolling for anything still unset. Autoscaled clusters always poll
+// steadily.
+func (a *StatusActor) pollingFor(cluster *appv1alpha1.AkkaCluster) StatusPolling {
+	policy := a.polling
+	if spec := cluster.Spec.StatusPolling; spec != nil {
+		override(&policy.QuiesceWindow, spec.QuiesceWindow)
+		override(&policy.MaxBackoff, spec.MaxBackoff)
+		override(&policy.Timeout, spec.Timeout)
+		override(&policy.SteadyInterval, spec.SteadyInterval)
+	}
+	if policy.QuiesceWindow <= 0 {
+		policy.QuiesceWindow = defaultStatusPolling.QuiesceWindow
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultStatusPolling.MaxBackoff
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = defaultStatusPolling.Timeout
+	}
+	if cluster.Spec.Autoscaling != nil && policy.SteadyInterval <= 0 {
+		// the autoscaler needs fresh load, so polling never stops
+		policy.SteadyInterval = autoscalingPollInterval
+	}
+	return policy
+}
+
+// override sets d to a positive duration from the spec.
+func override(d *time.Duration, spec *metav1.Duration) {
+	if spec != nil && spec.Duration > 0 {
+		*d = spec.Duration
+	}
+}
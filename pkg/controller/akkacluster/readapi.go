@@ -0,0 +1,316 @@
+package akkacluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
+)
+
+// On the read API:
+//
+// Dashboards that want the health of every Akka cluster in one place can read it from the
+// operator rather than from each AkkaCluster and each management endpoint. When
+// Options.ReadAPIAddress is set, the manager serves a read-only HTTP/JSON API:
+//
+//   GET /api/v1/akkaclusters                    every AkkaCluster the operator watches
+//   GET /api/v1/akkaclusters/<namespace>/<name> one AkkaCluster
+//   GET /api/v1/watch                           a Server-Sent Events stream of changes
+//
+// Each cluster is summarized with the status held by the StatusActor, which is fresher
+// than the stored status, and with its members mapped to pods. Everything is read from the
+// manager's cache and the StatusActor, so requests never reach a management endpoint. The
+// watch stream starts with an update event for every cluster, then sends one whenever a
+// cluster's status may have changed, and a delete event when a cluster is gone. The same
+// server has a web UI at / that draws the clusters from the watch stream.
+//
+// The StatusActor only polls on the leader, so the read API runs with it and a standby
+// doesn't listen at all. A readiness probe on the read API port keeps a Service on the
+// leader.
+
+// readAPIKeepAlive is how often an idle watch stream sends a comment, so proxies keep it open.
+const readAPIKeepAlive = 30 * time.Second
+
+// clusterSummary is the read API view of one AkkaCluster.
+type clusterSummary struct {
+	Namespace string                         `json:"namespace"`
+	Name      string                         `json:"name"`
+	Replicas  *int32                         `json:"replicas,omitempty"`
+	Status    *appv1alpha1.AkkaClusterStatus `json:"status,omitempty"`
+	Members   []memberSummary                `json:"members"`
+	// Transitions are the recent changes of members, as seen by this operator.
+	Transitions []memberTransition `json:"transitions"`
+}
+
+// memberSummary is an Akka cluster member and the pod it runs in, if known.
+type memberSummary struct {
+	Node      string   `json:"node"`
+	Status    string   `json:"status"`
+	Roles     []string `json:"roles"`
+	Reachable bool     `json:"reachable"`
+	// ObservedBy lists the members that find an unreachable member unreachable.
+	ObservedBy []string `json:"observedBy,omitempty"`
+	Pod        string   `json:"pod,omitempty"`
+}
+
+// readAPI serves the read API as a manager Runnable.
+type readAPI struct {
+	addr    string
+	reader  client.Reader
+	actor   *StatusActor
+	history *memberHistory
+	// selectorKey is the pod label of clusters without a selector, see defaultSelector.
+	selectorKey string
+}
+
+func newReadAPI(addr string, reader client.Reader, actor *StatusActor, selectorKey string) *readAPI {
+	return &readAPI{addr: addr, reader: reader, actor: actor, history: newMemberHistory(), selectorKey: selectorKey}
+}
+
+// Start serves the read API until stop is closed.
+func (a *readAPI) Start(stop <-chan struct{}) error {
+	server := &http.Server{Addr: a.addr, Handler: a.handler()}
+	go func() {
+		<-stop
+		server.Shutdown(context.Background())
+	}()
+	go a.follow(stop)
+	log.Info("serving read API", "address", a.addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (a *readAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/akkaclusters", a.list)
+	mux.HandleFunc("/api/v1/akkaclusters/", a.get)
+	mux.HandleFunc("/api/v1/watch", a.watch)
+	mux.HandleFunc("/", serveUI)
+	return mux
+}
+
+// follow summarizes clusters as their status changes, so member history is kept even while
+// no one watches.
+func (a *readAPI) follow(stop <-chan struct{}) {
+	updates, cancel := a.actor.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-stop:
+			return
+		case name, ok := <-updates:
+			if !ok {
+				return
+			}
+			if _, err := a.summary(context.TODO(), name.NamespacedName); errors.IsNotFound(err) {
+				a.history.forget(name.NamespacedName)
+			}
+		}
+	}
+}
+
+func (a *readAPI) list(w http.ResponseWriter, req *http.Request) {
+	if !readOnly(w, req) {
+		return
+	}
+	summaries, err := a.summaries(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, summaries)
+}
+
+func (a *readAPI) get(w http.ResponseWriter, req *http.Request) {
+	if !readOnly(w, req) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/api/v1/akkaclusters/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, req)
+		return
+	}
+	summary, err := a.summary(req.Context(), types.NamespacedName{Namespace: parts[0], Name: parts[1]})
+	if errors.IsNotFound(err) {
+		http.NotFound(w, req)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, summary)
+}
+
+func (a *readAPI) watch(w http.ResponseWriter, req *http.Request) {
+	if !readOnly(w, req) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	// subscribe first, so no change falls between the initial list and the stream
+	updates, cancel := a.actor.Subscribe()
+	defer cancel()
+	summaries, err := a.summaries(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for i := range summaries {
+		writeEvent(w, "update", summaries[i])
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(readAPIKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case name, ok := <-updates:
+			if !ok {
+				return
+			}
+			summary, err := a.summary(req.Context(), name.NamespacedName)
+			switch {
+			case errors.IsNotFound(err):
+				a.history.forget(name.NamespacedName)
+				writeEvent(w, "delete", clusterSummary{Namespace: name.Namespace, Name: name.Name})
+			case err != nil:
+				log.Info("read API could not summarize cluster", "name", name.String(), "err", err)
+				continue
+			default:
+				writeEvent(w, "update", summary)
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// summaries lists every AkkaCluster in the cache.
+func (a *readAPI) summaries(ctx context.Context) ([]clusterSummary, error) {
+	list := &appv1alpha1.AkkaClusterList{}
+	if err := a.reader.List(ctx, list); err != nil {
+		return nil, err
+	}
+	summaries := []clusterSummary{}
+	for i := range list.Items {
+		summary, err := a.summarize(ctx, &list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, *summary)
+	}
+	return summaries, nil
+}
+
+// summary reads one AkkaCluster from the cache.
+func (a *readAPI) summary(ctx context.Context, name types.NamespacedName) (*clusterSummary, error) {
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	if err := a.reader.Get(ctx, name, akkaCluster); err != nil {
+		return nil, err
+	}
+	return a.summarize(ctx, akkaCluster)
+}
+
+// summarize takes status from the StatusActor, and maps members to the cached pods of the
+// AkkaCluster by pod IP.
+func (a *readAPI) summarize(ctx context.Context, akkaCluster *appv1alpha1.AkkaCluster) (*clusterSummary, error) {
+	status := a.actor.statusOf(getReq(akkaCluster), akkaCluster.Status)
+	summary := &clusterSummary{
+		Namespace:   akkaCluster.Namespace,
+		Name:        akkaCluster.Name,
+		Replicas:    akkaCluster.Spec.Replicas,
+		Status:      status,
+		Members:     []memberSummary{},
+		Transitions: []memberTransition{},
+	}
+	if status == nil {
+		return summary, nil
+	}
+
+	// the selector as generateResources defaults it
+	selector := akkaCluster.Spec.Selector
+	if selector == nil {
+		selector = defaultSelector(akkaCluster, a.selectorKey)
+	}
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	pods := &corev1.PodList{}
+	if err := a.reader.List(ctx, pods, client.InNamespace(akkaCluster.Namespace),
+		client.MatchingLabelsSelector{Selector: labelSelector}); err != nil {
+		return nil, err
+	}
+	podNames := make(map[string]string)
+	for _, pod := range pods.Items {
+		if pod.Status.PodIP != "" {
+			podNames[pod.Status.PodIP] = pod.Name
+		}
+	}
+	observers := make(map[string][]string)
+	for _, member := range status.Cluster.Unreachable {
+		observers[member.Node] = append(observers[member.Node], member.ObservedBy...)
+	}
+	for _, member := range status.Cluster.Members {
+		_, unreachable := observers[member.Node]
+		summary.Members = append(summary.Members, memberSummary{
+			Node:       member.Node,
+			Status:     member.Status,
+			Roles:      member.Roles,
+			Reachable:  !unreachable,
+			ObservedBy: observers[member.Node],
+			Pod:        podNames[management.NodeHost(member.Node)],
+		})
+	}
+	summary.Transitions = a.history.observe(types.NamespacedName{Namespace: akkaCluster.Namespace, Name: akkaCluster.Name}, summary.Members)
+	return summary, nil
+}
+
+// readOnly answers anything but GET with 405, returning false.
+func readOnly(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method == http.MethodGet {
+		return true
+	}
+	w.Header().Set("Allow", http.MethodGet)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Info("read API could not write response", "err", err)
+	}
+}
+
+// writeEvent writes a Server-Sent Event with value as its JSON data.
+func writeEvent(w http.ResponseWriter, name string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Info("read API could not encode event", "err", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+}
@@ -0,0 +1,171 @@
+package akkacluster
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+func TestReadAPI(t *testing.T) {
+	akkaCluster := &appv1alpha1.AkkaCluster{}
+	akkaCluster.Name = "akka-cluster-test"
+	akkaCluster.Namespace = "akka-cluster-namespace"
+	akkaCluster.Status = &appv1alpha1.AkkaClusterStatus{}
+	setCondition(akkaCluster.Status, appv1alpha1.AkkaClusterPaused, corev1.ConditionTrue, "Annotated", "paused")
+	pod := generatePod("10.0.0.1")
+	pod.Name = "akka-cluster-test-abc"
+	pod.Namespace = akkaCluster.Namespace
+	pod.Labels = map[string]string{"app": akkaCluster.Name}
+
+	scheme := scheme.Scheme
+	scheme.AddKnownTypes(appv1alpha1.SchemeGroupVersion, akkaCluster, &appv1alpha1.AkkaClusterList{})
+	client := fake.NewFakeClientWithScheme(scheme, akkaCluster, pod)
+
+	// the StatusActor holds fresher status than the stored one
+	held := akkaCluster.DeepCopy()
+	held.Status = &appv1alpha1.AkkaClusterStatus{}
+	held.Status.Cluster.Members = []appv1alpha1.AkkaClusterMemberStatus{
+		{Node: generateNodeAddress("10.0.0.1"), Status: "Up"},
+		{Node: generateNodeAddress("10.0.0.2"), Status: "Joining"},
+	}
+	held.Status.Cluster.Unreachable = []appv1alpha1.AkkaClusterUnreachableMemberStatus{
+		{Node: generateNodeAddress("10.0.0.2")},
+	}
+	actor := &StatusActor{
+		inbox:    make(chan func(), 100),
+		done:     make(chan struct{}),
+		statuses: map[reconcile.Request]*appv1alpha1.AkkaClusterStatus{getReq(akkaCluster): held.Status},
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go actor.Start(stop)
+
+	server := httptest.NewServer(newReadAPI("", client, actor, DefaultOptions().Deployment.SelectorKey).handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v1/akkaclusters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summaries := []clusterSummary{}
+	json.NewDecoder(resp.Body).Decode(&summaries)
+	resp.Body.Close()
+	if len(summaries) != 1 || len(summaries[0].Members) != 2 {
+		t.Fatalf("expected one cluster with two members, but got %+v", summaries)
+	}
+	members := summaries[0].Members
+	if members[0].Pod != pod.Name || !members[0].Reachable || members[1].Pod != "" || members[1].Reachable {
+		t.Errorf("expected members mapped to pods and reachability, but got %+v", members)
+	}
+	if !isConditionTrue(summaries[0].Status, appv1alpha1.AkkaClusterPaused) {
+		t.Errorf("expected stored Paused condition to carry over, but got %+v", summaries[0].Status.Conditions)
+	}
+
+	for path, code := range map[string]int{
+		"/api/v1/akkaclusters/akka-cluster-namespace/akka-cluster-test": http.StatusOK,
+		"/api/v1/akkaclusters/akka-cluster-namespace/missing":           http.StatusNotFound,
+		"/":        http.StatusOK,
+		"/missing": http.StatusNotFound,
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Errorf("expected %s to answer %d, but got %d", path, code, resp.StatusCode)
+		}
+	}
+	resp, _ = http.Post(server.URL+"/api/v1/akkaclusters", "application/json", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected read only API, but POST got %d", resp.StatusCode)
+	}
+
+	// the watch stream starts with every cluster, then follows changes
+	resp, err = http.Get(server.URL + "/api/v1/watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := make(chan string)
+	go func() {
+		lines := bufio.NewScanner(resp.Body)
+		for lines.Scan() {
+			if strings.HasPrefix(lines.Text(), "event: ") {
+				events <- strings.TrimPrefix(lines.Text(), "event: ")
+			}
+		}
+		close(events)
+	}()
+	next := func() string {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			return "timeout"
+		}
+	}
+	if e := next(); e != "update" {
+		t.Errorf("expected initial update, but got %s", e)
+	}
+	actor.NotifySubscribers(getReq(akkaCluster))
+	if e := next(); e != "update" {
+		t.Errorf("expected update on notification, but got %s", e)
+	}
+	client.Delete(context.TODO(), akkaCluster)
+	actor.NotifySubscribers(getReq(akkaCluster))
+	if e := next(); e != "delete" {
+		t.Errorf("expected delete once cluster is gone, but got %s", e)
+	}
+}
+
+func TestMemberHistory(t *testing.T) {
+	name := types.NamespacedName{Namespace: "ns", Name: "cluster"}
+	history := newMemberHistory()
+	members := []memberSummary{
+		{Node: "a", Status: "Up", Reachable: true},
+		{Node: "b", Status: "Joining", Reachable: true},
+	}
+	if transitions := history.observe(name, members); len(transitions) != 0 {
+		t.Errorf("expected first sighting to be a baseline, but got %+v", transitions)
+	}
+	members = []memberSummary{
+		{Node: "b", Status: "Up", Reachable: false},
+		{Node: "c", Status: "Joining", Reachable: true},
+	}
+	transitions := history.observe(name, members)
+	expected := []string{"a: Up -> Removed", "b: Joining -> Up (unreachable)", "c:  -> Joining"}
+	if len(transitions) != len(expected) {
+		t.Fatalf("expected %d transitions, but got %+v", len(expected), transitions)
+	}
+	for i, transition := range transitions {
+		if got := transition.Node + ": " + transition.From + " -> " + transition.To; got != expected[i] {
+			t.Errorf("expected transition %q, but got %q", expected[i], got)
+		}
+	}
+	for i := 0; i < maxTransitions; i++ {
+		members[1].Reachable = !members[1].Reachable
+		history.observe(name, members)
+	}
+	if transitions := history.observe(name, members); len(transitions) != maxTransitions {
+		t.Errorf("expected transitions capped at %d, but got %d", maxTransitions, len(transitions))
+	}
+	history.forget(name)
+	if transitions := history.observe(name, members); len(transitions) != 0 {
+		t.Errorf("expected forgotten cluster to start over, but got %+v", transitions)
+	}
+}
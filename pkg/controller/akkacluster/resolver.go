@@ -0,0 +1,179 @@
+package akkacluster
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+)
+
+// On the split brain resolver:
+//
+// Split brain detection only reports that the pods of an AkkaCluster formed more than one
+// Akka cluster. Deciding which side of a partition survives is up to the Akka split brain
+// resolver in the pods. Its lease-majority strategy keeps the side that acquires a Lease,
+// a leases.akka.io object of Akka Coordination, which the pods create and update through
+// the API server. With spec.splitBrain.resolver set, the generated Role grants that, the
+// JVM of each container is told to use the strategy, the Lease CRD is checked for, and
+// status reports the member holding the Lease.
+
+// javaToolOptionsEnv is read by any JVM, whatever the image runs.
+const javaToolOptionsEnv = "JAVA_TOOL_OPTIONS"
+
+// leaseMajorityOptions set up the Akka split brain resolver with the lease-majority
+// strategy and a Kubernetes Lease, named by the leaseNameOption.
+var leaseMajorityOptions = []string{
+	"-Dakka.cluster.downing-provider-class=akka.cluster.sbr.SplitBrainResolverProvider",
+	"-Dakka.cluster.split-brain-resolver.active-strategy=lease-majority",
+	"-Dakka.cluster.split-brain-resolver.lease-majority.lease-implementation=akka.coordination.lease.kubernetes",
+}
+
+// leaseNameOption names the Lease, so the pods use the one status is read from.
+const leaseNameOption = "-Dakka.cluster.split-brain-resolver.lease-majority.lease-name="
+
+// leaseGroupVersionKind is the Lease of Akka Coordination.
+var leaseGroupVersionKind = schema.GroupVersionKind{Group: "akka.io", Version: "v1", Kind: "Lease"}
+
+// leaseRetryInterval is how often a missing Lease CRD is checked for again. Nothing
+// watches CRDs, so no event fires when it is installed.
+const leaseRetryInterval = time.Minute
+
+// leaseMajority returns the resolver of an AkkaCluster using the lease-majority strategy,
+// or nil.
+func leaseMajority(akkaCluster *appv1alpha1.AkkaCluster) *appv1alpha1.AkkaClusterSplitBrainResolverSpec {
+	spec := akkaCluster.Spec.SplitBrain
+	if spec == nil || spec.Resolver == nil || spec.Resolver.Strategy != appv1alpha1.LeaseMajority {
+		return nil
+	}
+	return spec.Resolver
+}
+
+// leaseName is the name of the Lease of a lease-majority resolver.
+func leaseName(akkaCluster *appv1alpha1.AkkaCluster) string {
+	if resolver := leaseMajority(akkaCluster); resolver != nil && resolver.LeaseName != "" {
+		return resolver.LeaseName
+	}
+	return akkaCluster.Name + "-akka-sbr"
+}
+
+// leaseRule lets the pods of a lease-majority resolver create and renew their Lease.
+func leaseRule() rbac.PolicyRule {
+	return rbac.PolicyRule{
+		APIGroups: []string{leaseGroupVersionKind.Group},
+		Resources: []string{"leases"},
+		Verbs:     []string{"get", "create", "update", "list"},
+	}
+}
+
+// addResolverOptions tells the JVM of a container to use the lease-majority strategy with
+// the named Lease. A container that picks its own strategy in JAVA_TOOL_OPTIONS, or takes
+// them from elsewhere, is left alone; see optionsFromElsewhere.
+func addResolverOptions(container *corev1.Container, leaseName string) {
+	options := strings.Join(append(leaseMajorityOptions, leaseNameOption+leaseName), " ")
+	for i := range container.Env {
+		env := &container.Env[i]
+		if env.Name != javaToolOptionsEnv {
+			continue
+		}
+		if env.ValueFrom == nil && !strings.Contains(env.Value, "akka.cluster.split-brain-resolver.active-strategy") {
+			env.Value = strings.TrimSpace(env.Value + " " + options)
+		}
+		return
+	}
+	container.Env = append(container.Env, corev1.EnvVar{Name: javaToolOptionsEnv, Value: options})
+}
+
+// optionsFromElsewhere names the containers of a lease-majority AkkaCluster that take
+// JAVA_TOOL_OPTIONS from a ConfigMap or Secret, which the resolver options can't be
+// added to.
+func optionsFromElsewhere(akkaCluster *appv1alpha1.AkkaCluster) []string {
+	if leaseMajority(akkaCluster) == nil {
+		return nil
+	}
+	names := []string{}
+	for _, container := range akkaCluster.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == javaToolOptionsEnv && env.ValueFrom != nil {
+				names = append(names, container.Name)
+			}
+		}
+	}
+	return names
+}
+
+// leaseAvailable is false if the AkkaCluster uses a lease-majority resolver but the Lease
+// CRD is not installed. It is true when discovery fails, along with the error.
+func (r *ReconcileAkkaCluster) leaseAvailable(akkaCluster *appv1alpha1.AkkaCluster) (bool, error) {
+	if leaseMajority(akkaCluster) == nil || r.mapper == nil {
+		return true, nil
+	}
+	_, err := r.mapper.RESTMapping(leaseGroupVersionKind.GroupKind(), leaseGroupVersionKind.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return true, err
+}
+
+// reportLease sets the LeaseUnavailable condition on status, for a missing Lease CRD or
+// containers whose options are taken from elsewhere.
+func (r *ReconcileAkkaCluster) reportLease(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus,
+	available bool, unset []string) *appv1alpha1.AkkaClusterStatus {
+
+	if !available {
+		return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterLeaseUnavailable, false, "CRDNotFound",
+			"the split brain resolver needs a Lease, but the leases.akka.io CRD is not installed")
+	}
+	if len(unset) > 0 {
+		return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterLeaseUnavailable, false, "OptionsNotSet",
+			"the split brain resolver is not set up in containers "+strings.Join(unset, ", ")+
+				", as they take "+javaToolOptionsEnv+" from a ConfigMap or Secret")
+	}
+	return r.reportCondition(akkaCluster, status, appv1alpha1.AkkaClusterLeaseUnavailable, true, "CRDFound",
+		"the leases.akka.io CRD is installed")
+}
+
+// Given an AkkaCluster with a lease-majority resolver, return the holder of its Lease.
+type leaseReader interface {
+	LeaseHolder(*appv1alpha1.AkkaCluster) (string, error)
+}
+
+// controllerLeaseReader is a leaseReader with a controller client. The Lease CRD is not
+// part of the operator's scheme, so Leases are read unstructured, straight from the API.
+type controllerLeaseReader struct {
+	client.Client
+}
+
+func (l *controllerLeaseReader) LeaseHolder(cluster *appv1alpha1.AkkaCluster) (string, error) {
+	lease := &unstructured.Unstructured{}
+	lease.SetGroupVersionKind(leaseGroupVersionKind)
+	key := types.NamespacedName{Namespace: cluster.Namespace, Name: leaseName(cluster)}
+	if err := l.Get(context.TODO(), key, lease); err != nil {
+		return "", err
+	}
+	holder, _, err := unstructured.NestedString(lease.Object, "spec", "owner")
+	return holder, err
+}
+
+// readLeaseHolder sets the holder of the Lease of a lease-majority resolver on status.
+// Status keeps the last holder read if the Lease can't be read.
+func (a *StatusActor) readLeaseHolder(cluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus) {
+	if leaseMajority(cluster) == nil {
+		status.LeaseHolder = ""
+		return
+	}
+	holder, err := a.leases.LeaseHolder(cluster)
+	if err != nil {
+		log.Info("StatusActor could not read Lease", "name", cluster.Namespace+"/"+leaseName(cluster), "err", err)
+		return
+	}
+	status.LeaseHolder = holder
+}
@@ -0,0 +1,302 @@
+package akkacluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
+)
+
+// On split brain detection:
+//
+// Status normally comes from a single member, preferably the leader. If a network
+// partition or a bootstrap race leaves the pods of one AkkaCluster in two independent Akka
+// clusters, each with its own leader, that single view looks perfectly healthy. With
+// spec.splitBrain.probes set to two or more, the StatusActor also asks that many running
+// members for their view, and compares them. Members of one Akka cluster agree on the
+// leader, give or take gossip convergence, while members of disjoint clusters don't even
+// know about each other's leader.
+//
+// With spec.splitBrain.remediate set, the controller goes one step further once a split
+// brain has lasted for the grace period: it deletes the pods of the minority island, which
+// restart and bootstrap into the surviving cluster. This is what one would otherwise do by
+// hand, and is opt-in since deleting pods discards whatever state the island had.
+
+// defaultSplitBrainGracePeriod is how long a split brain may last before remediation.
+const defaultSplitBrainGracePeriod = 60 * time.Second
+
+// probeMembers asks up to n running pods, in parallel, for their view of the cluster.
+// Views are keyed by pod IP. Pods that can't be read within timeout are left out.
+func (a *StatusActor) probeMembers(cluster *appv1alpha1.AkkaCluster, n int, timeout time.Duration) map[string]*appv1alpha1.AkkaClusterManagementStatus {
+	pods := a.lister.ListPods(cluster)
+	running := []*corev1.Pod{}
+	for _, i := range rand.Perm(len(pods.Items)) {
+		if pod := &pods.Items[i]; management.IsRunning(pod) {
+			running = append(running, pod)
+		}
+	}
+	if len(running) > n {
+		running = running[:n]
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	views := make(map[string]*appv1alpha1.AkkaClusterManagementStatus)
+	for _, pod := range running {
+		wg.Add(1)
+		go func(host string, port int32) {
+			defer wg.Done()
+			body, err := management.ReadWithTimeout(a.reader, management.MembersURL(management.Endpoint(host, port)), timeout)
+			if err != nil {
+				log.Info("StatusActor could not probe member", "host", host, "err", err)
+				return
+			}
+			view := &appv1alpha1.AkkaClusterManagementStatus{}
+			if err := json.Unmarshal(body, view); err != nil {
+				return
+			}
+			mu.Lock()
+			views[host] = view
+			mu.Unlock()
+		}(pod.Status.PodIP, a.ports.Of(pod))
+	}
+	wg.Wait()
+	return views
+}
+
+// compareViews decides if views from several members describe more than one cluster. It
+// returns true and a description of the disagreement if so. Members disagree if they see
+// different leaders, or if one doesn't list the leader another member sees.
+func compareViews(views map[string]*appv1alpha1.AkkaClusterManagementStatus) (bool, string) {
+	if len(views) < 2 {
+		return false, ""
+	}
+	// group probed hosts by the leader they see, ignoring members that don't see one yet
+	leaders := make(map[string][]string)
+	for host, view := range views {
+		if view.Leader != "" {
+			leaders[view.Leader] = append(leaders[view.Leader], host)
+		}
+	}
+	split := len(leaders) > 1
+	for leader := range leaders {
+		for _, view := range views {
+			if !hasMember(view, leader) {
+				split = true
+			}
+		}
+	}
+	if !split {
+		return false, fmt.Sprintf("%d members agree on leader", len(views))
+	}
+
+	seen := []string{}
+	for leader, hosts := range leaders {
+		sort.Strings(hosts)
+		members := len(views[hosts[0]].Members)
+		seen = append(seen, fmt.Sprintf("%s seen by %s (%d members)", leader, strings.Join(hosts, ", "), members))
+	}
+	sort.Strings(seen)
+	return true, fmt.Sprintf("members disagree on cluster: %s", strings.Join(seen, "; "))
+}
+
+// findIslands groups views by the leader they see, listing the members each group can reach.
+// During a partition every side still lists the other side's nodes as unreachable, so those
+// are left out, as are nodes claimed by more than one island: they can't safely be removed.
+func findIslands(views map[string]*appv1alpha1.AkkaClusterManagementStatus) []appv1alpha1.AkkaClusterIsland {
+	members := make(map[string]map[string]bool)
+	claims := make(map[string]map[string]bool)
+	for _, view := range views {
+		if view.Leader == "" {
+			continue
+		}
+		if members[view.Leader] == nil {
+			members[view.Leader] = make(map[string]bool)
+		}
+		unreachable := make(map[string]bool)
+		for _, member := range view.Unreachable {
+			unreachable[member.Node] = true
+		}
+		for _, member := range view.Members {
+			if unreachable[member.Node] {
+				continue
+			}
+			members[view.Leader][member.Node] = true
+			if claims[member.Node] == nil {
+				claims[member.Node] = make(map[string]bool)
+			}
+			claims[member.Node][view.Leader] = true
+		}
+	}
+	islands := []appv1alpha1.AkkaClusterIsland{}
+	for leader, nodes := range members {
+		island := appv1alpha1.AkkaClusterIsland{Leader: leader, Members: []string{}}
+		for node := range nodes {
+			if len(claims[node]) > 1 {
+				continue
+			}
+			island.Members = append(island.Members, node)
+		}
+		sort.Strings(island.Members)
+		islands = append(islands, island)
+	}
+	sort.Slice(islands, func(i, j int) bool { return islands[i].Leader < islands[j].Leader })
+	return islands
+}
+
+// hasMember is true if node is listed in the members of view.
+func hasMember(view *appv1alpha1.AkkaClusterManagementStatus, node string) bool {
+	for _, member := range view.Members {
+		if member.Node == node {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSplitBrain probes several members when split brain detection is enabled, and sets
+// the SplitBrain condition on status from their combined views. Transitions are recorded
+// as Events on the AkkaCluster. Each probe is bounded by timeout.
+func (a *StatusActor) checkSplitBrain(cluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus, timeout time.Duration) {
+	if cluster.Spec.SplitBrain == nil || cluster.Spec.SplitBrain.Probes < 2 {
+		return
+	}
+	views := a.probeMembers(cluster, int(cluster.Spec.SplitBrain.Probes), timeout)
+	if len(views) < 2 {
+		// not enough views to tell either way, so leave things as they are
+		return
+	}
+	wasSplit := isConditionTrue(status, appv1alpha1.AkkaClusterSplitBrain)
+	split, message := compareViews(views)
+	if split {
+		status.Islands = findIslands(views)
+		setCondition(status, appv1alpha1.AkkaClusterSplitBrain, corev1.ConditionTrue, "MembersDisagree", message)
+		if !wasSplit && a.recorder != nil {
+			a.recorder.Event(cluster, corev1.EventTypeWarning, "SplitBrain", message)
+		}
+		return
+	}
+	status.Islands = nil
+	setCondition(status, appv1alpha1.AkkaClusterSplitBrain, corev1.ConditionFalse, "MembersAgree", message)
+	if wasSplit && a.recorder != nil {
+		a.recorder.Event(cluster, corev1.EventTypeNormal, "SplitBrainResolved", message)
+	}
+}
+
+// remediateSplitBrain deletes the pods of the minority island once a split brain has lasted
+// longer than the grace period, so that they restart and bootstrap into the main cluster.
+// Each step is recorded as an Event. It returns how long to wait before checking again,
+// or zero if there is nothing to wait for.
+func (r *ReconcileAkkaCluster) remediateSplitBrain(akkaCluster *appv1alpha1.AkkaCluster) (time.Duration, error) {
+	spec := akkaCluster.Spec.SplitBrain
+	if spec == nil || !spec.Remediate {
+		return 0, nil
+	}
+	condition := findCondition(akkaCluster.Status, appv1alpha1.AkkaClusterSplitBrain)
+	if condition == nil || condition.Status != corev1.ConditionTrue || len(akkaCluster.Status.Islands) < 2 {
+		return 0, nil
+	}
+	gracePeriod := defaultSplitBrainGracePeriod
+	if spec.GracePeriodSeconds != nil {
+		gracePeriod = time.Duration(*spec.GracePeriodSeconds) * time.Second
+	}
+	if elapsed := time.Since(condition.LastTransitionTime.Time); elapsed < gracePeriod {
+		return gracePeriod - elapsed, nil
+	}
+
+	pods := &corev1.PodList{}
+	err := r.client.List(context.TODO(), pods, &client.ListOptions{
+		Namespace:     akkaCluster.Namespace,
+		LabelSelector: labels.SelectorFromSet(akkaCluster.Spec.Selector.MatchLabels),
+	})
+	if err != nil {
+		return 0, err
+	}
+	island := minorityIsland(akkaCluster.Status.Islands, pods.Items)
+	hosts := onlyHosts(island, akkaCluster.Status.Islands)
+	if len(hosts) == 0 {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "SplitBrainRemediation",
+			"split brain lasted longer than %s, but no members belong only to the minority island led by %s",
+			gracePeriod, island.Leader)
+		return gracePeriod, nil
+	}
+	r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "SplitBrainRemediation",
+		"split brain lasted longer than %s, removing minority island led by %s with %d members",
+		gracePeriod, island.Leader, len(hosts))
+
+	errs := []error{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !hosts[pod.Status.PodIP] || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := r.client.Delete(context.TODO(), pod); err != nil {
+			r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "SplitBrainRemediation",
+				"failed to delete pod %s of minority island: %v", pod.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "SplitBrainRemediation",
+			"deleted pod %s of minority island so it can rejoin the main cluster", pod.Name)
+	}
+	return gracePeriod, utilerrors.NewAggregate(errs)
+}
+
+// onlyHosts returns the hosts of the members of island that no other island also lists, so
+// that a pod is only removed when it belongs to the minority alone.
+func onlyHosts(island appv1alpha1.AkkaClusterIsland, islands []appv1alpha1.AkkaClusterIsland) map[string]bool {
+	others := make(map[string]bool)
+	for _, other := range islands {
+		if other.Leader == island.Leader {
+			continue
+		}
+		for _, node := range other.Members {
+			others[management.NodeHost(node)] = true
+		}
+	}
+	hosts := make(map[string]bool)
+	for _, node := range island.Members {
+		if host := management.NodeHost(node); host != "" && !others[host] {
+			hosts[host] = true
+		}
+	}
+	return hosts
+}
+
+// minorityIsland picks the island to remove: the one with the fewest members, or on a tie
+// the one whose oldest pod is youngest, so the longest running cluster survives.
+func minorityIsland(islands []appv1alpha1.AkkaClusterIsland, pods []corev1.Pod) appv1alpha1.AkkaClusterIsland {
+	started := make(map[string]time.Time)
+	for _, pod := range pods {
+		started[pod.Status.PodIP] = pod.CreationTimestamp.Time
+	}
+	oldest := func(island appv1alpha1.AkkaClusterIsland) time.Time {
+		var t time.Time
+		for _, node := range island.Members {
+			if s, ok := started[management.NodeHost(node)]; ok && (t.IsZero() || s.Before(t)) {
+				t = s
+			}
+		}
+		return t
+	}
+	sorted := append([]appv1alpha1.AkkaClusterIsland{}, islands...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if len(sorted[i].Members) != len(sorted[j].Members) {
+			return len(sorted[i].Members) < len(sorted[j].Members)
+		}
+		return oldest(sorted[i]).After(oldest(sorted[j]))
+	})
+	return sorted[0]
+}
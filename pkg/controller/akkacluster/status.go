@@ -5,22 +5,26 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"net/url"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
 )
 
 //
@@ -49,32 +53,6 @@ import (
 // a replacement for polling. https://github.com/akka/akka-management/issues/540
 //
 
-// Given a URL, return the body of the response.
-type urlReader interface {
-	ReadURL(string) ([]byte, error)
-}
-
-// An httpReader is a urlReader with http.Client.
-type httpReader struct {
-	http.Client
-}
-
-func newHTTPReader() *httpReader {
-	return &httpReader{
-		http.Client{Timeout: 3 * time.Second},
-	}
-}
-
-func (r *httpReader) ReadURL(url string) ([]byte, error) {
-	resp, err := r.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
-	return body, err
-}
-
 // Given an AkkaCluster, return a list of pods.
 type podLister interface {
 	ListPods(*appv1alpha1.AkkaCluster) *corev1.PodList
@@ -98,44 +76,173 @@ func (p *controllerPodLister) ListPods(cluster *appv1alpha1.AkkaCluster) *corev1
 // StatusActor manages updating status for a set of Akka clusters. It is a worker for a
 // controller, responsible mainly for converting Akka cluster events into controller
 // reconciliation events. It also provides cluster status to the controller.
+//
+// The actor goroutine only keeps books. Reading management endpoints is done by a bounded
+// pool of workers, which send their results back through the inbox, so one cluster with a
+// dead endpoint holds up a worker for a while rather than every cluster. Reads wait for a
+// worker in a queue that holds each cluster at most once. Known status is published to a
+// snapshot that GetStatus reads under a lock, without waiting in the inbox.
+//
+// The StatusActor is a manager Runnable, so it only runs on the elected leader. When the
+// manager stops, it stops its timers and workers, and messages sent to it are dropped.
 type StatusActor struct {
 	// inbound:
 	inbox chan func()
+	// done is closed once the actor has stopped.
+	done chan struct{}
 	// outbound:
 	statusChanged chan event.GenericEvent
 	lister        podLister
-	reader        urlReader
+	leases        leaseReader
+	reader        management.Reader
+	recorder      record.EventRecorder
+	// workers is the number of management reads that may run at once, and busy how many
+	// are running.
+	workers int
+	busy    int
+	work    chan func()
+	// queue holds clusters waiting for a worker, in the order they asked.
+	queue []reconcile.Request
+	// polling is the policy for clusters that don't set their own, see pollingFor.
+	polling StatusPolling
+	// ports find the management endpoint of pods.
+	ports management.Ports
 	// state:
-	minimalWait time.Duration
 	polls       map[reconcile.Request]pollingRequest
+	subscribers map[chan reconcile.Request]bool
+	// published status, written in the actor's frame and read from any other.
+	mu       sync.RWMutex
+	statuses map[reconcile.Request]*appv1alpha1.AkkaClusterStatus
+	// reads is when status of each cluster was last read from a member.
+	reads map[reconcile.Request]time.Time
+	// heartbeat is when the actor loop was last free, in unix nanoseconds, zero until started.
+	heartbeat int64
 }
 
 type pollingRequest struct {
 	cluster    *appv1alpha1.AkkaCluster
 	waitFactor int
 	timer      *time.Timer
+	// generation counts StartPolling calls, so results for an older copy are dropped.
+	generation int
+	// inFlight is true while status is queued or read, queued while it waits for a
+	// worker, and pending asks for another read once it is done.
+	inFlight bool
+	queued   bool
+	pending  bool
 }
 
-// NewStatusActor constructs a new StatusActor given a Manager's api client and some
-// channel for status update events.
-func NewStatusActor(client client.Client, statusChanged chan event.GenericEvent) *StatusActor {
-	actor := &StatusActor{
-		inbox:         make(chan func(), 100),
+const (
+	// defaultStatusWorkers bounds concurrent management reads when none is configured.
+	defaultStatusWorkers = 8
+	// heartbeatInterval is how often an idle actor loop marks itself alive.
+	heartbeatInterval = 5 * time.Second
+	// livenessTimeout is how long the actor loop may be busy with one message before
+	// Healthz reports it wedged.
+	livenessTimeout = 30 * time.Second
+)
+
+// NewStatusActor constructs a new StatusActor given a Manager's api client, some channel
+// for status update events, a recorder for Events about cluster health, and the options of
+// the controller. It does nothing until started, normally by adding it to the manager.
+func NewStatusActor(client client.Client, statusChanged chan event.GenericEvent, recorder record.EventRecorder,
+	options Options) *StatusActor {
+
+	return &StatusActor{
+		inbox:         make(chan func(), 1024),
+		done:          make(chan struct{}),
 		statusChanged: statusChanged,
 		lister:        &controllerPodLister{client},
-		reader:        newHTTPReader(),
-		minimalWait:   time.Second,
+		leases:        &controllerLeaseReader{client},
+		reader:        newCircuitBreakerReader(management.NewHTTPClient(nil)),
+		recorder:      recorder,
+		workers:       defaultStatusWorkers,
+		polling:       options.StatusPolling,
+		ports:         options.Management,
 		polls:         make(map[reconcile.Request]pollingRequest),
 	}
-	go actor.Run()
-	return actor
 }
 
-// Run loops on inbox until it is closed.
-func (a *StatusActor) Run() {
-	for f := range a.inbox {
-		f()
+// Start runs the actor until stop is closed, as a manager Runnable.
+func (a *StatusActor) Start(stop <-chan struct{}) error {
+	if a.workers <= 0 {
+		a.workers = defaultStatusWorkers
+	}
+	// never more jobs than workers are handed out, so handing one out never waits
+	a.work = make(chan func(), a.workers)
+	for i := 0; i < a.workers; i++ {
+		go func(work <-chan func()) {
+			for {
+				select {
+				case job := <-work:
+					job()
+				case <-a.done:
+					return
+				}
+			}
+		}(a.work)
+	}
+	log.Info("StatusActor started", "workers", a.workers)
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	defer a.shutdown()
+	a.beat()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case f, ok := <-a.inbox:
+			if !ok {
+				return nil
+			}
+			f()
+		case <-ticker.C:
+		}
+		a.beat()
+	}
+}
+
+// shutdown stops polling timers and workers. It runs in the actor's frame, last.
+func (a *StatusActor) shutdown() {
+	for req, poll := range a.polls {
+		if poll.timer != nil {
+			poll.timer.Stop()
+		}
+		delete(a.polls, req)
+		a.publish(req, nil)
+	}
+	if a.done != nil {
+		close(a.done)
 	}
+	atomic.StoreInt64(&a.heartbeat, 0)
+	log.Info("StatusActor stopped")
+}
+
+// send puts f in the inbox, or drops it once the actor has stopped.
+func (a *StatusActor) send(f func()) {
+	select {
+	case a.inbox <- f:
+	case <-a.done:
+	}
+}
+
+// beat marks the actor loop as alive now.
+func (a *StatusActor) beat() {
+	atomic.StoreInt64(&a.heartbeat, time.Now().UnixNano())
+}
+
+// Healthz is a manager health check, failing if the actor loop has been stuck on one
+// message for longer than livenessTimeout. An actor that isn't running, as on a replica
+// that isn't the leader, is healthy.
+func (a *StatusActor) Healthz(_ *http.Request) error {
+	heartbeat := atomic.LoadInt64(&a.heartbeat)
+	if heartbeat == 0 {
+		return nil
+	}
+	if since := time.Since(time.Unix(0, heartbeat)); since > livenessTimeout {
+		return fmt.Errorf("StatusActor loop busy for %s", since.Round(time.Second))
+	}
+	return nil
 }
 
 // getReq assembles a NamespacedName from metadata of AkkaCluster.
@@ -155,115 +262,326 @@ func getReq(cluster *appv1alpha1.AkkaCluster) reconcile.Request {
 //
 // This work request is quiescing, which is a kind of work rate limiter. Practically this
 // means many outside callers can ask to start polling an arbitrary number of times, but
-// they'll cancel each other if within the minimal wait window (eg 1 second). This means
-// the first poll for status will only happen if there is a quiet window with only one
-// request in the last second. This fits the typical reconcile burst, which may send
+// they'll cancel each other if within the quiesce window (1 second by default, see
+// StatusPolling). This means the first poll for status will only happen if there is a
+// quiet window with only one request in the last window. This fits the typical reconcile burst, which may send
 // dozens of update requests per second, and here we wait until the burst seems to be
 // over. This would not work well if requests for polling were continuous to the point
 // where no quiet period happened.
 func (a *StatusActor) StartPolling(cluster *appv1alpha1.AkkaCluster) {
 	reqKey := getReq(cluster)
 	copy := cluster.DeepCopy()
-	isImmediate := make(chan bool)
 
-	a.inbox <- func() {
+	a.send(func() {
 		poll, ok := a.polls[reqKey]
 		immediateMode := true
 		if ok && poll.timer != nil {
 			poll.timer.Stop()
-			immediateMode = false // quiesce requests for minimalWait
+			immediateMode = false // quiesce requests for the quiesce window
 		}
 		msg := pollingRequest{
-			cluster: copy,
+			cluster:    copy,
+			generation: poll.generation + 1,
+			inFlight:   poll.inFlight,
+			queued:     poll.queued,
 		}
 		if !immediateMode {
-			// restart previous poll after minimalWait
-			msg.timer = time.AfterFunc(a.minimalWait, func() { a.update(reqKey) })
+			// restart previous poll after the quiesce window
+			msg.timer = time.AfterFunc(a.pollingFor(copy).QuiesceWindow, func() { a.update(reqKey) })
 		}
 		a.polls[reqKey] = msg
-		isImmediate <- immediateMode
-	}
-
-	if <-isImmediate {
-		a.update(reqKey)
-	}
+		a.publish(reqKey, copy.Status)
+		if immediateMode {
+			a.startUpdate(reqKey)
+		}
+	})
 }
 
 // GetStatus looks up last known status by name and namespace. A nil result means no known
 // last status, so caller may safely ignore nil results and hope for better next time.
 func (a *StatusActor) GetStatus(req reconcile.Request) *appv1alpha1.AkkaClusterStatus {
-	status := make(chan *appv1alpha1.AkkaClusterStatus)
-	a.inbox <- func() {
-		poll, ok := a.polls[req]
-		if !ok {
-			log.Info("StatusActor asked for missing status", "name", req.String())
-			status <- nil
-		} else {
-			status <- poll.cluster.Status.DeepCopy()
-		}
+	status := a.lookup(req)
+	if status == nil {
+		log.Info("StatusActor asked for missing status", "name", req.String())
+	}
+	return status
+}
+
+// ReadSince is true if status of a cluster was read from a member after since, rather
+// than only known from the AkkaCluster as stored.
+func (a *StatusActor) ReadSince(req reconcile.Request, since time.Time) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.reads[req].After(since)
+}
+
+// recordRead marks status of a cluster read from a member now.
+func (a *StatusActor) recordRead(req reconcile.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.reads == nil {
+		a.reads = make(map[reconcile.Request]time.Time)
+	}
+	a.reads[req] = time.Now()
+}
+
+// lookup is GetStatus without logging, for readers that often ask about idle clusters.
+func (a *StatusActor) lookup(req reconcile.Request) *appv1alpha1.AkkaClusterStatus {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.statuses[req].DeepCopy()
+}
+
+// publish makes status the known status of a cluster, or forgets it if nil. It runs in the
+// actor's frame.
+func (a *StatusActor) publish(req reconcile.Request, status *appv1alpha1.AkkaClusterStatus) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.statuses == nil {
+		a.statuses = make(map[reconcile.Request]*appv1alpha1.AkkaClusterStatus)
+	}
+	if status == nil {
+		delete(a.statuses, req)
+		return
 	}
-	return <-status
+	a.statuses[req] = status.DeepCopy()
+}
+
+// statusOf returns the last known status of a cluster from the StatusActor, with the
+// conditions and autoscaler records owned by Reconcile carried over from stored, the status
+// as stored on the AkkaCluster. Without a known status, it returns a copy of stored.
+func (a *StatusActor) statusOf(req reconcile.Request, stored *appv1alpha1.AkkaClusterStatus) *appv1alpha1.AkkaClusterStatus {
+	status := a.lookup(req)
+	if status == nil {
+		return stored.DeepCopy()
+	}
+	for _, conditionType := range reconcileConditions {
+		copyCondition(stored, status, conditionType)
+	}
+	status.Autoscaling = nil
+	if stored != nil {
+		status.Autoscaling = stored.Autoscaling.DeepCopy()
+	}
+	return status
 }
 
 // StopPolling stops timer and removes polling state for a given cluster. This is optional
 // since polling against a removed cluster will stop trying and remove itself eventually.
 func (a *StatusActor) StopPolling(req reconcile.Request) {
-	a.inbox <- func() {
+	a.send(func() {
 		poll, ok := a.polls[req]
 		if ok {
 			if poll.timer != nil {
 				poll.timer.Stop()
 			}
 			delete(a.polls, req)
+			a.publish(req, nil)
+		}
+		a.mu.Lock()
+		delete(a.reads, req)
+		a.mu.Unlock()
+	})
+}
+
+// Subscribe returns a channel that receives the name of any cluster whose status may have
+// changed, and a function to cancel the subscription. Notifications are dropped for a
+// subscriber that falls too far behind, so subscribers should read promptly.
+func (a *StatusActor) Subscribe() (<-chan reconcile.Request, func()) {
+	updates := make(chan reconcile.Request, 64)
+	a.send(func() {
+		if a.subscribers == nil {
+			a.subscribers = make(map[chan reconcile.Request]bool)
+		}
+		a.subscribers[updates] = true
+	})
+	cancel := func() {
+		a.send(func() {
+			if a.subscribers[updates] {
+				delete(a.subscribers, updates)
+				close(updates)
+			}
+		})
+	}
+	return updates, cancel
+}
+
+// NotifySubscribers tells subscribers that the status of a cluster may have changed,
+// as it does when Reconcile updates status or finds the cluster gone.
+func (a *StatusActor) NotifySubscribers(req reconcile.Request) {
+	a.send(func() {
+		a.notify(req)
+	})
+}
+
+// notify sends req to each subscriber with room for it. It runs in the actor's frame.
+func (a *StatusActor) notify(req reconcile.Request) {
+	for updates := range a.subscribers {
+		select {
+		case updates <- req:
+		default:
 		}
 	}
 }
 
+// signalChanged asks the controller to reconcile a cluster. It runs in the actor's frame,
+// so it never waits on a full statusChanged channel.
+func (a *StatusActor) signalChanged(cluster *appv1alpha1.AkkaCluster) {
+	changed := event.GenericEvent{Meta: cluster, Object: cluster}
+	select {
+	case a.statusChanged <- changed:
+	default:
+		go func() {
+			select {
+			case a.statusChanged <- changed:
+			case <-a.done:
+			}
+		}()
+	}
+}
+
 // update process
 // 1. try Leader, otherwise get random Pod IP and try that
-// 2. if status is different, save status and signal statusChanged
-// 3. otherwise double the wait time and retry up to some limit
+// 2. if split brain detection is on, ask other members for their view too
+// 3. if status is different, save status and signal statusChanged
+// 4. otherwise double the wait time and retry up to some limit, or at a steady interval
+//
+// Steps 1 and 2 are I/O, done by a worker on a copy of the cluster in fetch. Steps 3 and 4
+// are done back in the actor's frame by finishUpdate.
 func (a *StatusActor) update(req reconcile.Request) {
-	a.inbox <- func() {
+	a.send(func() {
+		a.startUpdate(req)
+	})
+}
+
+// startUpdate queues a read of cluster status for a worker. A cluster already queued is
+// read once, and one already being read is read again after. It runs in the actor's frame.
+func (a *StatusActor) startUpdate(req reconcile.Request) {
+	poll, ok := a.polls[req]
+	if !ok {
+		return
+	}
+	if poll.queued {
+		return
+	}
+	if poll.inFlight {
+		poll.pending = true
+		a.polls[req] = poll
+		return
+	}
+	poll.inFlight = true
+	poll.queued = true
+	poll.pending = false
+	a.polls[req] = poll
+	a.queue = append(a.queue, req)
+	a.dispatch()
+}
+
+// dispatch hands queued reads to idle workers, each read of the cluster as it is by then.
+// Clusters no longer polled are dropped from the queue. It runs in the actor's frame.
+func (a *StatusActor) dispatch() {
+	for a.busy < a.workers && len(a.queue) > 0 {
+		req := a.queue[0]
+		a.queue = a.queue[1:]
 		poll, ok := a.polls[req]
-		if !ok {
-			return
-		}
-		a.initStatus(poll.cluster)
-		currentStatus := a.fetchUpdate(poll.cluster)
-
-		if currentStatus == nil {
-			// write something so that initial status is not nil
-			poll.cluster.Status.LastUpdate = metav1.Now()
-			// start from scratch next time, maybe picking different pod
-			poll.cluster.Status.ManagementHost = ""
-		} else if !reflect.DeepEqual(currentStatus.Cluster, poll.cluster.Status.Cluster) {
-			// found a change: save it, signal upstream, stop polling
-			poll.cluster.Status = currentStatus
-			poll.cluster.Status.LastUpdate = metav1.Now()
-			poll.timer = nil
-			a.polls[req] = poll
-			a.statusChanged <- event.GenericEvent{
-				Meta:   poll.cluster,
-				Object: poll.cluster,
-			}
-			return
+		if !ok || !poll.queued {
+			continue
 		}
-		// poll again, up to some limit
-		if poll.waitFactor == 0 {
-			poll.waitFactor = 1
+		poll.queued = false
+		a.polls[req] = poll
+		generation, cluster := poll.generation, poll.cluster.DeepCopy()
+		timeout := a.pollingFor(cluster).Timeout
+		a.busy++
+		a.work <- func() { a.fetch(req, generation, cluster, timeout) }
+	}
+}
+
+// fetch reads status for a copy of a cluster, each read bounded by timeout, and sends the
+// result back to the actor. It runs in a worker.
+func (a *StatusActor) fetch(req reconcile.Request, generation int, cluster *appv1alpha1.AkkaCluster, timeout time.Duration) {
+	a.initStatus(cluster)
+	currentStatus := a.fetchUpdate(cluster, timeout)
+	if currentStatus != nil {
+		a.checkSplitBrain(cluster, currentStatus, timeout)
+		a.readLeaseHolder(cluster, currentStatus)
+		a.readLoad(cluster, currentStatus, timeout)
+	}
+	a.send(func() {
+		a.busy--
+		a.finishUpdate(req, generation, cluster, currentStatus)
+		a.dispatch()
+	})
+}
+
+// finishUpdate saves the result of a fetch, signalling a change or scheduling the next poll.
+// Results for a poll restarted since are dropped. It runs in the actor's frame.
+func (a *StatusActor) finishUpdate(req reconcile.Request, generation int, cluster *appv1alpha1.AkkaCluster,
+	currentStatus *appv1alpha1.AkkaClusterStatus) {
+
+	poll, ok := a.polls[req]
+	if !ok {
+		return
+	}
+	poll.inFlight = false
+	a.polls[req] = poll
+	if poll.generation != generation {
+		if poll.pending {
+			a.startUpdate(req)
 		}
-		poll.waitFactor *= 2
-		if poll.waitFactor > 60 {
+		return
+	}
+	poll.cluster = cluster
+	if currentStatus != nil {
+		a.recordRead(req)
+	}
+
+	if currentStatus == nil {
+		// write something so that initial status is not nil
+		poll.cluster.Status.LastUpdate = metav1.Now()
+		// start from scratch next time, maybe picking different pod
+		poll.cluster.Status.ManagementHost = ""
+	} else if !reflect.DeepEqual(currentStatus.Cluster, poll.cluster.Status.Cluster) ||
+		!reflect.DeepEqual(currentStatus.Conditions, poll.cluster.Status.Conditions) ||
+		currentStatus.LeaseHolder != poll.cluster.Status.LeaseHolder ||
+		!apiequality.Semantic.DeepEqual(currentStatus.Load, poll.cluster.Status.Load) {
+		// found a change: save it, signal upstream, stop polling
+		poll.cluster.Status = currentStatus
+		poll.cluster.Status.LastUpdate = metav1.Now()
+		poll.timer = nil
+		a.polls[req] = poll
+		a.publish(req, poll.cluster.Status)
+		a.notify(req)
+		a.signalChanged(poll.cluster.DeepCopy())
+		return
+	}
+	a.publish(req, poll.cluster.Status)
+	if poll.pending {
+		// asked again while reading, so read again now
+		a.polls[req] = poll
+		a.startUpdate(req)
+		return
+	}
+	// poll again, up to some limit
+	policy := a.pollingFor(poll.cluster)
+	if poll.waitFactor == 0 {
+		poll.waitFactor = 1
+	}
+	poll.waitFactor *= 2
+	wait := policy.QuiesceWindow * time.Duration(poll.waitFactor)
+	if wait > policy.MaxBackoff {
+		if policy.SteadyInterval <= 0 {
 			// State is stored in the AkkaCluster object, so we can be parsimonious here.
 			// A new update request will pick up where it left off with previous host and
 			// port etc since those are in the request object at this point.
 			delete(a.polls, req)
+			a.publish(req, nil)
 			return
 		}
-		poll.timer = time.AfterFunc(a.minimalWait*time.Duration(poll.waitFactor), func() { a.update(req) })
-		a.polls[req] = poll
+		// keep polling steadily, without growing the backoff any further
+		poll.waitFactor /= 2
+		wait = policy.SteadyInterval
 	}
+	poll.timer = time.AfterFunc(wait, func() { a.update(req) })
+	a.polls[req] = poll
 }
 
 // initStatus sets ManagementHost and ManagementPort as needed. We re-use the Leader or
@@ -292,7 +610,7 @@ func (a *StatusActor) initStatus(cluster *appv1alpha1.AkkaCluster) error {
 		}
 		cluster.Status.ManagementHost = pod.Status.PodIP
 		if cluster.Status.ManagementPort == 0 {
-			cluster.Status.ManagementPort = findManagementPort(pod)
+			cluster.Status.ManagementPort = a.ports.Of(pod)
 		}
 	}
 	return nil
@@ -300,16 +618,15 @@ func (a *StatusActor) initStatus(cluster *appv1alpha1.AkkaCluster) error {
 
 // fetchUpdate does a bunch of IO that can fail on bad config, network failures, http
 // failure, parsing failure. It returns a status object if found. No distinction is made
-// amongst the various errors, which are all presumed to perhaps work in the future.
-func (a *StatusActor) fetchUpdate(cluster *appv1alpha1.AkkaCluster) *appv1alpha1.AkkaClusterStatus {
+// amongst the various errors, which are all presumed to perhaps work in the future. The
+// read is bounded by timeout.
+func (a *StatusActor) fetchUpdate(cluster *appv1alpha1.AkkaCluster, timeout time.Duration) *appv1alpha1.AkkaClusterStatus {
 	if cluster.Status.ManagementHost == "" {
 		return nil
 	}
-	link := fmt.Sprintf("http://%s:%d/cluster/members/",
-		cluster.Status.ManagementHost,
-		cluster.Status.ManagementPort)
+	link := management.MembersURL(management.Endpoint(cluster.Status.ManagementHost, cluster.Status.ManagementPort))
 	log.Info("fetching status", "name", cluster.Namespace+"/"+cluster.Name, "url", link)
-	body, err := a.reader.ReadURL(link)
+	body, err := management.ReadWithTimeout(a.reader, link, timeout)
 	if err != nil {
 		log.Info("StatusActor could not read endpoint", "err", err)
 		return nil
@@ -322,17 +639,6 @@ func (a *StatusActor) fetchUpdate(cluster *appv1alpha1.AkkaCluster) *appv1alpha1
 	return currentStatus
 }
 
-func findManagementPort(pod *corev1.Pod) int32 {
-	for _, container := range pod.Spec.Containers {
-		for _, port := range container.Ports {
-			if port.Name == "management" {
-				return port.ContainerPort
-			}
-		}
-	}
-	return 8558
-}
-
 // findRunningPod emulates Akka Management by using the spec Selector to list Pods, then
 // filtering on those that have an IP, are not marked for deletion, and currently running.
 // This function also shuffles the list of pods to better avoid getting stuck in a loop
@@ -342,7 +648,7 @@ func (a *StatusActor) findRunningPod(cluster *appv1alpha1.AkkaCluster) *corev1.P
 	pods := a.lister.ListPods(cluster)
 	for n := range rand.Perm(len(pods.Items)) {
 		pod := &pods.Items[n]
-		if pod.Status.PodIP != "" && pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning {
+		if management.IsRunning(pod) {
 			return pod
 		}
 	}
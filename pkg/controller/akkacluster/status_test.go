@@ -2,21 +2,29 @@ package akkacluster
 
 import (
 	"encoding/json"
+	"errors"
 	"math/rand"
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/config"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
 )
 
-// In testing a StatusActor we want to set context of a podLister and urlReader suitable
+// In testing a StatusActor we want to set context of a podLister and management.Reader suitable
 // for simulating various scenarios.
 
 // akkacluster_types has the client schema, here we extend that to server schema :-|
@@ -113,10 +121,12 @@ func generateManagementResult(ips []string) *testManagementStatus {
 	return status
 }
 
-// testReader is a urlReader and podLister mock
+// testReader is a management.Reader and podLister mock. Hosts listed in views answer with their
+// own status, as members of a separate cluster would.
 type testReaderLister struct {
 	ips    []string
 	status *testManagementStatus
+	views  map[string]*testManagementStatus
 	pods   []corev1.Pod
 }
 
@@ -131,8 +141,11 @@ func newCluster(ips ...string) *testReaderLister {
 }
 
 func (r *testReaderLister) ReadURL(uri string) ([]byte, error) {
-	status := r.status
 	link, _ := url.Parse(uri)
+	status := *r.status
+	if view, ok := r.views[link.Hostname()]; ok {
+		status = *view
+	}
 	status.SelfNode = generateNodeAddress(link.Hostname())
 	return json.Marshal(status)
 }
@@ -143,6 +156,9 @@ func (r *testReaderLister) ListPods(cluster *appv1alpha1.AkkaCluster) *corev1.Po
 	return list
 }
 
+// testPolling polls without waiting, and gives up after the same steps as the defaults.
+var testPolling = StatusPolling{QuiesceWindow: time.Nanosecond, MaxBackoff: 60 * time.Nanosecond}
+
 func TestStatusActor(t *testing.T) {
 	statusChanged := make(chan event.GenericEvent, 10)
 	ips := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
@@ -150,13 +166,16 @@ func TestStatusActor(t *testing.T) {
 
 	actor := &StatusActor{
 		inbox:         make(chan func(), 100),
+		done:          make(chan struct{}),
 		statusChanged: statusChanged,
 		lister:        mock,
 		reader:        mock,
-		minimalWait:   time.Nanosecond,
+		polling:       testPolling,
 		polls:         make(map[reconcile.Request]pollingRequest),
 	}
-	go actor.Run()
+	stop := make(chan struct{})
+	defer close(stop)
+	go actor.Start(stop)
 
 	cluster := &appv1alpha1.AkkaCluster{}
 	cluster.Name = "boop"
@@ -178,19 +197,20 @@ func TestStatusActor(t *testing.T) {
 	actor.StartPolling(cluster)
 	<-statusChanged
 	status = actor.GetStatus(getReq(cluster))
-	if status.ManagementHost != "10.0.0.3" {
-		t.Errorf("expected management host to converge on leader, but got %s", status.ManagementHost)
+	leaderURL, _ := url.Parse(mock.status.Leader)
+	if status.ManagementHost != leaderURL.Hostname() {
+		t.Errorf("expected management host to converge on leader %s, but got %s", leaderURL.Hostname(), status.ManagementHost)
 	}
 
 	cluster.Status = status
-	actor.minimalWait = time.Second
+	actor.send(func() { actor.polling = StatusPolling{QuiesceWindow: time.Second, MaxBackoff: time.Minute} })
 	// this should start long polling and not find anything
 	actor.StartPolling(cluster)
 	// this should interrupt it and start over
 	actor.StartPolling(cluster)
 	// this should interrupt it and find something
 	cluster.Status.Cluster.Oldest = "somethingChanged"
-	actor.minimalWait = time.Nanosecond
+	actor.send(func() { actor.polling = testPolling })
 	actor.StartPolling(cluster)
 	<-statusChanged
 	status = actor.GetStatus(getReq(cluster))
@@ -240,3 +260,546 @@ func TestStatusActor(t *testing.T) {
 		t.Errorf("expected polling to be cleared, but got %#v", actor.polls)
 	}
 }
+
+func TestSplitBrain(t *testing.T) {
+	statusChanged := make(chan event.GenericEvent, 10)
+	recorder := record.NewFakeRecorder(10)
+	mock := newCluster("10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4")
+	// one cluster of .1 and .2 led by .1, another of .3 and .4 led by .3
+	mock.status = generateManagementResult([]string{"10.0.0.1", "10.0.0.2"})
+	mock.status.Leader = generateNodeAddress("10.0.0.1")
+	island := generateManagementResult([]string{"10.0.0.3", "10.0.0.4"})
+	island.Leader = generateNodeAddress("10.0.0.3")
+	mock.views = map[string]*testManagementStatus{"10.0.0.3": island, "10.0.0.4": island}
+
+	actor := &StatusActor{
+		inbox:         make(chan func(), 100),
+		done:          make(chan struct{}),
+		statusChanged: statusChanged,
+		lister:        mock,
+		reader:        mock,
+		recorder:      recorder,
+		polling:       testPolling,
+		polls:         make(map[reconcile.Request]pollingRequest),
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go actor.Start(stop)
+
+	cluster := &appv1alpha1.AkkaCluster{}
+	cluster.Name = "boop"
+	cluster.Namespace = "bop"
+	cluster.Spec.SplitBrain = &appv1alpha1.AkkaClusterSplitBrainSpec{Probes: 4}
+	cluster.Status = &appv1alpha1.AkkaClusterStatus{ManagementHost: "10.0.0.1", ManagementPort: 8558}
+
+	actor.StartPolling(cluster)
+	<-statusChanged
+	status := actor.GetStatus(getReq(cluster))
+	if !isConditionTrue(status, appv1alpha1.AkkaClusterSplitBrain) {
+		t.Errorf("expected split brain condition, but got %#v", status.Conditions)
+	}
+	if len(status.Islands) != 2 {
+		t.Errorf("expected two islands, but got %#v", status.Islands)
+	}
+	select {
+	case e := <-recorder.Events:
+		if !strings.Contains(e, "SplitBrain") {
+			t.Errorf("expected split brain event, but got %s", e)
+		}
+	default:
+		t.Errorf("expected split brain event, but got none")
+	}
+
+	// islands merge back into one cluster
+	mock.views = nil
+	cluster.Status = status
+	actor.StartPolling(cluster)
+	<-statusChanged
+	status = actor.GetStatus(getReq(cluster))
+	if isConditionTrue(status, appv1alpha1.AkkaClusterSplitBrain) {
+		t.Errorf("expected split brain to be resolved, but got %#v", status.Conditions)
+	}
+	if status.Islands != nil {
+		t.Errorf("expected islands to be cleared, but got %#v", status.Islands)
+	}
+	if e := <-recorder.Events; !strings.Contains(e, "SplitBrainResolved") {
+		t.Errorf("expected resolved event, but got %s", e)
+	}
+}
+
+// testLeaseReader holds a Lease for every cluster.
+type testLeaseReader struct {
+	holder atomic.Value
+}
+
+func (l *testLeaseReader) LeaseHolder(cluster *appv1alpha1.AkkaCluster) (string, error) {
+	return l.holder.Load().(string), nil
+}
+
+func TestLeaseHolder(t *testing.T) {
+	statusChanged := make(chan event.GenericEvent, 10)
+	mock := newCluster("10.0.0.1", "10.0.0.2")
+	leases := &testLeaseReader{}
+	leases.holder.Store("someActorSystem@10.0.0.1:2552")
+
+	actor := &StatusActor{
+		inbox:         make(chan func(), 100),
+		done:          make(chan struct{}),
+		statusChanged: statusChanged,
+		lister:        mock,
+		reader:        mock,
+		leases:        leases,
+		polling:       testPolling,
+		polls:         make(map[reconcile.Request]pollingRequest),
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go actor.Start(stop)
+
+	cluster := &appv1alpha1.AkkaCluster{}
+	cluster.Name = "boop"
+	cluster.Namespace = "bop"
+	cluster.Spec.SplitBrain = &appv1alpha1.AkkaClusterSplitBrainSpec{
+		Resolver: &appv1alpha1.AkkaClusterSplitBrainResolverSpec{Strategy: appv1alpha1.LeaseMajority},
+	}
+	actor.StartPolling(cluster)
+	<-statusChanged
+	status := actor.GetStatus(getReq(cluster))
+	if status.LeaseHolder != "someActorSystem@10.0.0.1:2552" {
+		t.Errorf("expected lease holder in status, but got %q", status.LeaseHolder)
+	}
+
+	// a new holder is a status change, even if membership isn't
+	leases.holder.Store("someActorSystem@10.0.0.2:2552")
+	cluster.Status = status
+	actor.StartPolling(cluster)
+	<-statusChanged
+	if status = actor.GetStatus(getReq(cluster)); status.LeaseHolder != "someActorSystem@10.0.0.2:2552" {
+		t.Errorf("expected new lease holder in status, but got %q", status.LeaseHolder)
+	}
+}
+
+func TestCompareViews(t *testing.T) {
+	view := func(leader string, ips ...string) *appv1alpha1.AkkaClusterManagementStatus {
+		v := &appv1alpha1.AkkaClusterManagementStatus{Leader: generateNodeAddress(leader)}
+		for _, ip := range ips {
+			v.Members = append(v.Members, appv1alpha1.AkkaClusterMemberStatus{Node: generateNodeAddress(ip)})
+		}
+		return v
+	}
+
+	if split, _ := compareViews(map[string]*appv1alpha1.AkkaClusterManagementStatus{
+		"10.0.0.1": view("10.0.0.1", "10.0.0.1", "10.0.0.2"),
+	}); split {
+		t.Error("expected a single view to never be split")
+	}
+	if split, msg := compareViews(map[string]*appv1alpha1.AkkaClusterManagementStatus{
+		"10.0.0.1": view("10.0.0.1", "10.0.0.1", "10.0.0.2"),
+		"10.0.0.2": view("10.0.0.1", "10.0.0.1", "10.0.0.2"),
+	}); split {
+		t.Errorf("expected agreeing views, but got %s", msg)
+	}
+	if split, msg := compareViews(map[string]*appv1alpha1.AkkaClusterManagementStatus{
+		"10.0.0.1": view("10.0.0.1", "10.0.0.1", "10.0.0.2"),
+		"10.0.0.3": view("10.0.0.3", "10.0.0.3"),
+	}); !split {
+		t.Errorf("expected different leaders to be split, but got %s", msg)
+	}
+	if split, msg := compareViews(map[string]*appv1alpha1.AkkaClusterManagementStatus{
+		"10.0.0.1": view("10.0.0.1", "10.0.0.1", "10.0.0.2"),
+		"10.0.0.3": {Members: view("", "10.0.0.3").Members},
+	}); !split {
+		t.Errorf("expected member missing leader to be split, but got %s", msg)
+	}
+}
+
+// slowReader blocks reads from slow hosts until release is closed, then fails them.
+type slowReader struct {
+	management.Reader
+	slow      map[string]bool
+	release   chan struct{}
+	slowReads int32
+	// started receives a value as each slow read starts.
+	started chan struct{}
+}
+
+func (r *slowReader) ReadURL(uri string) ([]byte, error) {
+	link, _ := url.Parse(uri)
+	if r.slow[link.Hostname()] {
+		atomic.AddInt32(&r.slowReads, 1)
+		if r.started != nil {
+			r.started <- struct{}{}
+		}
+		<-r.release
+		return nil, errors.New("timeout")
+	}
+	return r.Reader.ReadURL(uri)
+}
+
+func TestStatusActorSlowCluster(t *testing.T) {
+	statusChanged := make(chan event.GenericEvent, 10)
+	mock := newCluster("10.0.0.1", "10.0.0.2")
+	reader := &slowReader{Reader: mock, slow: map[string]bool{"10.9.9.9": true}, release: make(chan struct{}),
+		started: make(chan struct{}, 10)}
+	defer close(reader.release)
+	actor := &StatusActor{
+		inbox:         make(chan func(), 100),
+		done:          make(chan struct{}),
+		statusChanged: statusChanged,
+		lister:        mock,
+		reader:        reader,
+		workers:       2,
+		polling:       testPolling,
+		polls:         make(map[reconcile.Request]pollingRequest),
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go actor.Start(stop)
+
+	slow := &appv1alpha1.AkkaCluster{}
+	slow.Name = "slow"
+	slow.Namespace = "bop"
+	slow.Status = &appv1alpha1.AkkaClusterStatus{ManagementHost: "10.9.9.9", ManagementPort: 8558}
+	fast := slow.DeepCopy()
+	fast.Name = "fast"
+	fast.Status.ManagementHost = "10.0.0.1"
+
+	actor.StartPolling(slow)
+	actor.StartPolling(slow)
+	actor.StartPolling(fast)
+	select {
+	case e := <-statusChanged:
+		if e.Meta.GetName() != "fast" {
+			t.Errorf("expected fast cluster to change, but got %s", e.Meta.GetName())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected fast cluster status while slow cluster is stuck")
+	}
+
+	read := make(chan *appv1alpha1.AkkaClusterStatus)
+	go func() { read <- actor.GetStatus(getReq(slow)) }()
+	select {
+	case status := <-read:
+		if status == nil || status.ManagementHost != "10.9.9.9" {
+			t.Errorf("expected last known status of slow cluster, but got %#v", status)
+		}
+	case <-time.After(time.Second):
+		t.Error("expected GetStatus not to wait for the slow cluster")
+	}
+	// with every worker busy, the slow read may still be queued, so wait for it to start
+	select {
+	case <-reader.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a read of the slow cluster to start")
+	}
+	if n := atomic.LoadInt32(&reader.slowReads); n != 1 {
+		t.Errorf("expected one read of the slow cluster in flight, but got %d", n)
+	}
+}
+
+func TestStatusActorQueue(t *testing.T) {
+	slow, fast := &appv1alpha1.AkkaCluster{}, &appv1alpha1.AkkaCluster{}
+	slow.Name, fast.Name = "slow", "fast"
+	actor := &StatusActor{
+		workers: 1,
+		work:    make(chan func(), 1),
+		polling: testPolling,
+		polls: map[reconcile.Request]pollingRequest{
+			getReq(slow): {cluster: slow},
+			getReq(fast): {cluster: fast},
+		},
+	}
+
+	// with the only worker busy, asking again for a queued cluster doesn't queue it twice
+	actor.busy = 1
+	actor.startUpdate(getReq(slow))
+	actor.startUpdate(getReq(slow))
+	actor.startUpdate(getReq(fast))
+	if len(actor.queue) != 2 || len(actor.work) != 0 {
+		t.Fatalf("expected each cluster queued once and nothing handed out, but got %v and %d jobs", actor.queue, len(actor.work))
+	}
+
+	// a free worker takes the first in the queue, and the other waits
+	actor.busy = 0
+	actor.dispatch()
+	if len(actor.work) != 1 || len(actor.queue) != 1 || actor.queue[0] != getReq(fast) {
+		t.Errorf("expected one job handed out and the fast cluster still queued, but got %d jobs and %v", len(actor.work), actor.queue)
+	}
+	if poll := actor.polls[getReq(slow)]; !poll.inFlight || poll.queued || poll.pending {
+		t.Errorf("expected the slow cluster read once, but got %+v", poll)
+	}
+
+	// a cluster no longer polled is dropped from the queue
+	delete(actor.polls, getReq(fast))
+	<-actor.work
+	actor.busy = 0
+	actor.dispatch()
+	if len(actor.work) != 0 || len(actor.queue) != 0 {
+		t.Errorf("expected the queue emptied without a read, but got %d jobs and %v", len(actor.work), actor.queue)
+	}
+}
+
+// failingReader fails every read, counting them.
+type failingReader struct {
+	reads int
+}
+
+func (r *failingReader) ReadURL(uri string) ([]byte, error) {
+	r.reads++
+	return nil, errors.New("connection refused")
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	failing := &failingReader{}
+	now := time.Now()
+	breaker := newCircuitBreakerReader(failing)
+	breaker.now = func() time.Time { return now }
+	link := management.MembersURL(management.Endpoint("10.0.0.1", 8558))
+
+	for i := 0; i < breakerThreshold+2; i++ {
+		breaker.ReadURL(link)
+	}
+	if failing.reads != breakerThreshold {
+		t.Errorf("expected reads to stop after %d failures, but got %d", breakerThreshold, failing.reads)
+	}
+	if _, err := breaker.ReadURL(management.MembersURL(management.Endpoint("10.0.0.2", 8558))); err == nil ||
+		failing.reads != breakerThreshold+1 {
+		t.Errorf("expected other hosts to be read, but got %d reads", failing.reads)
+	}
+
+	// after the cooldown, a single trial read goes through, and fails the circuit again
+	now = now.Add(breakerCooldown)
+	breaker.ReadURL(link)
+	breaker.ReadURL(link)
+	if failing.reads != breakerThreshold+2 {
+		t.Errorf("expected one trial read after cooldown, but got %d reads", failing.reads)
+	}
+
+	// success closes the circuit
+	breaker.reader = newCluster("10.0.0.1")
+	now = now.Add(breakerCooldown)
+	if _, err := breaker.ReadURL(link); err != nil {
+		t.Errorf("expected trial read to succeed, but got %v", err)
+	}
+	breaker.reader = failing
+	breaker.ReadURL(link)
+	if failing.reads != breakerThreshold+3 {
+		t.Errorf("expected closed circuit to read again, but got %d reads", failing.reads)
+	}
+}
+
+func TestStatusActorLifecycle(t *testing.T) {
+	statusChanged := make(chan event.GenericEvent, 10)
+	mock := newCluster("10.0.0.1")
+	actor := NewStatusActor(nil, statusChanged, nil, DefaultOptions())
+	actor.lister = mock
+	actor.reader = mock
+	actor.polling = StatusPolling{QuiesceWindow: time.Hour, MaxBackoff: 60 * time.Hour}
+	if err := actor.Healthz(nil); err != nil {
+		t.Errorf("expected actor not yet started to be healthy, but got %v", err)
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan error)
+	go func() { stopped <- actor.Start(stop) }()
+
+	cluster := &appv1alpha1.AkkaCluster{}
+	cluster.Name = "boop"
+	cluster.Namespace = "bop"
+	actor.StartPolling(cluster)
+	<-statusChanged
+	// a second change leaves a poll waiting on its timer
+	cluster.Status = actor.GetStatus(getReq(cluster))
+	actor.StartPolling(cluster)
+	actor.StartPolling(cluster)
+	if err := actor.Healthz(nil); err != nil {
+		t.Errorf("expected running actor to be healthy, but got %v", err)
+	}
+
+	// a wedged loop fails the health check
+	wedged := make(chan struct{})
+	actor.inbox <- func() { <-wedged }
+	for actor.Healthz(nil) == nil {
+		atomic.StoreInt64(&actor.heartbeat, time.Now().Add(-2*livenessTimeout).UnixNano())
+		time.Sleep(time.Millisecond)
+	}
+	close(wedged)
+
+	close(stop)
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("expected clean stop, but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected actor to stop")
+	}
+	if len(actor.polls) != 0 || actor.GetStatus(getReq(cluster)) != nil {
+		t.Errorf("expected polling state to be cleared on stop, but got %#v", actor.polls)
+	}
+	// messages after stop are dropped rather than blocking
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2000; i++ {
+			actor.StartPolling(cluster)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("expected StartPolling not to block on a stopped actor")
+	}
+}
+
+// timeoutReader counts reads, and records the timeout asked for by the last one.
+type timeoutReader struct {
+	management.Reader
+	reads   int32
+	timeout int64
+}
+
+func (r *timeoutReader) ReadURLTimeout(uri string, timeout time.Duration) ([]byte, error) {
+	atomic.AddInt32(&r.reads, 1)
+	atomic.StoreInt64(&r.timeout, int64(timeout))
+	return r.Reader.ReadURL(uri)
+}
+
+func TestStatusPollingPolicy(t *testing.T) {
+	actor := &StatusActor{polling: StatusPolling{QuiesceWindow: 2 * time.Second}}
+	cluster := &appv1alpha1.AkkaCluster{}
+	if policy := actor.pollingFor(cluster); policy != (StatusPolling{QuiesceWindow: 2 * time.Second,
+		MaxBackoff: defaultStatusPolling.MaxBackoff, Timeout: defaultStatusPolling.Timeout}) {
+		t.Errorf("expected actor policy with defaults, but got %+v", policy)
+	}
+	operatorConfig := config.Default()
+	operatorConfig.StatusPolling.MaxBackoff = &metav1.Duration{Duration: 2 * time.Minute}
+	if policy := OptionsFor(operatorConfig).StatusPolling; policy != (StatusPolling{QuiesceWindow: time.Second,
+		MaxBackoff: 2 * time.Minute, Timeout: defaultStatusPolling.Timeout}) {
+		t.Errorf("expected the operator configuration to set the actor policy, but got %+v", policy)
+	}
+	cluster.Spec.StatusPolling = &appv1alpha1.AkkaClusterStatusPollingSpec{
+		Timeout:        &metav1.Duration{Duration: 250 * time.Millisecond},
+		SteadyInterval: &metav1.Duration{Duration: time.Millisecond},
+	}
+	if policy := actor.pollingFor(cluster); policy.QuiesceWindow != 2*time.Second ||
+		policy.Timeout != 250*time.Millisecond || policy.SteadyInterval != time.Millisecond {
+		t.Errorf("expected spec to override actor policy, but got %+v", policy)
+	}
+
+	// with a steady interval, polling goes on past the backoff cap
+	statusChanged := make(chan event.GenericEvent, 10)
+	mock := newCluster("10.0.0.1", "10.0.0.2")
+	reader := &timeoutReader{Reader: mock}
+	actor = &StatusActor{
+		inbox:         make(chan func(), 100),
+		done:          make(chan struct{}),
+		statusChanged: statusChanged,
+		lister:        mock,
+		reader:        reader,
+		polling:       testPolling,
+		polls:         make(map[reconcile.Request]pollingRequest),
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go actor.Start(stop)
+	cluster.Name = "boop"
+	cluster.Namespace = "bop"
+	actor.StartPolling(cluster)
+	<-statusChanged
+	cluster.Status = actor.GetStatus(getReq(cluster))
+	actor.StartPolling(cluster)
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&reader.reads) < 20 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if reads := atomic.LoadInt32(&reader.reads); reads < 20 {
+		t.Errorf("expected steady polling past the backoff cap, but got %d reads", reads)
+	}
+	if timeout := time.Duration(atomic.LoadInt64(&reader.timeout)); timeout != 250*time.Millisecond {
+		t.Errorf("expected reads bounded by the spec timeout, but got %s", timeout)
+	}
+	if actor.GetStatus(getReq(cluster)) == nil {
+		t.Errorf("expected steady polling to keep status published")
+	}
+	actor.StopPolling(getReq(cluster))
+}
+
+// loadReader is a management.Reader mock that also serves the shards and metrics of members.
+type loadReader struct {
+	management.Reader
+	shards  map[string]int
+	metrics map[string]string
+}
+
+func (r *loadReader) ReadURL(uri string) ([]byte, error) {
+	link, _ := url.Parse(uri)
+	switch {
+	case strings.HasPrefix(link.Path, "/cluster/shards/"):
+		details := management.ShardDetails{Regions: []management.ShardRegionInfo{}}
+		for i := 0; i < r.shards[link.Hostname()]; i++ {
+			details.Regions = append(details.Regions, management.ShardRegionInfo{ShardID: strconv.Itoa(i), NumEntities: 1})
+		}
+		return json.Marshal(details)
+	case link.Path == "/metrics":
+		metrics, ok := r.metrics[link.Hostname()]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		return []byte(metrics), nil
+	}
+	return r.Reader.ReadURL(uri)
+}
+
+func TestStatusActorLoad(t *testing.T) {
+	statusChanged := make(chan event.GenericEvent, 10)
+	mock := newCluster("10.0.0.1", "10.0.0.2")
+	reader := &loadReader{
+		Reader: mock,
+		shards: map[string]int{"10.0.0.1": 3, "10.0.0.2": 4},
+		metrics: map[string]string{
+			"10.0.0.1": "# TYPE mailbox_size gauge\nmailbox_size{actor=\"a\"} 10\nmailbox_size{actor=\"b\"} 2.5\n",
+			"10.0.0.2": "mailbox_size 7\nmailbox_size_max 100\n",
+		},
+	}
+	actor := &StatusActor{
+		inbox:         make(chan func(), 100),
+		done:          make(chan struct{}),
+		statusChanged: statusChanged,
+		lister:        mock,
+		reader:        reader,
+		polling:       testPolling,
+		polls:         make(map[reconcile.Request]pollingRequest),
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go actor.Start(stop)
+
+	cluster := &appv1alpha1.AkkaCluster{}
+	cluster.Name = "boop"
+	cluster.Namespace = "bop"
+	cluster.Spec.Autoscaling = &appv1alpha1.AkkaClusterAutoscalingSpec{
+		MinReplicas: 1,
+		MaxReplicas: 5,
+		Shards:      &appv1alpha1.AkkaClusterShardsLoadSpec{EntityTypes: []string{"cart"}, TargetPerMember: 5},
+		Metric:      &appv1alpha1.AkkaClusterMetricLoadSpec{Name: "mailbox_size", Port: 9001, TargetPerMember: resource.MustParse("10")},
+	}
+	if policy := actor.pollingFor(cluster); policy.SteadyInterval != autoscalingPollInterval {
+		t.Errorf("expected autoscaled cluster to poll steadily, but got %+v", policy)
+	}
+	actor.StartPolling(cluster)
+	<-statusChanged
+	status := actor.GetStatus(getReq(cluster))
+	if status.Load == nil || status.Load.Members != 2 || *status.Load.Shards != 7 || status.Load.Metric.String() != "19500m" {
+		t.Fatalf("expected load summed over members, but got %#v", status.Load)
+	}
+
+	// load can't be known without every member
+	delete(reader.metrics, "10.0.0.2")
+	cluster.Status = status
+	actor.StartPolling(cluster)
+	<-statusChanged
+	if status = actor.GetStatus(getReq(cluster)); status.Load != nil {
+		t.Errorf("expected unknown load when a member can't be read, but got %#v", status.Load)
+	}
+}
@@ -1,8 +1,16 @@
 package akkacluster
 
 import (
+	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"unsafe"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // Problem statement: we have a baseline Kubernetes resource, and a live resource, and need
@@ -13,20 +21,60 @@ import (
 // Alternative approach to this problem is to convert objects to JSON--getting their exported
 // and normalized form--and compare those. A challenge with that approach is dealing with
 // default values which may be hard to detect once converted, like time.Time.
+//
+// Zero values in the baseline normally mean "don't care", as most fields are left unset and
+// filled in downstream. A zero is compared though when it is explicit: set behind a pointer,
+// like replicas: 0, serialized without omitempty, or present as a list element or map value.
+// Quantities and int-or-strings compare by value, so 1000m equals 1. Lists tagged with a
+// patchMergeKey, like containers, env and ports, match elements by key rather than index.
 
 // SubsetEqual (A,B) returns true if A is a subset of B.
 // This allows us to focus on a smaller set of required fields and ignore other fields
 // that have downstream mutations to objects like creationTimestamp, uid, resourceVersion.
 // The algorithm here is similar to reflect.DeepCopy, in that we use reflection to walk
-// a potentially recursive tree. For comparison, we ignore empty or zero value fields in A.
-func SubsetEqual(subset, superset interface{}) bool {
+// a potentially recursive tree. For comparison, we ignore unset zero value fields in A,
+// as well as any fields under the ignore paths.
+func SubsetEqual(subset, superset interface{}, ignore ...FieldPath) bool {
 	t := newTreeWalk()
+	t.ignore = ignore
 	return t.subsetValueEqual(reflect.ValueOf(subset), reflect.ValueOf(superset))
 }
 
+// SubsetDiff (A,B) walks A and B like SubsetEqual, but rather than stopping at the first
+// mismatch it returns every field path where B doesn't match A. It is empty if A is a
+// subset of B. Values of sensitive fields, see redactedValue, are redacted.
+func SubsetDiff(subset, superset interface{}, ignore ...FieldPath) []Diff {
+	t := newTreeWalk()
+	t.ignore = ignore
+	t.collect = true
+	t.subsetValueEqual(reflect.ValueOf(subset), reflect.ValueOf(superset))
+	return t.diffs
+}
+
+// Diff is a field where the superset doesn't match the subset. Path uses json field names,
+// like spec.template.spec.containers[name=main].image.
+type Diff struct {
+	Path   string
+	Wanted interface{}
+	Actual interface{}
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("%s: wanted %v, actual %v", d.Path, d.Wanted, d.Actual)
+}
+
 type treeWalk struct {
 	matches int
 	visited map[node]bool
+
+	// with collect set, the walk continues past mismatches to record them all in diffs
+	collect bool
+	diffs   []Diff
+	path    []string
+	// redact is above zero while walking inside a sensitive value
+	redact int
+	// fields under these paths are skipped
+	ignore []FieldPath
 }
 
 func newTreeWalk() *treeWalk {
@@ -42,8 +90,24 @@ type node struct {
 	typ reflect.Type
 }
 
+// field carries what a struct field tag says about its value: whether a zero value counts,
+// and for lists, the merge key that identifies elements.
+type field struct {
+	explicit bool
+	mergeKey string
+}
+
+var (
+	quantityType    = reflect.TypeOf(resource.Quantity{})
+	intOrStringType = reflect.TypeOf(intstr.IntOrString{})
+)
+
 // Tests for subset equality using reflected types.
 func (t *treeWalk) subsetValueEqual(subset, superset reflect.Value) bool {
+	return t.walk(subset, superset, field{})
+}
+
+func (t *treeWalk) walk(subset, superset reflect.Value, f field) bool {
 	// if subset side is undefined, then nothing to compare
 	if !subset.IsValid() {
 		return true
@@ -51,10 +115,10 @@ func (t *treeWalk) subsetValueEqual(subset, superset reflect.Value) bool {
 
 	// sanity check, rest of code assume same type on both sides
 	if !superset.IsValid() {
-		return false
+		return t.mismatch(subset, nil)
 	}
 	if subset.Type() != superset.Type() {
-		return false
+		return t.mismatch(subset, superset)
 	}
 
 	// short circuit references already seen
@@ -77,49 +141,222 @@ func (t *treeWalk) subsetValueEqual(subset, superset reflect.Value) bool {
 		t.visited[n] = true
 	}
 
+	// types with more than one representation of the same value, like 1000m and 1
+	if subset.CanInterface() {
+		switch subset.Type() {
+		case quantityType:
+			wanted, actual := subset.Interface().(resource.Quantity), superset.Interface().(resource.Quantity)
+			if wanted.IsZero() && !f.explicit {
+				return true
+			}
+			return t.semanticEqual(wanted.Cmp(actual) == 0, wanted.String(), actual.String())
+		case intOrStringType:
+			wanted, actual := subset.Interface().(intstr.IntOrString), superset.Interface().(intstr.IntOrString)
+			if wanted == (intstr.IntOrString{}) && !f.explicit {
+				return true
+			}
+			return t.semanticEqual(wanted.String() == actual.String(), wanted.String(), actual.String())
+		}
+	}
+
 	// walk tree
 	switch subset.Kind() {
 	case reflect.Array, reflect.Slice:
+		if f.mergeKey != "" && subset.Type().Elem().Kind() == reflect.Struct {
+			return t.keyedListEqual(subset, superset, f.mergeKey)
+		}
 		// recursive subset: superset may have extra elements at the end, only the subset members must match
 		if superset.Len() < subset.Len() {
-			return false
+			return t.mismatch(fmt.Sprintf("%d items", subset.Len()), fmt.Sprintf("%d items", superset.Len()))
 		}
-		for i := 0; i < subset.Len(); i++ {
-			if !t.subsetValueEqual(subset.Index(i), superset.Index(i)) {
-				return false
-			}
+		equal := true
+		for i := 0; i < subset.Len() && (equal || t.collect); i++ {
+			equal = t.at(fmt.Sprintf("[%d]", i), subset.Index(i), superset.Index(i), field{explicit: true}) && equal
 		}
-		return true
+		return equal
 	case reflect.Interface, reflect.Ptr:
-		return t.subsetValueEqual(subset.Elem(), superset.Elem())
+		// a set pointer makes its value explicit, so a pointer to zero is compared as zero
+		return t.walk(subset.Elem(), superset.Elem(), field{explicit: true, mergeKey: f.mergeKey})
 	case reflect.Struct:
-		for i, n := 0, subset.NumField(); i < n; i++ {
-			if !t.subsetValueEqual(subset.Field(i), superset.Field(i)) {
-				return false
-			}
+		if redactedValue(subset) || redactedValue(superset) {
+			t.redact++
+			defer func() { t.redact-- }()
 		}
-		return true
+		equal := true
+		for i, n := 0, subset.NumField(); i < n && (equal || t.collect); i++ {
+			structField := subset.Type().Field(i)
+			equal = t.at(fieldSegment(structField), subset.Field(i), superset.Field(i), fieldOf(structField)) && equal
+		}
+		return equal
 	case reflect.Map:
-		for _, k := range subset.MapKeys() {
-			if !t.subsetValueEqual(subset.MapIndex(k), superset.MapIndex(k)) {
-				return false
+		keys := subset.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		equal := true
+		for _, k := range keys {
+			if !equal && !t.collect {
+				break
 			}
+			equal = t.at(fmt.Sprintf("[%v]", k), subset.MapIndex(k), superset.MapIndex(k), field{explicit: true}) && equal
 		}
-		return true
+		return equal
 	default:
 		// Leaf node: if exported, non-default value, compare subset with superset.
 		if subset.CanInterface() {
-			// Ignore default values, like empty string, bool false, zero int... :-|
-			// If needed, could be more selective in the kinds of zeros ignored.
-			if subset.Interface() == reflect.Zero(subset.Type()).Interface() {
+			// Ignore default values, like empty string, bool false, zero int, unless explicit.
+			if !f.explicit && subset.Interface() == reflect.Zero(subset.Type()).Interface() {
 				return true
 			}
 			if subset.Interface() == superset.Interface() {
 				t.matches++
 				return true
 			}
-			return false
+			return t.mismatch(subset, superset)
 		}
 		return true // Ignore non-exported opaque internal fields, like time.Time{}.
 	}
 }
+
+// at walks subset and superset as the given path segment.
+func (t *treeWalk) at(segment string, subset, superset reflect.Value, f field) bool {
+	t.path = append(t.path, segment)
+	defer func() { t.path = t.path[:len(t.path)-1] }()
+	for _, path := range t.ignore {
+		if path.matches(t.path) {
+			return true
+		}
+	}
+	return t.walk(subset, superset, f)
+}
+
+// semanticEqual counts a match, or records a mismatch between the given descriptions.
+func (t *treeWalk) semanticEqual(equal bool, wanted, actual string) bool {
+	if !equal {
+		return t.mismatch(wanted, actual)
+	}
+	t.matches++
+	return true
+}
+
+// keyedListEqual matches each element of subset with the superset element that has the
+// same merge key, like the name of a container, so that order doesn't matter. Elements
+// without a key fall back to matching by index.
+func (t *treeWalk) keyedListEqual(subset, superset reflect.Value, mergeKey string) bool {
+	index := make(map[interface{}]int)
+	for i := superset.Len() - 1; i >= 0; i-- {
+		if key, ok := mergeKeyOf(superset.Index(i), mergeKey); ok {
+			index[key] = i
+		}
+	}
+	equal := true
+	for i := 0; i < subset.Len() && (equal || t.collect); i++ {
+		element := subset.Index(i)
+		var actual reflect.Value
+		segment := fmt.Sprintf("[%d]", i)
+		if key, ok := mergeKeyOf(element, mergeKey); ok {
+			segment = fmt.Sprintf("[%s=%v]", mergeKey, key)
+			if j, found := index[key]; found {
+				actual = superset.Index(j)
+			}
+		} else if i < superset.Len() {
+			actual = superset.Index(i)
+		}
+		equal = t.at(segment, element, actual, field{explicit: true}) && equal
+	}
+	return equal
+}
+
+// mergeKeyOf returns the value of the field named key in json, if set on the struct v.
+func mergeKeyOf(v reflect.Value, key string) (interface{}, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		if jsonName(v.Type().Field(i)) != key {
+			continue
+		}
+		value := v.Field(i)
+		if !value.CanInterface() || value.IsZero() {
+			return nil, false
+		}
+		return value.Interface(), true
+	}
+	return nil, false
+}
+
+// mismatch records a diff at the current path when collecting diffs, and returns false.
+// Wanted and actual may be reflected values or plain descriptions.
+func (t *treeWalk) mismatch(wanted, actual interface{}) bool {
+	if !t.collect {
+		return false
+	}
+	describe := func(v interface{}) interface{} {
+		if t.redact > 0 {
+			return "[redacted]"
+		}
+		if value, ok := v.(reflect.Value); ok {
+			switch {
+			case !value.IsValid() || !value.CanInterface():
+				return "<none>"
+			case value.Kind() == reflect.Struct || value.Kind() == reflect.Ptr || value.Kind() == reflect.Map ||
+				value.Kind() == reflect.Slice || value.Kind() == reflect.Interface:
+				// name whole objects rather than dumping them, which also keeps nested secrets out
+				return value.Type().String()
+			}
+			return value.Interface()
+		}
+		if v == nil {
+			return "<none>"
+		}
+		return v
+	}
+	t.diffs = append(t.diffs, Diff{
+		Path:   strings.TrimPrefix(strings.Join(t.path, ""), "."),
+		Wanted: describe(wanted),
+		Actual: describe(actual),
+	})
+	return false
+}
+
+// fieldSegment names a struct field in a path by its json name. Inlined fields, like
+// TypeMeta, add no segment of their own.
+func fieldSegment(structField reflect.StructField) string {
+	name := jsonName(structField)
+	if name == "" && structField.Anonymous {
+		return ""
+	}
+	if name == "" || name == "-" {
+		name = structField.Name
+	}
+	return "." + name
+}
+
+// jsonName is the name of a struct field in json, or empty if its tag gives none.
+func jsonName(structField reflect.StructField) string {
+	return strings.Split(structField.Tag.Get("json"), ",")[0]
+}
+
+// fieldOf reads the tags of a struct field. Fields serialized without omitempty are always
+// sent, so their zero value is explicit. Untagged fields keep zero as "don't care".
+func fieldOf(structField reflect.StructField) field {
+	tag := structField.Tag.Get("json")
+	return field{
+		explicit: jsonName(structField) != "" && jsonName(structField) != "-" && !strings.Contains(tag, ",omitempty"),
+		mergeKey: structField.Tag.Get("patchMergeKey"),
+	}
+}
+
+// sensitiveName matches env var names that usually hold credentials.
+var sensitiveName = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|private|api_?key|access_?key)`)
+
+// redactedValue is true for values that shouldn't show up in logs or Events: Secrets, and
+// env vars taken from a Secret or with a name that suggests a credential.
+func redactedValue(v reflect.Value) bool {
+	if !v.CanInterface() {
+		return false
+	}
+	switch value := v.Interface().(type) {
+	case corev1.Secret:
+		return true
+	case corev1.EnvVar:
+		return sensitiveName.MatchString(value.Name) ||
+			(value.ValueFrom != nil && value.ValueFrom.SecretKeyRef != nil)
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package akkacluster
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appv1alpha1 "github.com/lightbend/akka-cluster-operator/pkg/apis/app/v1alpha1"
+	"github.com/lightbend/akka-cluster-operator/pkg/management"
+)
+
+// On graceful teardown:
+//
+// Without a finalizer, deleting an AkkaCluster lets garbage collection remove the
+// Deployment and with it every pod at once, so Akka never gets to hand over shards or
+// finish in-flight work. An AkkaCluster with spec.teardown set gets the teardownFinalizer
+// instead, and deletion walks the
+// members through Leave one at a time, youngest first and oldest last, which keeps
+// singletons on the oldest node moving only once. As members leave, the Deployment is
+// scaled down to those remaining, so a member whose JVM exits after leaving isn't restarted
+// and bootstrapped back in. When no members remain, or the timeout passes, the finalizer
+// is released and garbage collection takes the owned resources. No members remaining is
+// only trusted once the StatusActor has read status since deletion started, as the status
+// stored, or none at all after a restart of the operator, may be out of date.
+//
+// The finalizer is only there while spec.teardown is set, as it holds deletion for as long
+// as no operator runs.
+
+const (
+	// teardownFinalizer holds deletion of an AkkaCluster until its members have left.
+	teardownFinalizer = "app.lightbend.com/leave-cluster"
+	// defaultTeardownTimeout bounds how long deletion waits for members to leave, unless
+	// spec.teardown sets another timeout.
+	defaultTeardownTimeout = 2 * time.Minute
+	// teardownPollInterval is how often teardown checks on leaving members.
+	teardownPollInterval = 5 * time.Second
+)
+
+// hasFinalizer is true if the AkkaCluster lists the named finalizer.
+func hasFinalizer(akkaCluster *appv1alpha1.AkkaCluster, finalizer string) bool {
+	for _, f := range akkaCluster.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// removeFinalizer drops the named finalizer from the AkkaCluster, if present.
+func removeFinalizer(akkaCluster *appv1alpha1.AkkaCluster, finalizer string) {
+	finalizers := []string{}
+	for _, f := range akkaCluster.Finalizers {
+		if f != finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	akkaCluster.Finalizers = finalizers
+}
+
+// wantsTeardown is true if the AkkaCluster asks for its members to leave on deletion.
+func wantsTeardown(akkaCluster *appv1alpha1.AkkaCluster) bool {
+	return akkaCluster.Spec.Teardown != nil
+}
+
+// teardownTimeout bounds how long deletion of the AkkaCluster waits for members to leave.
+func teardownTimeout(akkaCluster *appv1alpha1.AkkaCluster) time.Duration {
+	if spec := akkaCluster.Spec.Teardown; spec != nil && spec.Timeout != nil {
+		return spec.Timeout.Duration
+	}
+	return defaultTeardownTimeout
+}
+
+// teardown runs one step of graceful deletion. Each step either asks the next member to
+// leave, waits for a leaving member, or releases the finalizer once done.
+func (r *ReconcileAkkaCluster) teardown(request reconcile.Request, akkaCluster *appv1alpha1.AkkaCluster) (reconcile.Result, error) {
+	reqLogger := log.WithValues("name", request.String())
+	if !hasFinalizer(akkaCluster, teardownFinalizer) {
+		return reconcile.Result{}, nil
+	}
+	if !wantsTeardown(akkaCluster) {
+		return reconcile.Result{}, r.releaseTeardown(request, akkaCluster)
+	}
+
+	status := akkaCluster.Status
+	if r.statusActor != nil {
+		if current := r.statusActor.GetStatus(request); current != nil {
+			status = current
+		}
+		// keep status fresh while members leave
+		r.statusActor.StartPolling(akkaCluster)
+	}
+
+	if timeout := teardownTimeout(akkaCluster); time.Since(akkaCluster.DeletionTimestamp.Time) > timeout {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "TeardownTimeout",
+			"members did not leave within %s, releasing cluster for deletion", timeout)
+		return reconcile.Result{}, r.releaseTeardown(request, akkaCluster)
+	}
+
+	next, waiting := "", ""
+	staying := make(map[string]bool)
+	for _, member := range r.leaveOrder(akkaCluster, status) {
+		switch member.Status {
+		case "Leaving":
+			staying[management.NodeHost(member.Node)] = true
+			waiting = member.Node
+		case "Exiting":
+			waiting = member.Node
+		case "Joining", "WeaklyUp", "Up":
+			staying[management.NodeHost(member.Node)] = true
+			if next == "" {
+				next = member.Node
+			}
+		}
+	}
+	// A member that left would otherwise be restarted with its container, and bootstrap
+	// back into the cluster.
+	if err := r.scaleToMembers(akkaCluster, staying); err != nil {
+		return reconcile.Result{}, err
+	}
+	if waiting != "" {
+		reqLogger.Info("waiting for member to leave", "node", waiting)
+		return reconcile.Result{RequeueAfter: teardownPollInterval}, nil
+	}
+	if next == "" {
+		if r.statusActor != nil && !r.statusActor.ReadSince(request, akkaCluster.DeletionTimestamp.Time) {
+			reqLogger.Info("waiting for status of members to be read before releasing")
+			return reconcile.Result{RequeueAfter: teardownPollInterval}, nil
+		}
+		r.recorder.Event(akkaCluster, corev1.EventTypeNormal, "TeardownComplete",
+			"all members left the cluster, releasing cluster for deletion")
+		return reconcile.Result{}, r.releaseTeardown(request, akkaCluster)
+	}
+
+	port := status.ManagementPort
+	if port == 0 {
+		port = r.options.Management.FallbackPort()
+	}
+	if _, err := management.OperateMember(r.writer, management.Endpoint(management.NodeHost(next), port), next, management.Leave); err != nil {
+		r.recorder.Eventf(akkaCluster, corev1.EventTypeWarning, "TeardownLeave",
+			"failed to ask member %s to leave: %v", next, err)
+		return reconcile.Result{RequeueAfter: teardownPollInterval}, nil
+	}
+	r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "TeardownLeave", "asked member %s to leave", next)
+	return reconcile.Result{RequeueAfter: teardownPollInterval}, nil
+}
+
+// leaveOrder sorts members youngest first by the creation time of their pods, with the
+// oldest Akka member always last.
+func (r *ReconcileAkkaCluster) leaveOrder(akkaCluster *appv1alpha1.AkkaCluster, status *appv1alpha1.AkkaClusterStatus) []appv1alpha1.AkkaClusterMemberStatus {
+	if status == nil {
+		return nil
+	}
+	// fill in defaults on a copy to find the pod selector; without one, only the oldest is ordered
+	started := make(map[string]time.Time)
+	if defaulted, err := r.defaulted(akkaCluster); err == nil {
+		pods := &corev1.PodList{}
+		r.client.List(context.TODO(), pods, &client.ListOptions{
+			Namespace:     defaulted.Namespace,
+			LabelSelector: labels.SelectorFromSet(defaulted.Spec.Selector.MatchLabels),
+		})
+		for _, pod := range pods.Items {
+			started[pod.Status.PodIP] = pod.CreationTimestamp.Time
+		}
+	}
+	members := append([]appv1alpha1.AkkaClusterMemberStatus{}, status.Cluster.Members...)
+	sort.SliceStable(members, func(i, j int) bool {
+		if members[i].Node == status.Cluster.Oldest || members[j].Node == status.Cluster.Oldest {
+			return members[j].Node == status.Cluster.Oldest && members[i].Node != status.Cluster.Oldest
+		}
+		return started[management.NodeHost(members[i].Node)].After(started[management.NodeHost(members[j].Node)])
+	})
+	return members
+}
+
+// scaleToMembers lowers the replicas of the Deployment to the members still in the cluster,
+// given by the IPs of their pods, ranking the pods of the others first for deletion.
+func (r *ReconcileAkkaCluster) scaleToMembers(akkaCluster *appv1alpha1.AkkaCluster, staying map[string]bool) error {
+	// fill in defaults on a copy to find the name and pod selector, leaving the original untouched
+	defaulted, err := r.defaulted(akkaCluster)
+	if err != nil {
+		return err
+	}
+	deployment := &appsv1.Deployment{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{Namespace: akkaCluster.Namespace, Name: generatedName(defaulted)}, deployment)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	replicas := int32(len(staying))
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas <= replicas {
+		return nil
+	}
+
+	if err := r.markForDeletion(defaulted, func(podIP string) bool { return !staying[podIP] }); err != nil {
+		return err
+	}
+
+	scaled := deployment.DeepCopy()
+	scaled.Spec.Replicas = &replicas
+	if err := r.client.Patch(context.TODO(), scaled, client.MergeFrom(deployment)); err != nil {
+		return err
+	}
+	r.recorder.Eventf(akkaCluster, corev1.EventTypeNormal, "TeardownScaleDown",
+		"scaled deployment %s down to the %d members still in the cluster", deployment.Name, replicas)
+	return nil
+}
+
+// releaseTeardown removes the finalizer so garbage collection can take owned resources.
+func (r *ReconcileAkkaCluster) releaseTeardown(request reconcile.Request, akkaCluster *appv1alpha1.AkkaCluster) error {
+	if r.statusActor != nil {
+		r.statusActor.StopPolling(request)
+	}
+	removeFinalizer(akkaCluster, teardownFinalizer)
+	return r.client.Update(context.TODO(), akkaCluster)
+}
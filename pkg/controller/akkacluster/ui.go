@@ -0,0 +1,138 @@
+package akkacluster
+
+import (
+	"net/http"
+)
+
+// serveUI serves the web UI page at the root of the read API. Other paths are not found.
+func serveUI(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	if !readOnly(w, req) {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(uiPage))
+}
+
+// uiPage draws every AkkaCluster from the read API watch stream: members with their pods
+// and roles, the leader and oldest member, unreachable observations and recent transitions.
+// Text is set through textContent only, as node names and roles come from the clusters.
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Akka Clusters</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; color: #222; background: #f6f7f9; }
+h1 { font-size: 1.4em; }
+#connection { font-size: 0.8em; color: #888; }
+.cluster { background: #fff; border: 1px solid #ddd; border-radius: 4px; margin: 1em 0; padding: 0.5em 1em; }
+.cluster h2 { font-size: 1.1em; margin: 0.3em 0; }
+.summary, .conditions { font-size: 0.85em; color: #555; margin: 0.3em 0; }
+.condition { display: inline-block; padding: 0 0.5em; margin-right: 0.5em; border-radius: 3px; background: #eee; }
+.condition.True { background: #fde2e1; }
+table { border-collapse: collapse; width: 100%; font-size: 0.85em; margin: 0.5em 0; }
+th, td { text-align: left; padding: 0.2em 0.6em; border-bottom: 1px solid #eee; }
+.Up { color: #1a7f37; }
+.Joining, .WeaklyUp { color: #9a6700; }
+.Leaving, .Exiting, .Down, .Removed { color: #cf222e; }
+.unreachable { background: #fde2e1; }
+.tag { font-size: 0.8em; padding: 0 0.4em; margin-left: 0.4em; border-radius: 3px; background: #ddf4ff; }
+.transitions { font-size: 0.8em; color: #555; margin: 0.3em 0 0.6em; padding-left: 1.2em; }
+</style>
+</head>
+<body>
+<h1>Akka Clusters <span id="connection">connecting</span></h1>
+<div id="clusters"></div>
+<script>
+var clusters = {};
+
+function el(tag, text, className) {
+  var e = document.createElement(tag);
+  if (text !== undefined && text !== null) { e.textContent = text; }
+  if (className) { e.className = className; }
+  return e;
+}
+
+function row(cells, className) {
+  var tr = el("tr", null, className);
+  cells.forEach(function (cell) {
+    var td = el("td");
+    if (cell instanceof Node) { td.appendChild(cell); } else { td.textContent = cell; }
+    tr.appendChild(td);
+  });
+  return tr;
+}
+
+function render(cluster) {
+  var status = cluster.status || {};
+  var state = status.cluster || {};
+  var div = el("div", null, "cluster");
+  div.appendChild(el("h2", cluster.namespace + "/" + cluster.name));
+  div.appendChild(el("div", "replicas: " + (cluster.replicas === undefined ? "default" : cluster.replicas) +
+    "   leader: " + (state.leader || "none") + "   oldest: " + (state.oldest || "none") +
+    "   updated: " + (status.lastUpdate || "never"), "summary"));
+
+  var conditions = el("div", null, "conditions");
+  (status.conditions || []).forEach(function (c) {
+    var badge = el("span", c.type + ": " + c.status, "condition " + c.status);
+    badge.title = c.message || "";
+    conditions.appendChild(badge);
+  });
+  div.appendChild(conditions);
+
+  var table = el("table");
+  table.appendChild(row(["node", "pod", "status", "roles", "unreachable by"]));
+  cluster.members.forEach(function (m) {
+    var node = el("span", m.node);
+    if (m.node === state.leader) { node.appendChild(el("span", "leader", "tag")); }
+    if (m.node === state.oldest) { node.appendChild(el("span", "oldest", "tag")); }
+    table.appendChild(row([node, m.pod || "", el("span", m.status, m.status), (m.roles || []).join(", "),
+      (m.observedBy || []).join(", ")], m.reachable ? "" : "unreachable"));
+  });
+  div.appendChild(table);
+
+  if (cluster.transitions.length > 0) {
+    var list = el("ul", null, "transitions");
+    cluster.transitions.slice().reverse().forEach(function (t) {
+      list.appendChild(el("li", t.time + "  " + t.node + ": " + (t.from || "new") + " → " + t.to));
+    });
+    div.appendChild(list);
+  }
+  return div;
+}
+
+function draw() {
+  var container = document.getElementById("clusters");
+  container.textContent = "";
+  var keys = Object.keys(clusters).sort();
+  if (keys.length === 0) { container.appendChild(el("p", "No AkkaClusters.")); }
+  keys.forEach(function (key) { container.appendChild(render(clusters[key])); });
+}
+
+var source = new EventSource("api/v1/watch");
+source.onopen = function () {
+  clusters = {};
+  document.getElementById("connection").textContent = "live";
+};
+source.onerror = function () {
+  document.getElementById("connection").textContent = "reconnecting";
+};
+source.addEventListener("update", function (e) {
+  var cluster = JSON.parse(e.data);
+  clusters[cluster.namespace + "/" + cluster.name] = cluster;
+  draw();
+});
+source.addEventListener("delete", function (e) {
+  var cluster = JSON.parse(e.data);
+  delete clusters[cluster.namespace + "/" + cluster.name];
+  draw();
+});
+draw();
+</script>
+</body>
+</html>
+`
@@ -2,15 +2,17 @@ package controller
 
 import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/lightbend/akka-cluster-operator/pkg/controller/akkacluster"
 )
 
 // AddToManagerFuncs is a list of functions to add all Controllers to the Manager
-var AddToManagerFuncs []func(manager.Manager) error
+var AddToManagerFuncs []func(manager.Manager, akkacluster.Options) error
 
-// AddToManager adds all Controllers to the Manager
-func AddToManager(m manager.Manager) error {
+// AddToManager adds all Controllers to the Manager, with the options of the operator
+func AddToManager(m manager.Manager, options akkacluster.Options) error {
 	for _, f := range AddToManagerFuncs {
-		if err := f(m); err != nil {
+		if err := f(m, options); err != nil {
 			return err
 		}
 	}
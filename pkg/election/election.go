@@ -0,0 +1,218 @@
+// Package election runs the leader-only parts of a controller manager under a Lease lock.
+//
+// The manager of controller-runtime v0.6 only elects leaders with a ConfigMap lock, and
+// operator-sdk's leader.Become is leader-for-life, so a new leader is only elected once the
+// old pod is deleted. Here a replica holds a coordination.k8s.io Lease while it renews it,
+// and a standby takes over once the Lease expires. The manager itself, with its caches,
+// health probes and metrics, runs on every replica. Only runnables that need leader
+// election, like controllers, wait for the Lease, so a standby takes over with warm caches.
+package election
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var log = logf.Log.WithName("election")
+
+// Options name the Lease and set its timings.
+type Options struct {
+	// Namespace and ID name the Lease.
+	Namespace string
+	ID        string
+	// Identity is the holder identity of this replica, by default its hostname and a UUID.
+	Identity string
+	// LeaseDuration is how long standbys wait after the last renewal before taking over.
+	LeaseDuration time.Duration
+	// RenewDeadline is how long the leader keeps trying to renew before giving up.
+	RenewDeadline time.Duration
+	// RetryPeriod is how often candidates try to acquire or renew the Lease.
+	RetryPeriod time.Duration
+}
+
+// DefaultOptions has the timings of controller-runtime leader election.
+var DefaultOptions = Options{
+	ID:            "akka-cluster-operator-lock",
+	LeaseDuration: 15 * time.Second,
+	RenewDeadline: 10 * time.Second,
+	RetryPeriod:   2 * time.Second,
+}
+
+// Manager is a manager.Manager that starts runnables needing leader election only once it
+// holds the Lease. Losing the Lease stops them and fails the manager, so the replica
+// restarts as a standby.
+type Manager struct {
+	manager.Manager
+	elector *elector
+}
+
+// New wraps mgr to elect a leader with a Lease, using client to reach the API server.
+// Runnables must be added to the returned Manager, not to mgr.
+func New(mgr manager.Manager, client kubernetes.Interface, options Options) (*Manager, error) {
+	e, err := newElector(client, mgr.GetEventRecorderFor, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := mgr.Add(e); err != nil {
+		return nil, err
+	}
+	return &Manager{Manager: mgr, elector: e}, nil
+}
+
+// Add adds r to the manager. Runnables that need leader election are held back until this
+// replica leads.
+func (m *Manager) Add(r manager.Runnable) error {
+	if leRunnable, ok := r.(manager.LeaderElectionRunnable); ok && !leRunnable.NeedLeaderElection() {
+		return m.Manager.Add(r)
+	}
+	if err := m.Manager.SetFields(r); err != nil {
+		return err
+	}
+	m.elector.add(r)
+	return nil
+}
+
+// elector is a manager Runnable that campaigns for the Lease, and runs its runnables while
+// it leads.
+type elector struct {
+	lock    resourcelock.Interface
+	options Options
+
+	mu        sync.Mutex
+	runnables []manager.Runnable
+	// leading is closed when leadership is lost, and nil until elected.
+	leading <-chan struct{}
+	failed  func(error)
+}
+
+func newElector(client kubernetes.Interface, recorderFor func(string) record.EventRecorder, options Options) (*elector, error) {
+	if options.Namespace == "" || options.ID == "" {
+		return nil, errors.New("leader election needs the namespace and ID of a Lease")
+	}
+	if options.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		options.Identity = hostname + "_" + string(uuid.NewUUID())
+	}
+	return &elector{
+		lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{Namespace: options.Namespace, Name: options.ID},
+			Client:    client.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity:      options.Identity,
+				EventRecorder: recorderFor(options.Identity),
+			},
+		},
+		options: options,
+	}, nil
+}
+
+// NeedLeaderElection is false, as the elector is what elects the leader.
+func (e *elector) NeedLeaderElection() bool {
+	return false
+}
+
+// add runs r while leading, starting it now if already leading.
+func (e *elector) add(r manager.Runnable) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.runnables = append(e.runnables, r)
+	if e.leading != nil {
+		e.run(r)
+	}
+}
+
+// run starts r until leadership is lost. It runs under mu.
+func (e *elector) run(r manager.Runnable) {
+	stop, failed := e.leading, e.failed
+	go func() {
+		if err := r.Start(stop); err != nil {
+			failed(err)
+		}
+	}()
+}
+
+// lead starts the runnables, stopping them when ctx is done.
+func (e *elector) lead(ctx context.Context, failed func(error)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.leading, e.failed = ctx.Done(), failed
+	for _, r := range e.runnables {
+		e.run(r)
+	}
+}
+
+// Start campaigns for the Lease until stop is closed. It returns an error if leadership is
+// lost or a runnable fails, and releases the Lease on a clean stop.
+func (e *elector) Start(stop <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var once sync.Once
+	var runErr error
+	failed := func(err error) {
+		once.Do(func() {
+			runErr = err
+			cancel()
+		})
+	}
+	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            e.lock,
+		LeaseDuration:   e.options.LeaseDuration,
+		RenewDeadline:   e.options.RenewDeadline,
+		RetryPeriod:     e.options.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            e.options.ID,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				log.Info("became leader", "identity", e.options.Identity)
+				e.lead(ctx, failed)
+			},
+			OnStoppedLeading: func() {
+				log.Info("stopped leading", "identity", e.options.Identity)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != e.options.Identity {
+					log.Info("standing by", "leader", identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("campaigning for leader", "lease", e.options.Namespace+"/"+e.options.ID, "identity", e.options.Identity)
+	le.Run(ctx)
+
+	select {
+	case <-stop:
+		return nil
+	default:
+	}
+	once.Do(func() {
+		runErr = fmt.Errorf("leader election lost")
+	})
+	return runErr
+}
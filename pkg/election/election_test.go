@@ -0,0 +1,108 @@
+package election
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// testRunnable reports when it starts and stops.
+type testRunnable struct {
+	started chan struct{}
+	stopped chan struct{}
+}
+
+func newTestRunnable() *testRunnable {
+	return &testRunnable{started: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+func (r *testRunnable) Start(stop <-chan struct{}) error {
+	close(r.started)
+	<-stop
+	close(r.stopped)
+	return nil
+}
+
+func newTestElector(t *testing.T, client *fake.Clientset, identity string, r manager.Runnable) *elector {
+	options := Options{
+		Namespace:     "operators",
+		ID:            "test-lock",
+		Identity:      identity,
+		LeaseDuration: time.Second,
+		RenewDeadline: 500 * time.Millisecond,
+		RetryPeriod:   100 * time.Millisecond,
+	}
+	e, err := newElector(client, func(string) record.EventRecorder { return record.NewFakeRecorder(10) }, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.add(r)
+	return e
+}
+
+func waitFor(t *testing.T, c <-chan struct{}, what string) {
+	select {
+	case <-c:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected %s", what)
+	}
+}
+
+func TestElection(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	first, second := newTestRunnable(), newTestRunnable()
+	stopFirst, stopSecond := make(chan struct{}), make(chan struct{})
+	defer close(stopSecond)
+
+	firstElector := newTestElector(t, client, "first", first)
+	secondElector := newTestElector(t, client, "second", second)
+	firstDone := make(chan error)
+	go func() { firstDone <- firstElector.Start(stopFirst) }()
+	waitFor(t, first.started, "first replica to lead")
+	go secondElector.Start(stopSecond)
+
+	select {
+	case <-second.started:
+		t.Fatal("expected second replica to stand by while the first leads")
+	case <-time.After(300 * time.Millisecond):
+	}
+	lease, err := client.CoordinationV1().Leases("operators").Get(context.TODO(), "test-lock", metav1.GetOptions{})
+	if err != nil || *lease.Spec.HolderIdentity != "first" {
+		t.Errorf("expected first replica to hold the lease, but got %v %v", lease, err)
+	}
+
+	// a clean stop releases the lease, so the standby takes over without waiting it out
+	close(stopFirst)
+	waitFor(t, first.stopped, "first replica runnables to stop")
+	if err := <-firstDone; err != nil {
+		t.Errorf("expected clean stop, but got %v", err)
+	}
+	waitFor(t, second.started, "second replica to take over")
+}
+
+// failingRunnable fails as soon as it starts.
+type failingRunnable struct{}
+
+func (failingRunnable) Start(<-chan struct{}) error {
+	return errors.New("boom")
+}
+
+func TestElectionRunnableFails(t *testing.T) {
+	e := newTestElector(t, fake.NewSimpleClientset(), "only", failingRunnable{})
+	done := make(chan error)
+	go func() { done <- e.Start(make(chan struct{})) }()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "boom" {
+			t.Errorf("expected runnable error, but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected failing runnable to stop the elector")
+	}
+}
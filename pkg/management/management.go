@@ -0,0 +1,201 @@
+// Package management is a client for the Akka Management HTTP endpoints of cluster members,
+// per https://doc.akka.io/docs/akka-management/current/cluster-http-management.html
+//
+// Endpoints are addressed by a base URL, either the member's own management port as the
+// operator sees it from inside the Kubernetes cluster, or the pod proxy of the API server as
+// kubectl-akka sees it from outside.
+package management
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// Leave moves a member to Leaving, so it hands over its work and exits the cluster.
+	Leave = "Leave"
+	// Down marks a member as Down, removing it from the cluster without a handover.
+	Down = "Down"
+)
+
+const (
+	// DefaultPortName is the name Akka Management examples give the container port.
+	DefaultPortName = "management"
+	// DefaultPort is the port Akka Management listens on by default.
+	DefaultPort = 8558
+)
+
+// Ports find the Akka Management endpoint of pods. Fields left zero take the defaults.
+type Ports struct {
+	// Name names the container port of the endpoint.
+	Name string
+	// Fallback is used when no container port has that name.
+	Fallback int32
+}
+
+// Reader returns the body of the response to a GET of a URL.
+type Reader interface {
+	ReadURL(string) ([]byte, error)
+}
+
+// TimeoutReader is a Reader that can also bound a single read by a timeout.
+type TimeoutReader interface {
+	Reader
+	ReadURLTimeout(string, time.Duration) ([]byte, error)
+}
+
+// ReadWithTimeout reads link with r, bounded by timeout if r is a TimeoutReader and timeout
+// is positive.
+func ReadWithTimeout(r Reader, link string, timeout time.Duration) ([]byte, error) {
+	if tr, ok := r.(TimeoutReader); ok && timeout > 0 {
+		return tr.ReadURLTimeout(link, timeout)
+	}
+	return r.ReadURL(link)
+}
+
+// Writer PUTs form values to a URL and returns the body of the response.
+type Writer interface {
+	PutForm(string, url.Values) ([]byte, error)
+}
+
+// HTTPClient is a Reader and Writer with http.Client.
+type HTTPClient struct {
+	http.Client
+}
+
+// DefaultTimeout bounds requests of an HTTPClient, unless a read asks for another timeout.
+const DefaultTimeout = 3 * time.Second
+
+// NewHTTPClient returns an HTTPClient with a short timeout, given an optional transport.
+func NewHTTPClient(transport http.RoundTripper) *HTTPClient {
+	return &HTTPClient{
+		http.Client{Transport: transport, Timeout: DefaultTimeout},
+	}
+}
+
+func (c *HTTPClient) ReadURL(link string) ([]byte, error) {
+	return c.ReadURLTimeout(link, c.Timeout)
+}
+
+// ReadURLTimeout is ReadURL with a timeout other than the client's own.
+func (c *HTTPClient) ReadURLTimeout(link string, timeout time.Duration) ([]byte, error) {
+	client := c.Client
+	client.Timeout = timeout
+	resp, err := client.Get(link)
+	if err != nil {
+		return nil, err
+	}
+	return readBody(resp)
+}
+
+func (c *HTTPClient) PutForm(link string, form url.Values) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPut, link, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return readBody(resp)
+}
+
+// readBody reads and closes the body of a response, failing on non-2xx status.
+func readBody(resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil && resp.StatusCode >= http.StatusMultipleChoices {
+		err = fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return body, err
+}
+
+// Endpoint is the base URL of the management endpoint of a member, at host and port.
+func Endpoint(host string, port int32) string {
+	return fmt.Sprintf("http://%s:%d", host, port)
+}
+
+// ProxyEndpoint is the base URL of the management endpoint of a pod, through the pod proxy
+// of the API server at apiServer.
+func ProxyEndpoint(apiServer, namespace, pod string, port int32) string {
+	return fmt.Sprintf("%s/api/v1/namespaces/%s/pods/%s:%d/proxy",
+		strings.TrimSuffix(apiServer, "/"), url.PathEscape(namespace), url.PathEscape(pod), port)
+}
+
+// MembersURL lists the cluster members, as seen by the member at endpoint.
+func MembersURL(endpoint string) string {
+	return endpoint + "/cluster/members/"
+}
+
+// MemberURL is a single member node, as seen by the member at endpoint.
+func MemberURL(endpoint, node string) string {
+	return endpoint + "/cluster/members/" + url.PathEscape(node)
+}
+
+// ShardsURL lists the shards of the named entity type hosted by the member at endpoint.
+func ShardsURL(endpoint, entityType string) string {
+	return endpoint + "/cluster/shards/" + url.PathEscape(entityType)
+}
+
+// OperateMember asks the member at endpoint to apply operation, like Leave, to member node.
+func OperateMember(w Writer, endpoint, node, operation string) ([]byte, error) {
+	return w.PutForm(MemberURL(endpoint, node), url.Values{"operation": {operation}})
+}
+
+// ShardDetails is the response of ShardsURL: the shards of one region.
+type ShardDetails struct {
+	Regions []ShardRegionInfo `json:"regions"`
+}
+
+// ShardRegionInfo is one shard and its number of running entities.
+type ShardRegionInfo struct {
+	ShardID     string `json:"shardId"`
+	NumEntities int    `json:"numEntities"`
+}
+
+// Of returns the container port with the name of the endpoint in the pod, or the fallback.
+func (p Ports) Of(pod *corev1.Pod) int32 {
+	name := p.Name
+	if name == "" {
+		name = DefaultPortName
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == name {
+				return port.ContainerPort
+			}
+		}
+	}
+	return p.FallbackPort()
+}
+
+// FallbackPort returns the port used when no container port has the name of the endpoint.
+func (p Ports) FallbackPort() int32 {
+	if p.Fallback == 0 {
+		return DefaultPort
+	}
+	return p.Fallback
+}
+
+// IsRunning is true for pods that have an IP, are not marked for deletion, and are currently
+// running, so their management endpoint may answer.
+func IsRunning(pod *corev1.Pod) bool {
+	return pod.Status.PodIP != "" && pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning
+}
+
+// NodeHost returns the host of an Akka node address like akka://system@host:port, which is
+// the IP of the member's pod.
+func NodeHost(node string) string {
+	nodeURL, err := url.Parse(node)
+	if err != nil {
+		return ""
+	}
+	return nodeURL.Hostname()
+}
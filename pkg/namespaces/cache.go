@@ -0,0 +1,519 @@
+// Package namespaces selects the namespaces an operator watches by label, as they come and
+// go.
+//
+// cache.MultiNamespacedCacheBuilder watches a fixed list of namespaces, so changing the list
+// means redeploying the operator. The cache here watches Namespace objects instead, and
+// keeps a cache for each namespace whose labels match a selector. Namespaces are added as
+// they are labelled, and removed as they are unlabelled or deleted.
+package namespaces
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("namespaces")
+
+// LabelSelectedCacheBuilder returns a cache of the namespaces with labels matching selector,
+// for manager.Options.NewCache. When a namespace is removed, event handlers see each of its
+// objects deleted, and Get finds them NotFound, so controllers let go of them as they would
+// of deleted objects. Namespaces and other cluster-scoped objects are cached cluster-wide.
+func LabelSelectedCacheBuilder(selector labels.Selector) cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		if opts.Scheme == nil {
+			opts.Scheme = scheme.Scheme
+		}
+		if opts.Mapper == nil {
+			mapper, err := apiutil.NewDiscoveryRESTMapper(config)
+			if err != nil {
+				return nil, err
+			}
+			opts.Mapper = mapper
+		}
+		opts.Namespace = ""
+		cluster, err := cache.New(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		newCache := func(namespace string) (cache.Cache, error) {
+			namespaced := opts
+			namespaced.Namespace = namespace
+			return cache.New(config, namespaced)
+		}
+		return newSelectedCache(selector, opts.Scheme, opts.Mapper, cluster, newCache), nil
+	}
+}
+
+// selectedCache is a cache.Cache of a changing set of namespaces.
+type selectedCache struct {
+	selector labels.Selector
+	scheme   *runtime.Scheme
+	mapper   meta.RESTMapper
+	// cluster caches Namespaces and other cluster-scoped objects.
+	cluster  cache.Cache
+	newCache func(namespace string) (cache.Cache, error)
+
+	mu sync.Mutex
+	// stop is nil until started.
+	stop       <-chan struct{}
+	namespaces map[string]*namespaceCache
+	// pending are the namespaces selected before Start, to be added when it runs.
+	pending map[string]bool
+	// kinds are the namespaced informers asked for so far, to be added to new namespaces.
+	kinds   map[schema.GroupVersionKind]*selectedInformer
+	indexes []fieldIndex
+}
+
+var _ cache.Cache = &selectedCache{}
+
+// namespaceCache is the cache of one namespace, running until stop is closed.
+type namespaceCache struct {
+	cache.Cache
+	stop chan struct{}
+}
+
+type fieldIndex struct {
+	obj     runtime.Object
+	field   string
+	extract client.IndexerFunc
+}
+
+func newSelectedCache(selector labels.Selector, scheme *runtime.Scheme, mapper meta.RESTMapper, cluster cache.Cache,
+	newCache func(string) (cache.Cache, error)) *selectedCache {
+	return &selectedCache{
+		selector:   selector,
+		scheme:     scheme,
+		mapper:     mapper,
+		cluster:    cluster,
+		newCache:   newCache,
+		namespaces: make(map[string]*namespaceCache),
+		pending:    make(map[string]bool),
+		kinds:      make(map[schema.GroupVersionKind]*selectedInformer),
+	}
+}
+
+// Start watches Namespaces, and runs a cache for each selected one until stopCh is closed.
+// Namespaces selected before, as WaitForCacheSync may race with Start, are added first.
+func (c *selectedCache) Start(stopCh <-chan struct{}) error {
+	c.mu.Lock()
+	c.stop = stopCh
+	pending := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+	for name := range pending {
+		c.add(name)
+	}
+
+	informer, err := c.cluster.GetInformer(context.TODO(), &corev1.Namespace{})
+	if err != nil {
+		return err
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.observe(obj) },
+		UpdateFunc: func(_, obj interface{}) { c.observe(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if namespace, ok := obj.(*corev1.Namespace); ok {
+				c.remove(namespace.Name)
+			}
+		},
+	})
+	go func() {
+		<-stopCh
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for name, namespace := range c.namespaces {
+			close(namespace.stop)
+			delete(c.namespaces, name)
+		}
+	}()
+	return c.cluster.Start(stopCh)
+}
+
+// WaitForCacheSync waits for Namespaces, then for the caches of the namespaces selected by
+// then.
+func (c *selectedCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	if !c.cluster.WaitForCacheSync(stop) {
+		return false
+	}
+	// namespace events may not all be handled yet, so select from the synced list
+	namespaces := &corev1.NamespaceList{}
+	if err := c.cluster.List(context.TODO(), namespaces); err != nil {
+		log.Error(err, "could not list namespaces")
+		return false
+	}
+	for i := range namespaces.Items {
+		c.observe(&namespaces.Items[i])
+	}
+	c.mu.Lock()
+	caches := make([]cache.Cache, 0, len(c.namespaces))
+	for _, namespace := range c.namespaces {
+		caches = append(caches, namespace.Cache)
+	}
+	c.mu.Unlock()
+	for _, namespace := range caches {
+		if !namespace.WaitForCacheSync(stop) {
+			return false
+		}
+	}
+	return true
+}
+
+// observe adds or removes a namespace as its labels match the selector or not.
+func (c *selectedCache) observe(obj interface{}) {
+	namespace, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return
+	}
+	if c.selector.Matches(labels.Set(namespace.Labels)) {
+		c.add(namespace.Name)
+	} else {
+		c.remove(namespace.Name)
+	}
+}
+
+// add starts a cache for a namespace, with the informers and indexes asked for so far, or
+// keeps it pending until Start.
+func (c *selectedCache) add(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop == nil {
+		c.pending[name] = true
+		return
+	}
+	if _, ok := c.namespaces[name]; ok {
+		return
+	}
+	select {
+	case <-c.stop:
+		return
+	default:
+	}
+	created, err := c.newCache(name)
+	if err != nil {
+		log.Error(err, "could not create cache", "namespace", name)
+		return
+	}
+	for _, index := range c.indexes {
+		if err := created.IndexField(context.TODO(), index.obj, index.field, index.extract); err != nil {
+			log.Error(err, "could not index field", "namespace", name, "field", index.field)
+			return
+		}
+	}
+	for _, kind := range c.kinds {
+		informer, err := created.GetInformer(context.TODO(), kind.obj)
+		if err == nil {
+			err = kind.attach(name, informer)
+		}
+		if err != nil {
+			log.Error(err, "could not get informer", "namespace", name)
+			for _, kind := range c.kinds {
+				kind.detach(name)
+			}
+			return
+		}
+	}
+	stop := make(chan struct{})
+	c.namespaces[name] = &namespaceCache{Cache: created, stop: stop}
+	go func() {
+		if err := created.Start(stop); err != nil {
+			log.Error(err, "namespace cache failed", "namespace", name)
+		}
+	}()
+	log.Info("watching namespace", "namespace", name)
+}
+
+// remove stops the cache of a namespace, first telling event handlers its objects are gone.
+func (c *selectedCache) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, name)
+	namespace, ok := c.namespaces[name]
+	if !ok {
+		return
+	}
+	delete(c.namespaces, name)
+	for gvk, kind := range c.kinds {
+		handlers := kind.detach(name)
+		objs, err := c.listKind(namespace, gvk)
+		if err != nil {
+			log.Error(err, "could not list objects of removed namespace", "namespace", name, "kind", gvk.Kind)
+			continue
+		}
+		for _, obj := range objs {
+			for _, handler := range handlers {
+				handler.OnDelete(obj)
+			}
+		}
+	}
+	close(namespace.stop)
+	log.Info("stopped watching namespace", "namespace", name)
+}
+
+// listKind lists every cached object of a kind in a namespace cache.
+func (c *selectedCache) listKind(namespace *namespaceCache, gvk schema.GroupVersionKind) ([]runtime.Object, error) {
+	list, err := c.scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err != nil {
+		return nil, err
+	}
+	if err := namespace.List(context.TODO(), list); err != nil {
+		return nil, err
+	}
+	return meta.ExtractList(list)
+}
+
+// mapping finds the resource of a kind, and if it is namespaced.
+func (c *selectedCache) mapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, bool, error) {
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, false, err
+	}
+	return mapping, mapping.Scope.Name() != meta.RESTScopeNameRoot, nil
+}
+
+func (c *selectedCache) GetInformer(ctx context.Context, obj runtime.Object) (cache.Informer, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return nil, err
+	}
+	return c.informerFor(ctx, gvk, obj)
+}
+
+func (c *selectedCache) GetInformerForKind(ctx context.Context, gvk schema.GroupVersionKind) (cache.Informer, error) {
+	obj, err := c.scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	return c.informerFor(ctx, gvk, obj)
+}
+
+// informerFor returns an informer over the selected namespaces, which follows them as they
+// change.
+func (c *selectedCache) informerFor(ctx context.Context, gvk schema.GroupVersionKind, obj runtime.Object) (cache.Informer, error) {
+	_, namespaced, err := c.mapping(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if !namespaced {
+		return c.cluster.GetInformer(ctx, obj)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if kind, ok := c.kinds[gvk]; ok {
+		return kind, nil
+	}
+	kind := &selectedInformer{obj: obj, informers: make(map[string]cache.Informer)}
+	for name, namespace := range c.namespaces {
+		informer, err := namespace.GetInformer(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+		kind.informers[name] = informer
+	}
+	c.kinds[gvk] = kind
+	return kind, nil
+}
+
+func (c *selectedCache) IndexField(ctx context.Context, obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return err
+	}
+	_, namespaced, err := c.mapping(gvk)
+	if err != nil {
+		return err
+	}
+	if !namespaced {
+		return c.cluster.IndexField(ctx, obj, field, extractValue)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexes = append(c.indexes, fieldIndex{obj: obj, field: field, extract: extractValue})
+	for _, namespace := range c.namespaces {
+		if err := namespace.IndexField(ctx, obj, field, extractValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get finds objects in namespaces that aren't selected NotFound.
+func (c *selectedCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return err
+	}
+	mapping, namespaced, err := c.mapping(gvk)
+	if err != nil {
+		return err
+	}
+	if !namespaced {
+		return c.cluster.Get(ctx, key, obj)
+	}
+	c.mu.Lock()
+	namespace, ok := c.namespaces[key.Namespace]
+	c.mu.Unlock()
+	if !ok {
+		return errors.NewNotFound(mapping.Resource.GroupResource(), key.Name)
+	}
+	return namespace.Get(ctx, key, obj)
+}
+
+// List lists across the selected namespaces, or finds nothing in a namespace that isn't.
+func (c *selectedCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	gvk, err := apiutil.GVKForObject(list, c.scheme)
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(gvk.Kind, "List") {
+		return fmt.Errorf("non-list type %T (kind %q) passed as output", list, gvk)
+	}
+	_, namespaced, err := c.mapping(gvk.GroupVersion().WithKind(strings.TrimSuffix(gvk.Kind, "List")))
+	if err != nil {
+		return err
+	}
+	if !namespaced {
+		return c.cluster.List(ctx, list, opts...)
+	}
+
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	c.mu.Lock()
+	namespaces := []cache.Cache{}
+	for name, namespace := range c.namespaces {
+		if listOpts.Namespace == corev1.NamespaceAll || listOpts.Namespace == name {
+			namespaces = append(namespaces, namespace.Cache)
+		}
+	}
+	c.mu.Unlock()
+
+	allItems := []runtime.Object{}
+	for _, namespace := range namespaces {
+		namespaceList := list.DeepCopyObject()
+		if err := namespace.List(ctx, namespaceList, opts...); err != nil {
+			return err
+		}
+		items, err := meta.ExtractList(namespaceList)
+		if err != nil {
+			return err
+		}
+		allItems = append(allItems, items...)
+	}
+	return meta.SetList(list, allItems)
+}
+
+// selectedInformer is an informer of one kind across the selected namespaces. Handlers and
+// indexers are kept, to be added to the informers of namespaces selected later.
+type selectedInformer struct {
+	obj runtime.Object
+
+	mu        sync.Mutex
+	informers map[string]cache.Informer
+	handlers  []resyncHandler
+	indexers  []toolscache.Indexers
+}
+
+var _ cache.Informer = &selectedInformer{}
+
+// resyncHandler is an event handler and its resync period, if not the default.
+type resyncHandler struct {
+	toolscache.ResourceEventHandler
+	resyncPeriod *time.Duration
+}
+
+func (h resyncHandler) addTo(informer cache.Informer) {
+	if h.resyncPeriod == nil {
+		informer.AddEventHandler(h.ResourceEventHandler)
+	} else {
+		informer.AddEventHandlerWithResyncPeriod(h.ResourceEventHandler, *h.resyncPeriod)
+	}
+}
+
+func (i *selectedInformer) AddEventHandler(handler toolscache.ResourceEventHandler) {
+	i.addHandler(resyncHandler{ResourceEventHandler: handler})
+}
+
+func (i *selectedInformer) AddEventHandlerWithResyncPeriod(handler toolscache.ResourceEventHandler, resyncPeriod time.Duration) {
+	i.addHandler(resyncHandler{ResourceEventHandler: handler, resyncPeriod: &resyncPeriod})
+}
+
+func (i *selectedInformer) addHandler(handler resyncHandler) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.handlers = append(i.handlers, handler)
+	for _, informer := range i.informers {
+		handler.addTo(informer)
+	}
+}
+
+func (i *selectedInformer) AddIndexers(indexers toolscache.Indexers) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.indexers = append(i.indexers, indexers)
+	for _, informer := range i.informers {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *selectedInformer) HasSynced() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, informer := range i.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// attach adds the informer of a newly selected namespace, with the indexers and handlers
+// added so far.
+func (i *selectedInformer) attach(namespace string, informer cache.Informer) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, indexers := range i.indexers {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	for _, handler := range i.handlers {
+		handler.addTo(informer)
+	}
+	i.informers[namespace] = informer
+	return nil
+}
+
+// detach drops the informer of a namespace no longer selected, and returns the handlers to
+// tell about it.
+func (i *selectedInformer) detach(namespace string) []toolscache.ResourceEventHandler {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.informers, namespace)
+	handlers := make([]toolscache.ResourceEventHandler, 0, len(i.handlers))
+	for _, handler := range i.handlers {
+		handlers = append(handlers, handler.ResourceEventHandler)
+	}
+	return handlers
+}
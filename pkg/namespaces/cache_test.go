@@ -0,0 +1,164 @@
+package namespaces
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// testCache is a fake namespace cache holding some ConfigMaps.
+type testCache struct {
+	*informertest.FakeInformers
+	configMaps []corev1.ConfigMap
+	started    chan struct{}
+}
+
+func (c *testCache) Start(<-chan struct{}) error {
+	close(c.started)
+	return nil
+}
+
+func (c *testCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	for i := range c.configMaps {
+		if c.configMaps[i].Name == key.Name {
+			c.configMaps[i].DeepCopyInto(obj.(*corev1.ConfigMap))
+			return nil
+		}
+	}
+	return errors.NewNotFound(corev1.Resource("configmaps"), key.Name)
+}
+
+func (c *testCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	list.(*corev1.ConfigMapList).Items = append([]corev1.ConfigMap{}, c.configMaps...)
+	return nil
+}
+
+func testMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	return mapper
+}
+
+func namespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestLabelSelectedCache(t *testing.T) {
+	selector, _ := labels.Parse("akka-operator=enabled")
+	cluster := &informertest.FakeInformers{}
+	caches := make(map[string]*testCache)
+	newCache := func(name string) (cache.Cache, error) {
+		configMap := corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: name, Name: "config"}}
+		caches[name] = &testCache{FakeInformers: &informertest.FakeInformers{}, configMaps: []corev1.ConfigMap{configMap},
+			started: make(chan struct{})}
+		return caches[name], nil
+	}
+	c := newSelectedCache(selector, scheme.Scheme, testMapper(), cluster, newCache)
+	if err := c.Start(make(chan struct{})); err != nil {
+		t.Fatal(err)
+	}
+
+	// a handler added before any namespace is selected follows them as they are
+	added, deleted := []string{}, []string{}
+	informer, err := c.GetInformer(context.TODO(), &corev1.ConfigMap{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { added = append(added, obj.(*corev1.ConfigMap).Namespace) },
+		DeleteFunc: func(obj interface{}) { deleted = append(deleted, obj.(*corev1.ConfigMap).Namespace) },
+	})
+
+	namespaces, _ := cluster.FakeInformerFor(&corev1.Namespace{})
+	namespaces.Add(namespace("tenant-a", map[string]string{"akka-operator": "enabled"}))
+	namespaces.Add(namespace("other", nil))
+	if len(caches) != 1 || caches["tenant-a"] == nil {
+		t.Fatalf("expected a cache for the labelled namespace only, but got %v", caches)
+	}
+	select {
+	case <-caches["tenant-a"].started:
+	case <-time.After(time.Second):
+		t.Fatal("expected the cache of the labelled namespace to start")
+	}
+	tenantConfigMaps, _ := caches["tenant-a"].FakeInformerFor(&corev1.ConfigMap{})
+	tenantConfigMaps.Add(&caches["tenant-a"].configMaps[0])
+	if len(added) != 1 || added[0] != "tenant-a" {
+		t.Errorf("expected handler to see objects of the selected namespace, but got %v", added)
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "tenant-a", Name: "config"}, configMap); err != nil {
+		t.Errorf("expected object of selected namespace, but got %v", err)
+	}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "other", Name: "config"}, configMap); !errors.IsNotFound(err) {
+		t.Errorf("expected NotFound in an unselected namespace, but got %v", err)
+	}
+	list := &corev1.ConfigMapList{}
+	if err := c.List(context.TODO(), list); err != nil || len(list.Items) != 1 {
+		t.Errorf("expected one object across selected namespaces, but got %v %v", list.Items, err)
+	}
+
+	// unlabelling deletes its objects from the point of view of handlers
+	namespaces.Update(namespace("tenant-a", map[string]string{"akka-operator": "enabled"}), namespace("tenant-a", nil))
+	if len(deleted) != 1 || deleted[0] != "tenant-a" {
+		t.Errorf("expected handler to see objects of an unselected namespace deleted, but got %v", deleted)
+	}
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "tenant-a", Name: "config"}, configMap); !errors.IsNotFound(err) {
+		t.Errorf("expected NotFound once unselected, but got %v", err)
+	}
+	if err := c.List(context.TODO(), list, client.InNamespace("tenant-a")); err != nil || len(list.Items) != 0 {
+		t.Errorf("expected nothing listed once unselected, but got %v %v", list.Items, err)
+	}
+
+	// labelling it again watches it again
+	namespaces.Update(namespace("tenant-a", nil), namespace("tenant-a", map[string]string{"akka-operator": "enabled"}))
+	if err := c.Get(context.TODO(), types.NamespacedName{Namespace: "tenant-a", Name: "config"}, configMap); err != nil {
+		t.Errorf("expected object once selected again, but got %v", err)
+	}
+}
+
+func TestSelectedBeforeStart(t *testing.T) {
+	selector, _ := labels.Parse("akka-operator=enabled")
+	caches := make(map[string]*testCache)
+	newCache := func(name string) (cache.Cache, error) {
+		caches[name] = &testCache{FakeInformers: &informertest.FakeInformers{}, started: make(chan struct{})}
+		return caches[name], nil
+	}
+	c := newSelectedCache(selector, scheme.Scheme, testMapper(), &informertest.FakeInformers{}, newCache)
+
+	// WaitForCacheSync may select namespaces before Start runs
+	c.observe(namespace("early", map[string]string{"akka-operator": "enabled"}))
+	c.observe(namespace("dropped", map[string]string{"akka-operator": "enabled"}))
+	c.observe(namespace("dropped", nil))
+	if len(caches) != 0 {
+		t.Errorf("expected no caches before start, but got %d", len(caches))
+	}
+	if err := c.Start(make(chan struct{})); err != nil {
+		t.Fatal(err)
+	}
+	if caches["early"] == nil {
+		t.Fatalf("expected a namespace selected before start to be added on start")
+	}
+	select {
+	case <-caches["early"].started:
+	case <-time.After(time.Second):
+		t.Errorf("expected the cache of a namespace selected before start to be started")
+	}
+	if _, ok := caches["dropped"]; ok {
+		t.Errorf("expected a namespace unselected before start not to be added")
+	}
+}